@@ -40,6 +40,7 @@ const (
 
 type Client interface {
 	Sign(context *ct.ContractContext) error
+	SignPartialTransaction(ptx *transaction.PartialTransaction) (int, error)
 
 	ContainsAccount(pubKey *crypto.PubKey) bool
 	CreateAccount() (*Account, error)
@@ -481,6 +482,24 @@ func (cl *ClientImpl) Sign(context *ct.ContractContext) error {
 	return nil
 }
 
+// SignPartialTransaction provides the codes of the wallet contracts to ptx
+// and signs it with every account of the wallet, it returns the number of
+// signatures added.
+func (cl *ClientImpl) SignPartialTransaction(ptx *transaction.PartialTransaction) (int, error) {
+	for _, contract := range cl.GetContracts() {
+		ptx.SetCode(contract.Code)
+	}
+	signed := 0
+	for _, acct := range cl.GetAccounts() {
+		n, err := ptx.Sign(acct)
+		if err != nil {
+			return signed, err
+		}
+		signed += n
+	}
+	return signed, nil
+}
+
 func (cl *ClientImpl) verifyPasswordKey(passwordKey []byte) bool {
 	savedPasswordHash, err := cl.LoadStoredData("PasswordHash")
 	if err != nil {
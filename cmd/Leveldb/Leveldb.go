@@ -1,10 +1,13 @@
 package main
 
 import (
+	"IPT/account"
 	. "IPT/common"
+	"IPT/common/config"
 	"IPT/common/log"
 	"IPT/core/ledger"
-	"IPT/core/store"
+	"IPT/core/store/ChainStore"
+	"IPT/core/transaction"
 	"IPT/crypto"
 	"encoding/hex"
 	"fmt"
@@ -21,6 +24,7 @@ var Usage = func() {
 	fmt.Println("  block <hash>")
 	fmt.Println("  tx <txid>")
 	fmt.Println("  asset <assetid>")
+	fmt.Println("  verify [repair]")
 }
 
 func DumpDB() {
@@ -45,12 +49,12 @@ func DumpDB() {
 		fd.Write([]byte(fmt.Sprintf("Block hash: %x\n", h.ToArray())))
 		fd.Write([]byte(fmt.Sprintf("Block timestamp: %d\n", block.Blockdata.Timestamp)))
 		fd.Write([]byte(fmt.Sprintf("Block transactionsRoot :%x\n", block.Blockdata.TransactionsRoot)))
-		fd.Write([]byte(fmt.Sprintf("Tx Len: %d\n", len(block.Transcations))))
+		fd.Write([]byte(fmt.Sprintf("Tx Len: %d\n", len(block.Transactions))))
 
-		for k := 0; k < len(block.Transcations); k++ {
-			txhash := block.Transcations[k].Hash()
+		for k := 0; k < len(block.Transactions); k++ {
+			txhash := block.Transactions[k].Hash()
 			fd.Write([]byte(fmt.Sprintf("Tx hash: %x\n", txhash.ToArray())))
-			fd.Write([]byte(fmt.Sprintf("Tx Type: %x\n", block.Transcations[k].TxType)))
+			fd.Write([]byte(fmt.Sprintf("Tx Type: %x\n", block.Transactions[k].TxType)))
 		}
 
 		fd.Write([]byte("\n"))
@@ -72,12 +76,12 @@ func GetBlock(hash string) {
 	if err == nil {
 		fmt.Printf("hash: %s\n", hash)
 		fmt.Printf("height: %d\n", block.Blockdata.Height)
-		fmt.Printf("Tx Len: %d\n", len(block.Transcations))
+		fmt.Printf("Tx Len: %d\n", len(block.Transactions))
 
-		for k := 0; k < len(block.Transcations); k++ {
-			txhash := block.Transcations[k].Hash()
+		for k := 0; k < len(block.Transactions); k++ {
+			txhash := block.Transactions[k].Hash()
 			fmt.Printf("Tx hash: %x\n", txhash.ToArray())
-			fmt.Printf("Tx Type: %x\n", block.Transcations[k].TxType)
+			fmt.Printf("Tx Type: %x\n", block.Transactions[k].TxType)
 			fmt.Printf("\n")
 		}
 	} else {
@@ -113,7 +117,6 @@ func GetAsset(assetid string) {
 	asset, err := ledger.DefaultLedger.Store.GetAsset(uhash)
 	if err == nil {
 		fmt.Printf("txid: %s\n", assetid)
-		asset.ID
 		fmt.Printf("asset.TxType: %x\n", asset.AssetType)
 		fmt.Printf("asset.Name: %s\n", asset.Name)
 		fmt.Printf("asset.RecordType: %x\n", asset.RecordType)
@@ -123,13 +126,43 @@ func GetAsset(assetid string) {
 	}
 }
 
+func VerifyDB(repair bool) {
+	mismatches, err := ledger.DefaultLedger.Store.VerifyLedger(repair)
+	if err != nil {
+		fmt.Printf("err: %s\n", err)
+		return
+	}
+	for _, m := range mismatches {
+		fmt.Println(m)
+	}
+	if repair && len(mismatches) > 0 {
+		fmt.Printf("%d mismatches repaired\n", len(mismatches))
+	} else {
+		fmt.Printf("%d mismatches\n", len(mismatches))
+	}
+}
+
 func main() {
-	crypto.SetAlg(crypto.P256R1)
 	log.CreatePrintLog(path)
+	crypto.SetAlg(config.Parameters.EncryptAlg)
 
+	var err error
 	ledger.DefaultLedger = new(ledger.Ledger)
-	ledger.DefaultLedger.Store = store.NewLedgerStore()
+	ledger.DefaultLedger.Store, err = ChainStore.NewLedgerStore()
+	if err != nil {
+		fmt.Printf("open LedgerStore err: %s\n", err)
+		return
+	}
+	defer ledger.DefaultLedger.Store.Close()
 	ledger.DefaultLedger.Store.InitLedgerStore(ledger.DefaultLedger)
+	transaction.TxStore = ledger.DefaultLedger.Store
+
+	ledger.StandbyBookKeepers = account.GetBookKeepers()
+	ledger.DefaultLedger.Blockchain, err = ledger.NewBlockchainWithGenesisBlock(ledger.StandbyBookKeepers)
+	if err != nil {
+		fmt.Printf("load blockchain err: %s\n", err)
+		return
+	}
 
 	args := os.Args
 	if args == nil || len(args) < 2 {
@@ -149,6 +182,8 @@ func main() {
 	} else if cmd == "asset" {
 		assetid := args[2]
 		GetAsset(assetid)
+	} else if cmd == "verify" {
+		VerifyDB(len(args) > 2 && args[2] == "repair")
 	}
 
 }
@@ -9,6 +9,7 @@ import (
 	"IPT/account"
 	. "IPT/cmd/common"
 	. "IPT/common"
+	"IPT/core/asset"
 	"IPT/core/transaction"
 	"IPT/msg/rpc"
 	"IPT/sdk"
@@ -110,7 +111,11 @@ func assetAction(c *cli.Context) error {
 	case c.Bool("reg"):
 		name := parseAssetName(c)
 		wallet := openWallet(c.String("wallet"), WalletPassword(c.String("password")))
-		txn, err = sdk.MakeRegTransaction(wallet, name, value)
+		recordType := asset.UTXO
+		if c.Bool("balance") {
+			recordType = asset.Balance
+		}
+		txn, err = sdk.MakeRegTransaction(wallet, name, value, recordType)
 	case c.Bool("issue"):
 		assetID := parseAssetID(c)
 		address := parseAddress(c)
@@ -126,6 +131,15 @@ func assetAction(c *cli.Context) error {
 		}
 		FormatOutput(resp)
 		return nil
+	case c.Bool("burn"):
+		assetID := c.String("asset")
+		resp, err := rpc.Call(Address(), "burnasset", 0, []interface{}{assetID, value})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return err
+		}
+		FormatOutput(resp)
+		return nil
 	case c.Bool("lock"):
 		assetID := c.String("asset")
 		height := parseHeight(c)
@@ -161,7 +175,7 @@ func assetAction(c *cli.Context) error {
 func NewCommand() *cli.Command {
 	return &cli.Command{
 		Name:        "asset",
-		Usage:       "asset registration, issuance and transfer",
+		Usage:       "asset registration, issuance, transfer and burn",
 		Description: "With nodectl asset, you could control assert through transaction.",
 		ArgsUsage:   "[args]",
 		Flags: []cli.Flag{
@@ -177,10 +191,18 @@ func NewCommand() *cli.Command {
 				Name:  "transfer, t",
 				Usage: "transfer asset",
 			},
+			cli.BoolFlag{
+				Name:  "burn",
+				Usage: "burn asset",
+			},
 			cli.BoolFlag{
 				Name:  "lock",
 				Usage: "lock asset",
 			},
+			cli.BoolFlag{
+				Name:  "balance",
+				Usage: "register an account balance asset instead of an UTXO one, with --reg",
+			},
 			cli.StringFlag{
 				Name:  "wallet, w",
 				Usage: "wallet name",
@@ -0,0 +1,144 @@
+package chain
+
+import (
+	"fmt"
+	"os"
+
+	. "IPT/cmd/common"
+	"IPT/msg/rpc"
+
+	"github.com/urfave/cli"
+)
+
+func rollbackChain(c *cli.Context) error {
+	height := c.Int64("height")
+	if height < 0 {
+		fmt.Fprintln(os.Stderr, "target height is required with [--height]")
+		os.Exit(1)
+	}
+	resp, err := rpc.Call(Address(), "rollbackto", 0, []interface{}{height})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	FormatOutput(resp)
+
+	return nil
+}
+
+func exportChain(c *cli.Context) error {
+	from := c.Int64("from")
+	to := c.Int64("to")
+	if from < 0 || to < 0 {
+		fmt.Fprintln(os.Stderr, "block range is required with [--from] and [--to]")
+		os.Exit(1)
+	}
+	file := c.String("file")
+	if file == "" {
+		fmt.Fprintln(os.Stderr, "block stream file is required with [--file]")
+		os.Exit(1)
+	}
+	resp, err := rpc.Call(Address(), "exportblocks", 0, []interface{}{from, to, file})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	FormatOutput(resp)
+
+	return nil
+}
+
+func importChain(c *cli.Context) error {
+	file := c.String("file")
+	if file == "" {
+		file = c.Args().First()
+	}
+	if file == "" {
+		fmt.Fprintln(os.Stderr, "block stream file is required with [--file]")
+		os.Exit(1)
+	}
+	resp, err := rpc.Call(Address(), "importblocks", 0, []interface{}{file})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	FormatOutput(resp)
+
+	return nil
+}
+
+func chainAction(c *cli.Context) error {
+	if c.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+
+	var err error
+	switch {
+	case c.Bool("rollback"):
+		err = rollbackChain(c)
+	case c.Bool("export"):
+		err = exportChain(c)
+	case c.Bool("import"):
+		err = importChain(c)
+	default:
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+func NewCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "chain",
+		Usage: "local chain maintenance",
+		Description: "With nodectl chain, you could roll the local chain back to an earlier block, " +
+			"or export blocks to a file and import them on a node without P2P sync. " +
+			"The node serves these to nodectl on the same host when MaintenanceRPC is set in config.json.",
+		ArgsUsage: "[args]",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "rollback",
+				Usage: "remove every block above the target height",
+			},
+			cli.Int64Flag{
+				Name:  "height",
+				Usage: "target block height",
+				Value: -1,
+			},
+			cli.BoolFlag{
+				Name:  "export",
+				Usage: "write the blocks from [--from] to [--to] to a file on the node",
+			},
+			cli.BoolFlag{
+				Name:  "import",
+				Usage: "verify and save the blocks of a file on the node",
+			},
+			cli.Int64Flag{
+				Name:  "from",
+				Usage: "first block height to export",
+				Value: -1,
+			},
+			cli.Int64Flag{
+				Name:  "to",
+				Usage: "last block height to export",
+				Value: -1,
+			},
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "block stream file path on the node, a new file for [--export]",
+			},
+		},
+		Action: chainAction,
+		OnUsageError: func(c *cli.Context, err error, isSubcommand bool) error {
+			PrintError(c, err, "chain")
+			return cli.NewExitError("", 1)
+		},
+	}
+}
@@ -6,16 +6,20 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"sync"
 
 	"IPT/common/config"
 	"IPT/common/password"
+	sig "IPT/core/signature"
+	"IPT/msg/rpc"
 
 	"github.com/urfave/cli"
 )
 
 var (
-	Ip   string
-	Port string
+	Ip            string
+	Port          string
+	SigningHeight uint
 )
 
 func NewIpFlag() cli.Flag {
@@ -36,6 +40,43 @@ func NewPortFlag() cli.Flag {
 	}
 }
 
+func NewSigningHeightFlag() cli.Flag {
+	return cli.UintFlag{
+		Name:        "signing-height",
+		Usage:       "height of the block the transactions signed are for, the next block of the node by default",
+		Destination: &SigningHeight,
+	}
+}
+
+// SetSigningHeight makes the transactions signed by nodectl sign for the block
+// at --signing-height, or for the next block of the node when it is not given
+// and the node answers. The node is asked once, when something is signed.
+func SetSigningHeight(c *cli.Context) error {
+	if c.GlobalIsSet("signing-height") {
+		height := uint32(SigningHeight)
+		sig.SigningHeight = func() uint32 { return height }
+		return nil
+	}
+	var once sync.Once
+	height := sig.SigningHeight()
+	sig.SigningHeight = func() uint32 {
+		once.Do(func() {
+			resp, err := rpc.Call(Address(), "getblockcount", 0, []interface{}{})
+			if err != nil {
+				return
+			}
+			var out struct {
+				Result uint32 `json:"result"`
+			}
+			if err := json.Unmarshal(resp, &out); err == nil && out.Result > 0 {
+				height = out.Result
+			}
+		})
+		return height
+	}
+	return nil
+}
+
 func Address() string {
 	address := "http://" + Ip + ":" + Port
 	return address
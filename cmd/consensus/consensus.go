@@ -36,6 +36,21 @@ func consensusAction(c *cli.Context) (err error) {
 	return nil
 }
 
+func policyAction(c *cli.Context) error {
+	method := "getconsensuspolicy"
+	if c.Bool("reload") {
+		method = "reloadconsensuspolicy"
+	}
+	resp, err := rpc.Call(Address(), method, 0, []interface{}{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	FormatOutput(resp)
+
+	return nil
+}
+
 func NewCommand() *cli.Command {
 	return &cli.Command{
 		Name:        "consensus",
@@ -51,6 +66,20 @@ func NewCommand() *cli.Command {
 				Usage: "stop consensue",
 			},
 		},
+		Subcommands: []cli.Command{
+			{
+				Name:        "policy",
+				Usage:       "show or reload the consensus policy",
+				Description: "The policy decides which transactions the node puts into blocks, it is loaded from the policy file.",
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "reload",
+						Usage: "load the policy file again",
+					},
+				},
+				Action: policyAction,
+			},
+		},
 		Action: consensusAction,
 		OnUsageError: func(c *cli.Context, err error, isSubcommand bool) error {
 			PrintError(c, err, "consensus")
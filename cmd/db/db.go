@@ -0,0 +1,71 @@
+package db
+
+import (
+	"fmt"
+	"os"
+
+	. "IPT/cmd/common"
+	"IPT/msg/rpc"
+
+	"github.com/urfave/cli"
+)
+
+func verifyLedger(c *cli.Context) error {
+	resp, err := rpc.Call(Address(), "verifyledger", 0, []interface{}{c.Bool("repair")})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	FormatOutput(resp)
+
+	return nil
+}
+
+func dbAction(c *cli.Context) error {
+	if c.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+
+	var err error
+	switch {
+	case c.Bool("verify"):
+		err = verifyLedger(c)
+	default:
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+func NewCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "db",
+		Usage: "ledger database maintenance",
+		Description: "With nodectl db, you could replay the stored blocks and check the unspent outputs, " +
+			"address UTXO lists, account balances and issued quantities derived from them. " +
+			"The node serves this to nodectl on the same host when MaintenanceRPC is set in config.json.",
+		ArgsUsage: "[args]",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "verify, v",
+				Usage: "report every mismatch between the derived indexes and the blocks",
+			},
+			cli.BoolFlag{
+				Name:  "repair",
+				Usage: "rewrite the mismatched indexes from the blocks",
+			},
+		},
+		Action: dbAction,
+		OnUsageError: func(c *cli.Context, err error, isSubcommand bool) error {
+			PrintError(c, err, "db")
+			return cli.NewExitError("", 1)
+		},
+	}
+}
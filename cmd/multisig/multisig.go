@@ -2,8 +2,11 @@ package multisig
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
 
 	"IPT/account"
 	. "IPT/cmd/common"
@@ -40,50 +43,164 @@ func createMultisigTransaction(c *cli.Context) error {
 		fmt.Fprintln(os.Stderr, err)
 		return err
 	}
-	FormatOutput(resp)
 
-	return nil
+	return writeResponse(c, resp)
 }
 
-func checkMultisigTransaction(c *cli.Context) error {
+// readTransactions returns the hex encoded transactions given with --rawtxn
+// and --file.
+func readTransactions(c *cli.Context) []string {
+	var txns []string
 	if rawtxn := c.String("rawtxn"); rawtxn != "" {
-		raw, err := common.HexStringToBytes(rawtxn)
+		txns = append(txns, rawtxn)
+	}
+	for _, name := range c.StringSlice("file") {
+		data, err := ioutil.ReadFile(name)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "invalid transaction format")
+			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
-		var txn transaction.Transaction
-		err = txn.Deserialize(bytes.NewReader(raw))
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "invalid transaction")
+		txns = append(txns, strings.TrimSpace(string(data)))
+	}
+	if len(txns) == 0 {
+		fmt.Fprintln(os.Stderr, "transaction is required with [--rawtxn] or [--file]")
+		os.Exit(1)
+	}
+	return txns
+}
+
+// readPartialTransactions decodes and merges the partial transactions given,
+// which works without a node.
+func readPartialTransactions(c *cli.Context) *transaction.PartialTransaction {
+	var ptx *transaction.PartialTransaction
+	for _, txn := range readTransactions(c) {
+		raw, err := common.HexStringToBytes(txn)
+		if err != nil || !transaction.IsPartialTransaction(raw) {
+			fmt.Fprintln(os.Stderr, "invalid partial transaction format")
 			os.Exit(1)
 		}
-		havesig, needsig, err := txn.ParseTransactionSig()
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "parsing transaction failed")
+		next := new(transaction.PartialTransaction)
+		if err := next.Deserialize(bytes.NewReader(raw)); err != nil {
+			fmt.Fprintln(os.Stderr, "invalid partial transaction:", err)
 			os.Exit(1)
 		}
+		if ptx == nil {
+			ptx = next
+		} else if err := ptx.Merge(next); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	return ptx
+}
+
+// writeTransaction prints a hex encoded transaction or writes it to the file
+// given with --output.
+func writeTransaction(c *cli.Context, txn string) error {
+	if output := c.String("output"); output != "" {
+		return ioutil.WriteFile(output, []byte(txn+"\n"), 0600)
+	}
+	fmt.Println(txn)
+	return nil
+}
+
+// writeResponse writes the transaction returned by the node with
+// writeTransaction, other responses are printed.
+func writeResponse(c *cli.Context, resp []byte) error {
+	var out struct {
+		Result interface{} `json:"result"`
+	}
+	if err := json.Unmarshal(resp, &out); err == nil {
+		if result, ok := out.Result.(string); ok && c.String("output") != "" {
+			if raw, err := common.HexStringToBytes(result); err == nil && transaction.IsPartialTransaction(raw) {
+				return writeTransaction(c, result)
+			}
+		}
+	}
+	return FormatOutput(resp)
+}
+
+func checkMultisigTransaction(c *cli.Context) error {
+	txns := readTransactions(c)
+	raw, err := common.HexStringToBytes(txns[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid transaction format")
+		os.Exit(1)
+	}
+	if transaction.IsPartialTransaction(raw) {
+		ptx := readPartialTransactions(c)
+		havesig, needsig := ptx.Signatures()
 		fmt.Println(fmt.Sprintf("[ %v/%v ] signature detected", havesig, havesig+needsig))
-	} else {
-		fmt.Fprintln(os.Stderr, "raw transaction is required with [--rawtxn]")
+		for i, o := range ptx.References {
+			address, _ := o.ProgramHash.ToAddress()
+			fmt.Println(fmt.Sprintf("input %v: %v of asset %x from %v", i, o.Value, o.AssetID.ToArrayReverse(), address))
+		}
+		for i, o := range ptx.Transaction.Outputs {
+			address, _ := o.ProgramHash.ToAddress()
+			fmt.Println(fmt.Sprintf("output %v: %v of asset %x to %v", i, o.Value, o.AssetID.ToArrayReverse(), address))
+		}
+		return nil
+	}
+	var txn transaction.Transaction
+	err = txn.Deserialize(bytes.NewReader(raw))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid transaction")
 		os.Exit(1)
 	}
+	havesig, needsig, err := txn.ParseTransactionSig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "parsing transaction failed")
+		os.Exit(1)
+	}
+	fmt.Println(fmt.Sprintf("[ %v/%v ] signature detected", havesig, havesig+needsig))
 
 	return nil
 }
 
 func signMultisigTransaction(c *cli.Context) error {
-	if rawtxn := c.String("rawtxn"); rawtxn != "" {
-		resp, err := rpc.Call(Address(), "signmultisigtransaction", 0, []interface{}{rawtxn})
+	if c.Bool("offline") {
+		ptx := readPartialTransactions(c)
+		wallet, err := account.Open(c.String("wallet"), WalletPassword(c.String("password")))
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, "Failed to open wallet: ", c.String("wallet"))
+			os.Exit(1)
+		}
+		if _, err := wallet.SignPartialTransaction(ptx); err != nil {
 			return err
 		}
-		FormatOutput(resp)
-	} else {
-		fmt.Fprintln(os.Stderr, "raw transaction is required with [--rawtxn]")
-		os.Exit(1)
+		return writeTransaction(c, common.BytesToHexString(ptx.ToArray()))
+	}
+
+	params := []interface{}{}
+	for _, txn := range readTransactions(c) {
+		params = append(params, txn)
+	}
+	resp, err := rpc.Call(Address(), "signmultisigtransaction", 0, params)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+
+	return writeResponse(c, resp)
+}
+
+func mergeMultisigTransaction(c *cli.Context) error {
+	ptx := readPartialTransactions(c)
+	return writeTransaction(c, common.BytesToHexString(ptx.ToArray()))
+}
+
+func sendMultisigTransaction(c *cli.Context) error {
+	ptx := readPartialTransactions(c)
+	txn, err := ptx.Finalize()
+	if err != nil {
+		return err
 	}
+	resp, err := rpc.Call(Address(), "sendrawtransaction", 0, []interface{}{common.BytesToHexString(txn.ToArray())})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	FormatOutput(resp)
 
 	return nil
 }
@@ -102,6 +219,10 @@ func multisigAction(c *cli.Context) error {
 		err = checkMultisigTransaction(c)
 	case c.Bool("sign"):
 		err = signMultisigTransaction(c)
+	case c.Bool("merge"):
+		err = mergeMultisigTransaction(c)
+	case c.Bool("send"):
+		err = sendMultisigTransaction(c)
 	default:
 		cli.ShowSubcommandHelp(c)
 		return nil
@@ -118,7 +239,7 @@ func multisigAction(c *cli.Context) error {
 func NewCommand() *cli.Command {
 	return &cli.Command{
 		Name:        "multisig",
-		Usage:       "multisig transaction creation, checking and sign",
+		Usage:       "multisig transaction creation, checking, sign and merge",
 		Description: "With nodectl multisig, you use multsig transation.",
 		ArgsUsage:   "[args]",
 		Flags: []cli.Flag{
@@ -134,9 +255,29 @@ func NewCommand() *cli.Command {
 				Name:  "sign, s",
 				Usage: "sign a multisig transaction",
 			},
+			cli.BoolFlag{
+				Name:  "merge, m",
+				Usage: "merge partially signed transactions of the same transaction",
+			},
+			cli.BoolFlag{
+				Name:  "send",
+				Usage: "send a completely signed partial transaction",
+			},
+			cli.BoolFlag{
+				Name:  "offline",
+				Usage: "sign with the local wallet instead of the node, with --sign",
+			},
 			cli.StringFlag{
 				Name:  "rawtxn",
-				Usage: "raw transaction",
+				Usage: "raw or partially signed transaction",
+			},
+			cli.StringSliceFlag{
+				Name:  "file",
+				Usage: "file holding a partially signed transaction, may be repeated",
+			},
+			cli.StringFlag{
+				Name:  "output, o",
+				Usage: "file to write the partially signed transaction to",
 			},
 			cli.StringFlag{
 				Name:  "wallet, w",
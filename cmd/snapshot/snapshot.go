@@ -0,0 +1,86 @@
+package snapshot
+
+import (
+	"fmt"
+	"os"
+
+	. "IPT/cmd/common"
+	"IPT/msg/rpc"
+
+	"github.com/urfave/cli"
+)
+
+func exportSnapshot(c *cli.Context) error {
+	height := c.Int64("height")
+	if height < 0 {
+		fmt.Fprintln(os.Stderr, "snapshot height is required with [--height]")
+		os.Exit(1)
+	}
+	file := c.String("file")
+	if file == "" {
+		fmt.Fprintln(os.Stderr, "snapshot file is required with [--file]")
+		os.Exit(1)
+	}
+	resp, err := rpc.Call(Address(), "exportsnapshot", 0, []interface{}{height, file})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	FormatOutput(resp)
+
+	return nil
+}
+
+func snapshotAction(c *cli.Context) error {
+	if c.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+
+	var err error
+	switch {
+	case c.Bool("export"):
+		err = exportSnapshot(c)
+	default:
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+func NewCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "snapshot",
+		Usage: "ledger state snapshot",
+		Description: "With nodectl snapshot, you could export the ledger state at a block height. " +
+			"A new node loads it by setting SnapshotFile in config.json. " +
+			"The node serves the export to nodectl on the same host when MaintenanceRPC is set in config.json.",
+		ArgsUsage: "[args]",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "export, e",
+				Usage: "export a snapshot on the node",
+			},
+			cli.Int64Flag{
+				Name:  "height",
+				Usage: "block height of the snapshot",
+				Value: -1,
+			},
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "new snapshot file path on the node",
+			},
+		},
+		Action: snapshotAction,
+		OnUsageError: func(c *cli.Context, err error, isSubcommand bool) error {
+			PrintError(c, err, "snapshot")
+			return cli.NewExitError("", 1)
+		},
+	}
+}
@@ -0,0 +1,160 @@
+package swap
+
+import (
+	"fmt"
+	"os"
+
+	. "IPT/cmd/common"
+	"IPT/msg/rpc"
+
+	"github.com/urfave/cli"
+)
+
+func parseContract(c *cli.Context) string {
+	code := c.String("contract")
+	if code == "" {
+		fmt.Println("missing flag [--contract]")
+		os.Exit(1)
+	}
+	return code
+}
+
+func parseTxid(c *cli.Context) string {
+	txid := c.String("txid")
+	if txid == "" {
+		fmt.Println("missing flag [--txid]")
+		os.Exit(1)
+	}
+	return txid
+}
+
+func call(method string, params []interface{}) error {
+	resp, err := rpc.Call(Address(), method, 0, params)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	FormatOutput(resp)
+
+	return nil
+}
+
+func initiateAction(c *cli.Context) error {
+	if c.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+	assetID := c.String("asset")
+	recipient := c.String("recipient")
+	value := c.String("value")
+	timeout := c.Int64("timeout")
+	if assetID == "" || recipient == "" || value == "" || timeout <= 0 {
+		fmt.Println("[--asset], [--recipient], [--value] and [--timeout] are required")
+		return nil
+	}
+	params := []interface{}{assetID, recipient, value, timeout, c.String("hash")}
+	if lock := c.String("hashlock"); lock != "" {
+		params = append(params, lock)
+	}
+
+	return call("initiateswap", params)
+}
+
+func redeemAction(c *cli.Context) error {
+	if c.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+	secret := c.String("secret")
+	if secret == "" {
+		fmt.Println("missing flag [--secret]")
+		return nil
+	}
+
+	return call("redeemswap", []interface{}{parseContract(c), parseTxid(c), c.Int("index"), secret})
+}
+
+func refundAction(c *cli.Context) error {
+	if c.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+
+	return call("refundswap", []interface{}{parseContract(c), parseTxid(c), c.Int("index")})
+}
+
+func fundingFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "contract, c",
+			Usage: "hex encoded swap contract returned by initiate",
+		},
+		cli.StringFlag{
+			Name:  "txid, t",
+			Usage: "transaction that funded the swap contract",
+		},
+		cli.IntFlag{
+			Name:  "index, i",
+			Usage: "output index of the swap contract in the funding transaction",
+		},
+	}
+}
+
+func NewCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "swap",
+		Usage:       "cross-chain atomic swaps with hash time-locked contracts",
+		Description: "With nodectl swap, you could lock asset to a hash time-locked contract, redeem it with the secret or refund it after timeout.",
+		ArgsUsage:   "[args]",
+		Subcommands: []cli.Command{
+			{
+				Name:  "initiate",
+				Usage: "lock asset to a swap contract paying to recipient",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "asset, a",
+						Usage: "uniq id for asset",
+					},
+					cli.StringFlag{
+						Name:  "recipient, r",
+						Usage: "hex encoded public key of the recipient",
+					},
+					cli.StringFlag{
+						Name:  "value, v",
+						Usage: "asset amount",
+					},
+					cli.Int64Flag{
+						Name:  "timeout",
+						Usage: "block height after which the sender could refund",
+					},
+					cli.StringFlag{
+						Name:  "hash",
+						Usage: "preimage hash function, sha256 or sm3",
+						Value: "sha256",
+					},
+					cli.StringFlag{
+						Name:  "hashlock",
+						Usage: "hash of the counterparty's secret, a new secret is generated if omitted",
+					},
+				},
+				Action: initiateAction,
+			},
+			{
+				Name:   "redeem",
+				Usage:  "spend a swap contract with its secret",
+				Flags:  append(fundingFlags(), cli.StringFlag{Name: "secret, s", Usage: "hex encoded preimage of the hashlock"}),
+				Action: redeemAction,
+			},
+			{
+				Name:   "refund",
+				Usage:  "take back a swap contract after its timeout",
+				Flags:  fundingFlags(),
+				Action: refundAction,
+			},
+		},
+		OnUsageError: func(c *cli.Context, err error, isSubcommand bool) error {
+			PrintError(c, err, "swap")
+			return cli.NewExitError("", 1)
+		},
+	}
+}
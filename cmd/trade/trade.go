@@ -0,0 +1,135 @@
+package trade
+
+import (
+	"fmt"
+	"os"
+
+	. "IPT/cmd/common"
+	"IPT/msg/rpc"
+
+	"github.com/urfave/cli"
+)
+
+func call(method string, params []interface{}) error {
+	resp, err := rpc.Call(Address(), method, 0, params)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	FormatOutput(resp)
+
+	return nil
+}
+
+func parseOffer(c *cli.Context) string {
+	offer := c.String("offer")
+	if offer == "" {
+		fmt.Println("missing flag [--offer]")
+		os.Exit(1)
+	}
+	return offer
+}
+
+func offerAction(c *cli.Context) error {
+	if c.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+	give := c.String("asset")
+	giveValue := c.String("value")
+	want := c.String("want")
+	wantValue := c.String("wantvalue")
+	if give == "" || giveValue == "" || want == "" || wantValue == "" {
+		fmt.Println("[--asset], [--value], [--want] and [--wantvalue] are required")
+		return nil
+	}
+
+	return call("maketradeoffer", []interface{}{give, giveValue, want, wantValue})
+}
+
+func acceptAction(c *cli.Context) error {
+	if c.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+
+	return call("accepttradeoffer", []interface{}{parseOffer(c)})
+}
+
+func signAction(c *cli.Context) error {
+	if c.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+	rawtxn := c.String("rawtxn")
+	if rawtxn == "" {
+		fmt.Println("missing flag [--rawtxn]")
+		return nil
+	}
+
+	return call("signtrade", []interface{}{parseOffer(c), rawtxn})
+}
+
+func NewCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "trade",
+		Usage:       "exchange of two assets in one transaction signed by both parties",
+		Description: "With nodectl trade, you could offer an asset for another one, accept an offer of a counterparty and sign the accepted trade.",
+		ArgsUsage:   "[args]",
+		Subcommands: []cli.Command{
+			{
+				Name:  "offer",
+				Usage: "create an unsigned offer of the wallet",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "asset, a",
+						Usage: "uniq id for the offered asset",
+					},
+					cli.StringFlag{
+						Name:  "value, v",
+						Usage: "offered amount",
+					},
+					cli.StringFlag{
+						Name:  "want",
+						Usage: "uniq id for the wanted asset",
+					},
+					cli.StringFlag{
+						Name:  "wantvalue",
+						Usage: "wanted amount",
+					},
+				},
+				Action: offerAction,
+			},
+			{
+				Name:  "accept",
+				Usage: "pay for an offer and sign it, the fee is paid by the accepting party",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "offer, o",
+						Usage: "offer created by the counterparty",
+					},
+				},
+				Action: acceptAction,
+			},
+			{
+				Name:  "sign",
+				Usage: "check an accepted trade against the offer, sign and send it",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "offer, o",
+						Usage: "offer created by the wallet",
+					},
+					cli.StringFlag{
+						Name:  "rawtxn",
+						Usage: "trade accepted by the counterparty",
+					},
+				},
+				Action: signAction,
+			},
+		},
+		OnUsageError: func(c *cli.Context, err error, isSubcommand bool) error {
+			PrintError(c, err, "trade")
+			return cli.NewExitError("", 1)
+		},
+	}
+}
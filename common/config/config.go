@@ -43,6 +43,39 @@ type Configuration struct {
 	MaxTxInBlock    int                `json:"MaxTransactionInBlock"`
 	MaxHdrSyncReqs  int                `json:"MaxConcurrentSyncHeaderReqs"`
 	TransactionFee  map[string]float64 `json:"TransactionFee"`
+	FeePerByte      float64            `json:"FeePerByte"`
+	SnapshotFile    string             `json:"SnapshotFile"`
+	StateRootHeight uint32             `json:"StateRootHeight"`
+	PruneBlocks     uint32             `json:"PruneBlocks"`
+	// blocks from this height on count every IssueAsset of an asset in the
+	// block towards its issued quantity, earlier blocks only the last one
+	IssueQuantityHeight uint32 `json:"IssueQuantityHeight"`
+	// transactions in blocks from this height on pay exactly their declared
+	// fee, earlier ones the implicit fee of TransactionFee["Transfer"]
+	DeclaredFeeHeight uint32 `json:"DeclaredFeeHeight"`
+	// assets registered with the Balance record type from this height on keep
+	// account balances instead of UTXOs
+	BalanceAssetHeight uint32 `json:"BalanceAssetHeight"`
+	// transactions in blocks from this height on sign the network magic
+	SigningMagicHeight uint32 `json:"SigningMagicHeight"`
+	// blocks less than this many blocks from SigningMagicHeight accept
+	// transactions signed with or without the network magic
+	SigningMagicGrace uint32 `json:"SigningMagicGrace"`
+	// transaction pool limits, unlimited when not positive
+	MaxPoolTransactions int `json:"MaxPoolTransactions"`
+	MaxPoolBytes        int `json:"MaxPoolBytes"`
+	MaxPoolTxPerSender  int `json:"MaxPoolTransactionsPerSender"`
+	// orphan transactions held for their parents, and for how many seconds
+	MaxOrphanTxns   int  `json:"MaxOrphanTransactions"`
+	OrphanTxTimeout uint `json:"OrphanTransactionTimeout"`
+	// file keeping the transaction pool across restarts, and how often it is
+	// saved in seconds
+	TxnPoolFile         string `json:"TransactionPoolFile"`
+	TxnPoolSaveInterval uint   `json:"TransactionPoolSaveInterval"`
+	// file of the consensus policy on the transactions put into blocks
+	PolicyFile string `json:"PolicyFile"`
+	// serve the JSON RPC maintenance methods, to local callers only
+	MaintenanceRPC bool `json:"MaintenanceRPC"`
 }
 
 type ConfigFile struct {
@@ -28,6 +28,14 @@ const (
 	ErrLockedAsset          ErrCode = 45013
 	ErrDuplicateLockAsset   ErrCode = 45014
 	ErrXmitFail             ErrCode = 45015
+	ErrInsufficientFee      ErrCode = 45016
+	ErrTransactionExpired   ErrCode = 45017
+	ErrBalanceInput         ErrCode = 45018
+	ErrFrozenAsset          ErrCode = 45019
+	ErrHTLC                 ErrCode = 45020
+	ErrTxPoolFull           ErrCode = 45021
+	ErrSenderQuota          ErrCode = 45022
+	ErrOrphanTransaction    ErrCode = 45023
 )
 
 func (err ErrCode) Error() string {
@@ -66,6 +74,22 @@ func (err ErrCode) Error() string {
 		return "duplicate locking asset transaction detected"
 	case ErrXmitFail:
 		return "transmit error"
+	case ErrInsufficientFee:
+		return "transaction fee below the minimum fee"
+	case ErrTransactionExpired:
+		return "transaction expired"
+	case ErrBalanceInput:
+		return "invalid balance input"
+	case ErrFrozenAsset:
+		return "asset frozen by its controller"
+	case ErrHTLC:
+		return "invalid hash time-locked contract spend"
+	case ErrTxPoolFull:
+		return "transaction pool is full"
+	case ErrSenderQuota:
+		return "sender exceeds its transaction pool quota"
+	case ErrOrphanTransaction:
+		return "transaction held until the transactions it spends arrive"
 	}
 
 	return fmt.Sprintf("Unknown error? Error code = %d", err)
@@ -86,3 +86,9 @@ func Uint256ParseFromBytes(f []byte) (Uint256, error) {
 	}
 	return Uint256(hash), nil
 }
+
+type Uint256Slice []Uint256
+
+func (u Uint256Slice) Len() int           { return len(u) }
+func (u Uint256Slice) Less(i, j int) bool { return u[i].CompareTo(u[j]) < 0 }
+func (u Uint256Slice) Swap(i, j int)      { u[i], u[j] = u[j], u[i] }
@@ -92,7 +92,7 @@ func (cxt *ConsensusContext) MakeHeader() *ledger.Block {
 			return nil
 		}
 		blockData := &ledger.Blockdata{
-			Version:          ContextVersion,
+			Version:          ledger.BlockVersionAt(cxt.Height),
 			PrevBlockHash:    cxt.PrevHash,
 			TransactionsRoot: txRoot,
 			Timestamp:        cxt.Timestamp,
@@ -100,6 +100,9 @@ func (cxt *ConsensusContext) MakeHeader() *ledger.Block {
 			ConsensusData:    cxt.Nonce,
 			NextBookKeeper:   cxt.NextBookKeeper,
 		}
+		if blockData.Version >= ledger.BlockVersionStateRoot {
+			blockData.StateRoot = ledger.DefaultLedger.Store.GetStateRoot()
+		}
 		cxt.header = &ledger.Block{
 			Blockdata:    blockData,
 			Transactions: []*tx.Transaction{},
@@ -20,6 +20,7 @@ import (
 	msg "IPT/msg/message"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"time"
 )
 
@@ -39,6 +40,7 @@ type DbftService struct {
 	logDictionary     string
 	started           bool
 	localNet          net.Neter
+	signedState       *SignedState // the last block signed, nil when unknown
 
 	newInventorySubscriber          events.Subscriber
 	blockPersistCompletedSubscriber events.Subscriber
@@ -55,6 +57,15 @@ func NewebftService(client cl.Client, logDictionary string, localNet net.Neter)
 		logDictionary: logDictionary,
 	}
 
+	// never sign a block conflicting with one signed before a restart
+	state, err := LoadSignedState(ds.signedStateFile())
+	if err != nil {
+		log.Error("[NewebftService] load the last signed block failed, no block will be signed: ", err)
+	} else {
+		log.Info(fmt.Sprintf("Last signed block %x at height %d view %d", state.BlockHash, state.Height, state.View))
+		ds.signedState = state
+	}
+
 	if !ds.timer.Stop() {
 		<-ds.timer.C
 	}
@@ -107,9 +118,54 @@ func (ds *DbftService) CheckExpectedView(viewNumber byte) {
 }
 
 func (ds *DbftService) CheckPolicy(transaction *tx.Transaction) error {
-	//TODO: CheckPolicy
+	return con.DefaultPolicy.Check(transaction)
+}
 
-	return nil
+// filterPolicy leaves out the transactions the policy excludes and those
+// spending their outputs.
+func (ds *DbftService) filterPolicy(txns []*tx.Transaction) []*tx.Transaction {
+	excluded := make(map[Uint256]bool)
+	allowed := []*tx.Transaction{}
+	for _, txn := range txns {
+		err := ds.CheckPolicy(txn)
+		for _, input := range txn.UTXOInputs {
+			if err == nil && excluded[input.ReferTxID] {
+				err = errors.New(fmt.Sprintf("spends excluded transaction %x", input.ReferTxID))
+			}
+		}
+		if err != nil {
+			log.Info(fmt.Sprintf("Transaction %x left out of the block by policy: %s", txn.Hash(), err))
+			excluded[txn.Hash()] = true
+			continue
+		}
+		allowed = append(allowed, txn)
+	}
+	return allowed
+}
+
+func (ds *DbftService) signedStateFile() string {
+	return filepath.Join(ds.logDictionary, SignedStateFile)
+}
+
+// signBlock signs block, the header of the context, for the bookkeeper. The
+// block is saved as the last signed one first, a block conflicting with the
+// one signed last at the height and view is refused.
+func (ds *DbftService) signBlock(block *ledger.Block, bookKeeper sig.Signer) ([]byte, error) {
+	if ds.signedState == nil {
+		return nil, errors.New("the last signed block is unknown")
+	}
+	hash := block.Hash()
+	height, view := ds.context.Height, ds.context.ViewNumber
+	if !ds.signedState.CanSign(height, view, hash) {
+		return nil, errors.New(fmt.Sprintf("block %x conflicts with block %x signed at height %d view %d",
+			hash, ds.signedState.BlockHash, ds.signedState.Height, ds.signedState.View))
+	}
+	state := &SignedState{Height: height, View: view, BlockHash: hash}
+	if err := state.Save(ds.signedStateFile()); err != nil {
+		return nil, err
+	}
+	ds.signedState = state
+	return sig.SignBySigner(block, bookKeeper)
 }
 
 func (ds *DbftService) CheckSignatures() error {
@@ -419,6 +475,20 @@ func (ds *DbftService) PrepareRequestReceived(payload *msg.ConsensusPayload, mes
 		log.Error("PrepareRequestReceived new transaction verification failed, will not sent Prepare Response", err)
 		return
 	}
+	if err := va.CheckTransactionOrder(ds.context.Transactions, ledger.DefaultLedger); err != nil {
+		log.Warn("PrepareRequestReceived failed, transaction before the one it spends from", err)
+		return
+	}
+	if err := va.CheckBookKeepingFees(ds.context.Transactions); err != nil {
+		log.Warn("PrepareRequestReceived failed, BookKeeping transaction does not collect the fees", err)
+		return
+	}
+	for _, txn := range ds.context.Transactions {
+		if err := ds.CheckPolicy(txn); err != nil {
+			log.Warn(fmt.Sprintf("PrepareRequestReceived failed, transaction %x denied by policy: %s", txn.Hash(), err))
+			return
+		}
+	}
 
 	log.Info("send prepare response")
 	ds.context.State |= SignatureSent
@@ -427,9 +497,9 @@ func (ds *DbftService) PrepareRequestReceived(payload *msg.ConsensusPayload, mes
 		log.Error("[DbftService] GetAccount failed")
 		return
 	}
-	ds.context.Signatures[ds.context.BookKeeperIndex], err = sig.SignBySigner(ds.context.MakeHeader(), bookKeeper)
+	ds.context.Signatures[ds.context.BookKeeperIndex], err = ds.signBlock(ds.context.MakeHeader(), bookKeeper)
 	if err != nil {
-		log.Error("[DbftService] SignBySigner failed")
+		log.Error("[DbftService] SignBySigner failed: ", err)
 		return
 	}
 	payload = ds.context.MakePrepareResponse(ds.context.Signatures[ds.context.BookKeeperIndex])
@@ -469,9 +539,9 @@ func (ds *DbftService) PrepareResponseReceived(payload *msg.ConsensusPayload, me
 	log.Info("Prepare Response finished")
 }
 
-func (ds *DbftService) RefreshPolicy() {
+func (ds *DbftService) RefreshPolicy() error {
 	log.Debug()
-	con.DefaultPolicy.Refresh()
+	return con.DefaultPolicy.Refresh()
 }
 
 func (ds *DbftService) RequestChangeView() {
@@ -560,26 +630,26 @@ func (ds *DbftService) Timeout() {
 			}
 
 			ds.context.Nonce = GetNonce()
-			transactionsPool := ds.localNet.GetTxnPool(true)
-			//TODO: add policy
-			//TODO: add max TX limitation
+			// the block takes the pool transactions with the highest fee rate,
+			// each after the pool transactions it spends from
+			transactionsPool := ds.filterPolicy(ds.localNet.GetTxnsForBlock(config.Parameters.MaxTxInBlock))
 
 			account, _ := ds.Client.GetAccount(ds.context.BookKeepers[ds.context.BookKeeperIndex]) //TODO: handle error
 			txnFeeOutputs := []*tx.TxOutput{}
-			// calculate transaction fee when fee is configured and doesn't equal to 0.0,
-			if fee, ok := config.Parameters.TransactionFee["Transfer"]; ok && (fee != 0.0) {
-				for _, txn := range transactionsPool {
-					txnResult, _ := txn.GetTransactionResults()
-					for assetID, value := range txnResult {
-						//TODO: check system assetID
-						if value > 0 {
-							tmpOutput := tx.TxOutput{
-								AssetID:     assetID,
-								Value:       value,
-								ProgramHash: account.ProgramHash,
-							}
-							txnFeeOutputs = append(txnFeeOutputs, &tmpOutput)
+			// the bookkeeper collects the fees, see va.CheckBookKeepingFees
+			for _, txn := range transactionsPool {
+				if txn.TxType == tx.IssueAsset {
+					continue
+				}
+				txnResult, _ := txn.PaidFees()
+				for assetID, value := range txnResult {
+					if value > 0 {
+						tmpOutput := tx.TxOutput{
+							AssetID:     assetID,
+							Value:       value,
+							ProgramHash: account.ProgramHash,
 						}
+						txnFeeOutputs = append(txnFeeOutputs, &tmpOutput)
 					}
 				}
 			}
@@ -594,7 +664,14 @@ func (ds *DbftService) Timeout() {
 			ds.context.header = nil
 			//build block and sign
 			block := ds.context.MakeHeader()
-			ds.context.Signatures[ds.context.BookKeeperIndex], _ = sig.SignBySigner(block, account)
+			signature, err := ds.signBlock(block, account)
+			if err != nil {
+				log.Error("[Timeout] sign the block failed: ", err)
+				ds.timer.Stop()
+				ds.timer.Reset(GenBlockTime << (ds.timeView + 1))
+				return
+			}
+			ds.context.Signatures[ds.context.BookKeeperIndex] = signature
 		}
 		payload := ds.context.MakePrepareRequest()
 		ds.SignAndRelay(payload)
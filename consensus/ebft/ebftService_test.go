@@ -0,0 +1,46 @@
+package ebft
+
+import (
+	"testing"
+
+	. "IPT/common"
+	con "IPT/consensus"
+	tx "IPT/core/transaction"
+	"IPT/core/transaction/payload"
+)
+
+func TestFilterPolicy(t *testing.T) {
+	defer func(policy *con.Policy) { con.DefaultPolicy = policy }(con.DefaultPolicy)
+	denied, allowed := Uint160{1}, Uint160{2}
+	con.DefaultPolicy = &con.Policy{PolicyLevel: con.DenyList, List: []Uint160{denied}}
+
+	parent := &tx.Transaction{
+		TxType:        tx.TransferAsset,
+		Payload:       &payload.TransferAsset{},
+		BalanceInputs: []*tx.BalanceTxInput{{Value: 2, ProgramHash: denied}},
+		Outputs:       []*tx.TxOutput{{Value: 2, ProgramHash: allowed}},
+	}
+	// the child spends from and pays to allowed accounts only
+	child := &tx.Transaction{
+		TxType:     tx.TransferAsset,
+		Payload:    &payload.TransferAsset{},
+		UTXOInputs: []*tx.UTXOTxInput{{ReferTxID: parent.Hash(), ReferTxOutputIndex: 0}},
+		Outputs:    []*tx.TxOutput{{Value: 2, ProgramHash: allowed}},
+	}
+	child.SetBlockTransactions(map[Uint256]*tx.Transaction{parent.Hash(): parent})
+	other := &tx.Transaction{
+		TxType:        tx.TransferAsset,
+		Payload:       &payload.TransferAsset{},
+		BalanceInputs: []*tx.BalanceTxInput{{Value: 1, ProgramHash: allowed}},
+		Outputs:       []*tx.TxOutput{{Value: 1, ProgramHash: Uint160{3}}},
+	}
+	if err := con.DefaultPolicy.Check(child); err != nil {
+		t.Fatal(err)
+	}
+
+	ds := &DbftService{}
+	txns := ds.filterPolicy([]*tx.Transaction{parent, child, other})
+	if len(txns) != 1 || txns[0] != other {
+		t.Fatalf("%d transactions left by the policy", len(txns))
+	}
+}
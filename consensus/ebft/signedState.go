@@ -0,0 +1,110 @@
+package ebft
+
+import (
+	. "IPT/common"
+	"IPT/common/serialization"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	SignedStateFile = "SignedState.dat"
+)
+
+// SignedState is the last block the bookkeeper signed. It is saved before the
+// signature leaves the node, so that after a restart the node does not sign a
+// different block for the same height and view.
+type SignedState struct {
+	Height    uint32
+	View      byte
+	BlockHash Uint256
+}
+
+// CanSign reports whether the block with hash may be signed at height and
+// view. Only a later height or view, or the very block signed last, may be.
+func (s *SignedState) CanSign(height uint32, view byte, hash Uint256) bool {
+	if height != s.Height {
+		return height > s.Height
+	}
+	if view != s.View {
+		return view > s.View
+	}
+	return hash == s.BlockHash
+}
+
+func (s *SignedState) Serialize(w io.Writer) error {
+	if err := serialization.WriteUint32(w, s.Height); err != nil {
+		return err
+	}
+	if err := serialization.WriteByte(w, s.View); err != nil {
+		return err
+	}
+	if _, err := s.BlockHash.Serialize(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SignedState) Deserialize(r io.Reader) error {
+	height, err := serialization.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+	s.Height = height
+
+	view, err := serialization.ReadByte(r)
+	if err != nil {
+		return err
+	}
+	s.View = view
+
+	return s.BlockHash.Deserialize(r)
+}
+
+// LoadSignedState reads the state saved in file, a node that never signed a
+// block has no file.
+func LoadSignedState(file string) (*SignedState, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return &SignedState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := new(SignedState)
+	if err := s.Deserialize(bufio.NewReader(f)); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid signed state in %s: %s", file, err))
+	}
+	return s, nil
+}
+
+// Save writes the state to file and syncs it to disk. The file is replaced at
+// once so that a crash while saving keeps the previous state.
+func (s *SignedState) Save(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	err = s.Serialize(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, file)
+}
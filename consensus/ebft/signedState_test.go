@@ -0,0 +1,58 @@
+package ebft
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "IPT/common"
+)
+
+func TestSignedState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signedstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "ebft", SignedStateFile)
+
+	state, err := LoadSignedState(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.CanSign(1, 0, Uint256{1}) {
+		t.Error("a node that never signed refuses the first block")
+	}
+
+	signed := &SignedState{Height: 10, View: 2, BlockHash: Uint256{1}}
+	if err := signed.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	state, err = LoadSignedState(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *state != *signed {
+		t.Fatalf("loaded %v, saved %v", state, signed)
+	}
+
+	cases := []struct {
+		height uint32
+		view   byte
+		hash   Uint256
+		ok     bool
+	}{
+		{10, 2, Uint256{1}, true},
+		{10, 2, Uint256{2}, false},
+		{10, 1, Uint256{2}, false},
+		{9, 5, Uint256{2}, false},
+		{10, 3, Uint256{2}, true},
+		{11, 0, Uint256{2}, true},
+	}
+	for _, c := range cases {
+		if state.CanSign(c.height, c.view, c.hash) != c.ok {
+			t.Errorf("CanSign(%d, %d, %x) should be %v", c.height, c.view, c.hash, c.ok)
+		}
+	}
+}
@@ -2,24 +2,145 @@ package consensus
 
 import (
 	. "IPT/common"
+	"IPT/common/config"
+	tx "IPT/core/transaction"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
 )
 
+const (
+	DefaultPolicyFile = "./policy.json"
+)
+
+// Policy decides which transactions the node puts into the blocks it proposes
+// and signs, by the addresses they spend from or pay to.
 type Policy struct {
+	sync.RWMutex
 	PolicyLevel PolicyLevel
 	List        []Uint160
 }
 
+// policyFile is the content of the policy file, with the level as its String
+// and the list as addresses.
+type policyFile struct {
+	PolicyLevel string   `json:"PolicyLevel"`
+	List        []string `json:"List"`
+}
+
 func NewPolicy() *Policy {
 	return &Policy{}
 }
 
-func (p *Policy) Refresh() {
-	//TODO: Refresh
+// PolicyFile returns the file the policy is loaded from.
+func PolicyFile() string {
+	if file := config.Parameters.PolicyFile; file != "" {
+		return file
+	}
+	return DefaultPolicyFile
+}
+
+// Refresh loads the policy again from the policy file. Without a policy file
+// all transactions are allowed, a file that fails to load keeps the current
+// policy.
+func (p *Policy) Refresh() error {
+	level, list, err := loadPolicy(PolicyFile())
+	if err != nil {
+		return err
+	}
+	p.Lock()
+	defer p.Unlock()
+	p.PolicyLevel = level
+	p.List = list
+	return nil
+}
+
+func loadPolicy(file string) (PolicyLevel, []Uint160, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return AllowAll, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	// Remove the UTF-8 Byte Order Mark
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	pf := policyFile{}
+	if err := json.Unmarshal(data, &pf); err != nil {
+		return 0, nil, err
+	}
+	level, ok := ParsePolicyLevel(strings.ToLower(pf.PolicyLevel))
+	if !ok {
+		return 0, nil, errors.New(fmt.Sprintf("unknown policy level %s", pf.PolicyLevel))
+	}
+	list := make([]Uint160, 0, len(pf.List))
+	for _, address := range pf.List {
+		programHash, err := ToScriptHash(address)
+		if err != nil {
+			return 0, nil, errors.New(fmt.Sprintf("invalid address %s in the policy", address))
+		}
+		list = append(list, programHash)
+	}
+	return level, list, nil
+}
+
+// Get returns the level and a copy of the list of the policy.
+func (p *Policy) Get() (PolicyLevel, []Uint160) {
+	p.RLock()
+	defer p.RUnlock()
+	return p.PolicyLevel, append([]Uint160{}, p.List...)
+}
+
+// Check returns an error when the policy excludes txn. Under AllowList every
+// address txn spends from or pays to has to be listed, under DenyList none
+// of them may be.
+func (p *Policy) Check(txn *tx.Transaction) error {
+	if txn.TxType == tx.BookKeeping {
+		return nil
+	}
+	p.RLock()
+	defer p.RUnlock()
+	switch p.PolicyLevel {
+	case AllowAll:
+		return nil
+	case DenyAll:
+		return errors.New("the policy denies all transactions")
+	}
+
+	addresses, err := txn.GetProgramHashes()
+	if err != nil {
+		return err
+	}
+	for _, output := range txn.Outputs {
+		addresses = append(addresses, output.ProgramHash)
+	}
+	for _, programHash := range addresses {
+		if p.listed(programHash) == (p.PolicyLevel == DenyList) {
+			return errors.New(fmt.Sprintf("the policy denies account %x", programHash))
+		}
+	}
+	return nil
+}
+
+func (p *Policy) listed(programHash Uint160) bool {
+	for _, h := range p.List {
+		if h == programHash {
+			return true
+		}
+	}
+	return false
 }
 
-var DefaultPolicy *Policy
+var DefaultPolicy = NewPolicy()
 
-func InitPolicy() {
-	DefaultPolicy := NewPolicy()
-	DefaultPolicy.Refresh()
+// InitPolicy loads the policy of the node from the policy file.
+func InitPolicy() error {
+	DefaultPolicy = NewPolicy()
+	return DefaultPolicy.Refresh()
 }
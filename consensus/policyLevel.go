@@ -8,3 +8,27 @@ const (
 	AllowList PolicyLevel = 0x02
 	DenyList PolicyLevel = 0x03
 )
+
+func (l PolicyLevel) String() string {
+	switch l {
+	case AllowAll:
+		return "allowall"
+	case DenyAll:
+		return "denyall"
+	case AllowList:
+		return "allowlist"
+	case DenyList:
+		return "denylist"
+	}
+	return "unknown"
+}
+
+// ParsePolicyLevel is the reverse of String.
+func ParsePolicyLevel(s string) (PolicyLevel, bool) {
+	for _, l := range []PolicyLevel{AllowAll, DenyAll, AllowList, DenyList} {
+		if l.String() == s {
+			return l, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,85 @@
+package consensus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "IPT/common"
+	tx "IPT/core/transaction"
+	"IPT/core/transaction/payload"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "policy.json")
+
+	if level, list, err := loadPolicy(file); err != nil || level != AllowAll || len(list) != 0 {
+		t.Fatalf("missing policy file: %v %v %v", level, list, err)
+	}
+
+	listed := Uint160{1}
+	address, err := listed.ToAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		content string
+		level   PolicyLevel
+		valid   bool
+	}{
+		{"\xef\xbb\xbf{\"PolicyLevel\": \"AllowList\", \"List\": [\"" + address + "\"]}", AllowList, true},
+		{"{\"PolicyLevel\": \"denylist\", \"List\": [\"" + address + "\"]}", DenyList, true},
+		{"{\"PolicyLevel\": \"denysome\", \"List\": []}", 0, false},
+		{"{\"PolicyLevel\": \"DenyList\", \"List\": [\"not an address\"]}", 0, false},
+	}
+	for _, c := range cases {
+		if err := ioutil.WriteFile(file, []byte(c.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		level, list, err := loadPolicy(file)
+		if !c.valid {
+			if err == nil {
+				t.Errorf("%s loaded", c.content)
+			}
+			continue
+		}
+		if err != nil || level != c.level || len(list) != 1 || list[0] != listed {
+			t.Errorf("%s loaded as %v %v %v", c.content, level, list, err)
+		}
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	from, to := Uint160{1}, Uint160{2}
+	txn := &tx.Transaction{
+		TxType:        tx.TransferAsset,
+		Payload:       &payload.TransferAsset{},
+		BalanceInputs: []*tx.BalanceTxInput{{Value: 1, ProgramHash: from}},
+		Outputs:       []*tx.TxOutput{{Value: 1, ProgramHash: to}},
+	}
+	cases := []struct {
+		level   PolicyLevel
+		list    []Uint160
+		allowed bool
+	}{
+		{AllowAll, nil, true},
+		{DenyAll, nil, false},
+		{AllowList, []Uint160{from, to}, true},
+		{AllowList, []Uint160{from}, false},
+		{DenyList, []Uint160{{3}}, true},
+		{DenyList, []Uint160{to}, false},
+		{DenyList, []Uint160{from}, false},
+	}
+	for _, c := range cases {
+		p := &Policy{PolicyLevel: c.level, List: c.list}
+		if err := p.Check(txn); (err == nil) != c.allowed {
+			t.Errorf("%s %v: %v", c.level, c.list, err)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"IPT/common/serialization"
 	"bytes"
 	"io"
+	"sort"
 )
 
 type AccountState struct {
@@ -27,7 +28,14 @@ func (accountState *AccountState) Serialize(w io.Writer) error {
 	accountState.ProgramHash.Serialize(w)
 	serialization.WriteBool(w, accountState.IsFrozen)
 	serialization.WriteUint64(w, uint64(len(accountState.Balances)))
-	for k, v := range accountState.Balances {
+	// sorted so every node stores the same bytes for the same balances
+	assetIds := make([]common.Uint256, 0, len(accountState.Balances))
+	for k := range accountState.Balances {
+		assetIds = append(assetIds, k)
+	}
+	sort.Sort(common.Uint256Slice(assetIds))
+	for _, k := range assetIds {
+		v := accountState.Balances[k]
 		k.Serialize(w)
 		v.Serialize(w)
 	}
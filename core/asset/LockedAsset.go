@@ -2,15 +2,85 @@ package asset
 
 import (
 	"io"
+	"math/big"
 
 	. "IPT/common"
 	"IPT/common/serialization"
 )
 
+// ReleaseType is how a locked amount vests between Start and Unlock.
+type ReleaseType byte
+
+const (
+	// the whole amount unlocks at Unlock
+	ReleaseCliff ReleaseType = 0x00
+	// the amount vests evenly at every height from Start to Unlock
+	ReleaseLinear ReleaseType = 0x01
+	// the amount vests in Steps equal parts from Start to Unlock
+	ReleaseStepped ReleaseType = 0x02
+)
+
+func (t ReleaseType) String() string {
+	switch t {
+	case ReleaseCliff:
+		return "cliff"
+	case ReleaseLinear:
+		return "linear"
+	case ReleaseStepped:
+		return "stepped"
+	}
+	return "unknown"
+}
+
+// ParseReleaseType is the reverse of String.
+func ParseReleaseType(s string) (ReleaseType, bool) {
+	for _, t := range []ReleaseType{ReleaseCliff, ReleaseLinear, ReleaseStepped} {
+		if t.String() == s {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// LockAsset is an amount locked at height Lock. Nothing vests before Cliff,
+// the rest of the schedule is given by Release.
 type LockAsset struct {
-	Lock   uint32
-	Unlock uint32
-	Amount Fixed64
+	Lock    uint32
+	Unlock  uint32
+	Amount  Fixed64
+	Start   uint32
+	Cliff   uint32
+	Release ReleaseType
+	Steps   uint32
+}
+
+// Unvested returns the part of the amount still locked at height.
+func (a *LockAsset) Unvested(height uint32) Fixed64 {
+	if height >= a.Unlock {
+		return 0
+	}
+	if a.Release == ReleaseCliff || height < a.Cliff || height <= a.Start {
+		return a.Amount
+	}
+
+	span := uint64(a.Unlock - a.Start)
+	elapsed := uint64(height - a.Start)
+	vested := big.NewInt(int64(a.Amount))
+	switch a.Release {
+	case ReleaseLinear:
+		vested.Mul(vested, new(big.Int).SetUint64(elapsed))
+		vested.Div(vested, new(big.Int).SetUint64(span))
+	case ReleaseStepped:
+		if a.Steps == 0 {
+			return a.Amount
+		}
+		steps := uint64(a.Steps) * elapsed / span
+		vested.Mul(vested, new(big.Int).SetUint64(steps))
+		vested.Div(vested, new(big.Int).SetUint64(uint64(a.Steps)))
+	default:
+		return a.Amount
+	}
+	return a.Amount - Fixed64(vested.Int64())
 }
 
 func (a *LockAsset) Serialize(w io.Writer) error {
@@ -23,11 +93,57 @@ func (a *LockAsset) Serialize(w io.Writer) error {
 	if err := a.Amount.Serialize(w); err != nil {
 		return err
 	}
+	if err := serialization.WriteUint32(w, a.Start); err != nil {
+		return err
+	}
+	if err := serialization.WriteUint32(w, a.Cliff); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte{byte(a.Release)}); err != nil {
+		return err
+	}
+	if err := serialization.WriteUint32(w, a.Steps); err != nil {
+		return err
+	}
 
 	return nil
 }
 
 func (a *LockAsset) Deserialize(r io.Reader) error {
+	if err := a.DeserializeCliff(r); err != nil {
+		return err
+	}
+
+	start, err := serialization.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+	a.Start = start
+
+	cliff, err := serialization.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+	a.Cliff = cliff
+
+	release, err := serialization.ReadBytes(r, 1)
+	if err != nil {
+		return err
+	}
+	a.Release = ReleaseType(release[0])
+
+	steps, err := serialization.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+	a.Steps = steps
+
+	return nil
+}
+
+// DeserializeCliff reads a lock stored before vesting schedules existed,
+// which unlocks at once.
+func (a *LockAsset) DeserializeCliff(r io.Reader) error {
 	startHeight, err := serialization.ReadUint32(r)
 	if err != nil {
 		return err
@@ -45,5 +161,10 @@ func (a *LockAsset) Deserialize(r io.Reader) error {
 		return err
 	}
 
+	a.Start = a.Lock
+	a.Cliff = a.Unlock
+	a.Release = ReleaseCliff
+	a.Steps = 0
+
 	return nil
 }
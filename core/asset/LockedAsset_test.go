@@ -0,0 +1,32 @@
+package asset
+
+import (
+	. "IPT/common"
+	"testing"
+)
+
+func TestUnvested(t *testing.T) {
+	linear := &LockAsset{Amount: 100, Start: 10, Cliff: 15, Unlock: 20, Release: ReleaseLinear}
+	stepped := &LockAsset{Amount: 100, Start: 10, Cliff: 10, Unlock: 20, Release: ReleaseStepped, Steps: 4}
+	cases := []struct {
+		lock     *LockAsset
+		height   uint32
+		unvested Fixed64
+	}{
+		{linear, 10, 100},
+		{linear, 14, 100},
+		{linear, 15, 50},
+		{linear, 19, 10},
+		{linear, 20, 0},
+		{stepped, 12, 100},
+		{stepped, 13, 75},
+		{stepped, 17, 50},
+		{stepped, 18, 25},
+		{stepped, 25, 0},
+	}
+	for _, c := range cases {
+		if got := c.lock.Unvested(c.height); got != c.unvested {
+			t.Errorf("%v lock at height %d: unvested %v, want %v", c.lock.Release, c.height, got, c.unvested)
+		}
+	}
+}
@@ -6,4 +6,5 @@ const (
 	SignatureContract ContractType = iota
 	MultiSigContract
 	CustomContract
+	HTLCContract
 )
@@ -121,6 +121,37 @@ func (c *Contract) IsMultiSigContract() bool {
 	return true
 }
 
+// SignerKeys returns the number of signatures a signature or multi-signature
+// contract needs and the encoded public keys it checks them against, in the
+// order of the code.
+func (c *Contract) SignerKeys() (int, [][]byte, error) {
+	if c.IsStandard() {
+		return 1, [][]byte{c.Code[1:34]}, nil
+	}
+	if !c.IsMultiSigContract() {
+		return 0, nil, errors.New("not a signature contract")
+	}
+	var m int
+	i := 0
+	switch c.Code[i] {
+	case 1:
+		m = int(c.Code[1])
+		i += 2
+	case 2:
+		m = int(BytesToInt16(c.Code[1:]))
+		i += 3
+	default:
+		m = int(c.Code[i]) - 80
+		i++
+	}
+	var keys [][]byte
+	for c.Code[i] == 33 {
+		keys = append(keys, c.Code[i+1:i+34])
+		i += 34
+	}
+	return m, keys, nil
+}
+
 func (c *Contract) GetType() ContractType {
 	if c.IsStandard() {
 		return SignatureContract
@@ -128,6 +159,9 @@ func (c *Contract) GetType() ContractType {
 	if c.IsMultiSigContract() {
 		return MultiSigContract
 	}
+	if c.IsHTLCContract() {
+		return HTLCContract
+	}
 	return CustomContract
 }
 
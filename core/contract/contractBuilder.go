@@ -6,6 +6,8 @@ import (
 	"IPT/crypto"
 	. "IPT/common/errors"
 	"IPT/contracts/vm/avm"
+	"encoding/binary"
+	"errors"
 	"math/big"
 	"sort"
 )
@@ -93,3 +95,59 @@ func CreateMultiSigRedeemScript(m int, pubkeys []*crypto.PubKey) ([]byte, error)
 	sb.AddOp(avm.CHECKMULTISIG)
 	return sb.ToArray(), nil
 }
+
+//create a hash time-locked contract paying recipient for the preimage of
+//hashLock up to height timeout and refunding sender after it
+func CreateHTLCContract(hashType HTLCHashType, hashLock []byte, timeout uint32, recipient *crypto.PubKey, sender *crypto.PubKey) (*Contract, error) {
+	code, err := CreateHTLCRedeemScript(hashType, hashLock, timeout, recipient, sender)
+	if err != nil {
+		return nil, err
+	}
+	programHash, err := ToCodeHash(code)
+	if err != nil {
+		return nil, NewDetailErr(err, ErrNoCode, "[Contract],CreateHTLCContract failed.")
+	}
+	return &Contract{
+		Code:        code,
+		Parameters:  []ContractParameterType{Signature, ByteArray, Boolean},
+		ProgramHash: programHash,
+	}, nil
+}
+
+func CreateHTLCRedeemScript(hashType HTLCHashType, hashLock []byte, timeout uint32, recipient *crypto.PubKey, sender *crypto.PubKey) ([]byte, error) {
+	if _, err := hashType.Hash(nil); err != nil {
+		return nil, NewDetailErr(err, ErrNoCode, "[Contract],CreateHTLCRedeemScript failed.")
+	}
+	if len(hashLock) != 32 {
+		return nil, NewDetailErr(errors.New("hash lock is not 32 bytes"), ErrNoCode, "[Contract],CreateHTLCRedeemScript failed.")
+	}
+	recipientKey, err := recipient.EncodePoint(true)
+	if err != nil {
+		return nil, NewDetailErr(err, ErrNoCode, "[Contract],CreateHTLCRedeemScript failed.")
+	}
+	senderKey, err := sender.EncodePoint(true)
+	if err != nil {
+		return nil, NewDetailErr(err, ErrNoCode, "[Contract],CreateHTLCRedeemScript failed.")
+	}
+	height := make([]byte, 4)
+	binary.LittleEndian.PutUint32(height, timeout)
+	return htlcScript(byte(hashType), hashLock, height, recipientKey, senderKey), nil
+}
+
+//create the parameter spending an HTLC with the preimage of its hash lock
+func CreateHTLCRedeemParameter(signature []byte, preimage []byte) []byte {
+	sb := pg.NewProgramBuilder()
+	sb.PushData(signature)
+	sb.PushData(preimage)
+	sb.AddOp(avm.DROP)
+	sb.AddOp(avm.PUSH1)
+	return sb.ToArray()
+}
+
+//create the parameter refunding an HTLC to its sender
+func CreateHTLCRefundParameter(signature []byte) []byte {
+	sb := pg.NewProgramBuilder()
+	sb.PushData(signature)
+	sb.AddOp(avm.PUSH0)
+	return sb.ToArray()
+}
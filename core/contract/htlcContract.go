@@ -0,0 +1,132 @@
+package contract
+
+import (
+	"IPT/contracts/vm/avm"
+	"IPT/crypto/sm3"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// HTLCHashType is the hash function a hash time-locked contract uses.
+type HTLCHashType byte
+
+const (
+	HTLCSha256 HTLCHashType = 0x00
+	HTLCSm3    HTLCHashType = 0x01
+)
+
+const (
+	HTLCPreimageLen = 32
+
+	// hash type, hash lock and timeout pushes, their drops, two branches of
+	// 33 byte public keys with CHECKSIG and two jumps
+	htlcScriptLen = 2 + 33 + 5 + 3 + 3 + 35 + 3 + 35
+
+	htlcRecipientOffset = 46
+	htlcSenderOffset    = 84
+	htlcSignatureLen    = 64
+)
+
+// HTLC is a hash time-locked contract. The recipient spends it with the
+// preimage of HashLock in a transaction valid up to Timeout, after Timeout
+// the sender can take it back.
+//
+// The VM checks the signature of the chosen branch. The preimage and the
+// heights are checked by the validation, the VM has neither SM3 nor the
+// block height.
+type HTLC struct {
+	HashType  HTLCHashType
+	HashLock  []byte
+	Timeout   uint32
+	Recipient []byte
+	Sender    []byte
+}
+
+// Hash returns the hash lock matching preimage.
+func (h HTLCHashType) Hash(preimage []byte) ([]byte, error) {
+	switch h {
+	case HTLCSha256:
+		sum := sha256.Sum256(preimage)
+		return sum[:], nil
+	case HTLCSm3:
+		sum := sm3.Sum(preimage)
+		return sum[:], nil
+	}
+	return nil, errors.New("unknown hash type")
+}
+
+// Unlocks reports whether preimage opens the hash lock.
+func (h *HTLC) Unlocks(preimage []byte) bool {
+	if len(preimage) != HTLCPreimageLen {
+		return false
+	}
+	hash, err := h.HashType.Hash(preimage)
+	return err == nil && bytes.Equal(hash, h.HashLock)
+}
+
+// ParseHTLC reads the HTLC of a script made by CreateHTLCRedeemScript.
+func ParseHTLC(code []byte) (*HTLC, error) {
+	if len(code) != htlcScriptLen || !bytes.Equal(code, htlcScript(code[1], code[3:35], code[36:40], code[htlcRecipientOffset+1:htlcRecipientOffset+34], code[htlcSenderOffset+1:htlcSenderOffset+34])) {
+		return nil, errors.New("not a hash time-locked contract")
+	}
+	h := &HTLC{
+		HashType:  HTLCHashType(code[1]),
+		HashLock:  code[3:35],
+		Timeout:   binary.LittleEndian.Uint32(code[36:40]),
+		Recipient: code[htlcRecipientOffset+1 : htlcRecipientOffset+34],
+		Sender:    code[htlcSenderOffset+1 : htlcSenderOffset+34],
+	}
+	if _, err := h.HashType.Hash(nil); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// ParseHTLCParameter reads the parameter script of a program spending an
+// HTLC, the preimage is nil for a refund.
+func ParseHTLCParameter(parameter []byte) (signature []byte, preimage []byte, err error) {
+	if len(parameter) < 1+htlcSignatureLen || parameter[0] != htlcSignatureLen {
+		return nil, nil, errors.New("invalid hash time-locked contract parameter")
+	}
+	signature = parameter[1 : 1+htlcSignatureLen]
+	rest := parameter[1+htlcSignatureLen:]
+	switch {
+	case len(rest) == 1 && rest[0] == byte(avm.PUSH0):
+		return signature, nil, nil
+	case len(rest) == 1+HTLCPreimageLen+2 && rest[0] == HTLCPreimageLen &&
+		rest[1+HTLCPreimageLen] == byte(avm.DROP) && rest[2+HTLCPreimageLen] == byte(avm.PUSH1):
+		return signature, rest[1 : 1+HTLCPreimageLen], nil
+	}
+	return nil, nil, errors.New("invalid hash time-locked contract parameter")
+}
+
+func htlcScript(hashType byte, hashLock []byte, timeout []byte, recipient []byte, sender []byte) []byte {
+	jump := func(op avm.OpCode, offset int16) []byte {
+		b := []byte{byte(op), 0, 0}
+		binary.LittleEndian.PutUint16(b[1:], uint16(offset))
+		return b
+	}
+	code := bytes.NewBuffer(nil)
+	code.Write([]byte{1, hashType})
+	code.WriteByte(32)
+	code.Write(hashLock)
+	code.WriteByte(4)
+	code.Write(timeout)
+	code.Write([]byte{byte(avm.DROP), byte(avm.DROP), byte(avm.DROP)})
+	code.Write(jump(avm.JMPIFNOT, htlcSenderOffset-(htlcRecipientOffset-3)))
+	code.WriteByte(33)
+	code.Write(recipient)
+	code.WriteByte(byte(avm.CHECKSIG))
+	code.Write(jump(avm.JMP, htlcScriptLen-(htlcSenderOffset-3)))
+	code.WriteByte(33)
+	code.Write(sender)
+	code.WriteByte(byte(avm.CHECKSIG))
+	return code.Bytes()
+}
+
+func (c *Contract) IsHTLCContract() bool {
+	_, err := ParseHTLC(c.Code)
+	return err == nil
+}
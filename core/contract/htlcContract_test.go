@@ -0,0 +1,47 @@
+package contract
+
+import (
+	"bytes"
+	"testing"
+
+	"IPT/crypto"
+)
+
+func TestHTLC(t *testing.T) {
+	crypto.SetAlg("P256R1")
+	_, recipient, _ := crypto.GenKeyPair()
+	_, sender, _ := crypto.GenKeyPair()
+	preimage := bytes.Repeat([]byte{0x5a}, HTLCPreimageLen)
+
+	for _, hashType := range []HTLCHashType{HTLCSha256, HTLCSm3} {
+		hashLock, _ := hashType.Hash(preimage)
+		c, err := CreateHTLCContract(hashType, hashLock, 1000, &recipient, &sender)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.GetType() != HTLCContract {
+			t.Fatalf("contract type %v, want HTLCContract", c.GetType())
+		}
+		h, err := ParseHTLC(c.Code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.HashType != hashType || h.Timeout != 1000 || !bytes.Equal(h.HashLock, hashLock) {
+			t.Fatalf("parsed %+v", h)
+		}
+		if !h.Unlocks(preimage) || h.Unlocks(hashLock) {
+			t.Fatal("hash lock opened by the wrong preimage")
+		}
+	}
+
+	sig := bytes.Repeat([]byte{0x01}, 64)
+	if s, p, err := ParseHTLCParameter(CreateHTLCRedeemParameter(sig, preimage)); err != nil || !bytes.Equal(s, sig) || !bytes.Equal(p, preimage) {
+		t.Fatal("redeem parameter does not round trip")
+	}
+	if s, p, err := ParseHTLCParameter(CreateHTLCRefundParameter(sig)); err != nil || !bytes.Equal(s, sig) || p != nil {
+		t.Fatal("refund parameter does not round trip")
+	}
+	if _, err := ParseHTLC(append([]byte{0x51}, make([]byte, 118)...)); err == nil {
+		t.Fatal("parsed a script that is not an HTLC")
+	}
+}
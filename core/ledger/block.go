@@ -19,6 +19,9 @@ import (
 const BlockVersion uint32 = 0
 const GenesisNonce uint64 = 2083236893
 
+// MaxBlockSize bounds the size of a serialized block read from a stream.
+const MaxBlockSize = 32 * 1024 * 1024
+
 type Block struct {
 	Blockdata    *Blockdata
 	Transactions []*tx.Transaction
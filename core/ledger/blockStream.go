@@ -0,0 +1,132 @@
+package ledger
+
+import (
+	. "IPT/common"
+	"IPT/common/serialization"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A block stream holds consecutive blocks for archival or for seeding a node
+// without P2P sync. It starts with magic, version, the first height and the
+// number of blocks. Each block follows as var bytes of Block.Serialize and
+// its hash.
+const BlockStreamVersion uint32 = 0x01
+
+var blockStreamMagic = []byte("IPTBLKS")
+
+// ExportBlocks writes the blocks from height from to height to of store to w.
+func ExportBlocks(store ILedgerStore, from uint32, to uint32, w io.Writer) error {
+	if from > to {
+		return errors.New(fmt.Sprintf("[ExportBlocks] invalid range %d to %d", from, to))
+	}
+	if to > store.GetHeight() {
+		return errors.New(fmt.Sprintf("[ExportBlocks] height %d is above the current block %d", to, store.GetHeight()))
+	}
+
+	if _, err := w.Write(blockStreamMagic); err != nil {
+		return err
+	}
+	serialization.WriteUint32(w, BlockStreamVersion)
+	serialization.WriteUint32(w, from)
+	if err := serialization.WriteUint32(w, to-from+1); err != nil {
+		return err
+	}
+
+	for h := from; h <= to; h++ {
+		hash, err := store.GetBlockHash(h)
+		if err != nil {
+			return err
+		}
+		block, err := store.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+		buf := bytes.NewBuffer(nil)
+		if err := block.Serialize(buf); err != nil {
+			return err
+		}
+		if err := serialization.WriteVarBytes(w, buf.Bytes()); err != nil {
+			return err
+		}
+		if _, err := hash.Serialize(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BlockStreamReader reads the blocks of a block stream in order.
+type BlockStreamReader struct {
+	r     io.Reader
+	From  uint32
+	Count uint32
+	read  uint32
+}
+
+func NewBlockStreamReader(r io.Reader) (*BlockStreamReader, error) {
+	magic, err := serialization.ReadBytes(r, uint64(len(blockStreamMagic)))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, blockStreamMagic) {
+		return nil, errors.New("[BlockStream] not a block stream")
+	}
+	version, err := serialization.ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if version != BlockStreamVersion {
+		return nil, errors.New(fmt.Sprintf("[BlockStream] unsupported block stream version %d", version))
+	}
+
+	s := &BlockStreamReader{r: r}
+	if s.From, err = serialization.ReadUint32(r); err != nil {
+		return nil, err
+	}
+	if s.Count, err = serialization.ReadUint32(r); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Next returns the next block, io.EOF after the last one.
+func (s *BlockStreamReader) Next() (*Block, error) {
+	if s.read == s.Count {
+		return nil, io.EOF
+	}
+
+	size, err := serialization.ReadVarUint(s.r, MaxBlockSize)
+	if err == serialization.ErrRange {
+		return nil, errors.New(fmt.Sprintf("[BlockStream] block at height %d is larger than %d bytes", s.From+s.read, MaxBlockSize))
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(s.r, data); err != nil {
+		return nil, err
+	}
+	var hash Uint256
+	if err := hash.Deserialize(s.r); err != nil {
+		return nil, err
+	}
+
+	block := new(Block)
+	if err := block.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	if block.Blockdata.Height != s.From+s.read {
+		return nil, errors.New(fmt.Sprintf("[BlockStream] block %d found at height %d", block.Blockdata.Height, s.From+s.read))
+	}
+	if block.Hash() != hash {
+		return nil, errors.New(fmt.Sprintf("[BlockStream] block %d does not match its hash", block.Blockdata.Height))
+	}
+	s.read++
+
+	return block, nil
+}
@@ -0,0 +1,142 @@
+package ledger
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	. "IPT/common"
+	"IPT/common/serialization"
+	"IPT/core/contract/program"
+	tx "IPT/core/transaction"
+	"IPT/core/transaction/payload"
+	"IPT/crypto"
+)
+
+// streamStore is a ledger store holding the blocks of a block stream.
+type streamStore struct {
+	ILedgerStore
+	blocks []*Block
+}
+
+func (s *streamStore) GetHeight() uint32 {
+	return uint32(len(s.blocks) - 1)
+}
+
+func (s *streamStore) GetBlockHash(height uint32) (Uint256, error) {
+	return s.blocks[height].Hash(), nil
+}
+
+func (s *streamStore) GetBlock(hash Uint256) (*Block, error) {
+	for _, b := range s.blocks {
+		if b.Hash() == hash {
+			return b, nil
+		}
+	}
+	return nil, io.EOF
+}
+
+func newStreamStore(t *testing.T, count int) *streamStore {
+	s := &streamStore{}
+	var prev Uint256
+	for h := 0; h < count; h++ {
+		b := &Block{
+			Blockdata: &Blockdata{
+				PrevBlockHash: prev,
+				Height:        uint32(h),
+				ConsensusData: uint64(h),
+				Program:       &program.Program{},
+			},
+			Transactions: []*tx.Transaction{{
+				TxType:  tx.BookKeeping,
+				Payload: &payload.BookKeeping{Nonce: uint64(h)},
+			}},
+		}
+		root, err := crypto.ComputeRoot([]Uint256{b.Transactions[0].Hash()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Blockdata.TransactionsRoot = root
+		prev = b.Hash()
+		s.blocks = append(s.blocks, b)
+	}
+	return s
+}
+
+func TestBlockStream(t *testing.T) {
+	store := newStreamStore(t, 5)
+	if err := ExportBlocks(store, 3, 5, bytes.NewBuffer(nil)); err == nil {
+		t.Fatal("exported blocks above the current block")
+	}
+	stream := bytes.NewBuffer(nil)
+	if err := ExportBlocks(store, 1, 3, stream); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewBlockStreamReader(bytes.NewReader(stream.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.From != 1 || r.Count != 3 {
+		t.Fatalf("stream of %d blocks from %d", r.Count, r.From)
+	}
+	for h := 1; h <= 3; h++ {
+		b, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Hash() != store.blocks[h].Hash() {
+			t.Fatalf("block %d read back as %x", h, b.Hash())
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("read past the last block: %v", err)
+	}
+}
+
+func TestBadBlockStream(t *testing.T) {
+	store := newStreamStore(t, 3)
+	stream := bytes.NewBuffer(nil)
+	if err := ExportBlocks(store, 1, 1, stream); err != nil {
+		t.Fatal(err)
+	}
+	good := stream.Bytes()
+
+	// corrupt returns the stream with its bytes from offset replaced
+	corrupt := func(offset int, data ...byte) []byte {
+		bad := append([]byte{}, good...)
+		copy(bad[offset:], data)
+		return bad
+	}
+	cases := []struct {
+		name   string
+		stream []byte
+	}{
+		{"magic", corrupt(0, 'X')},
+		{"version", corrupt(len(blockStreamMagic), 2)},
+		{"height", corrupt(len(blockStreamMagic)+4, 2)},
+		{"hash", corrupt(len(good)-1, good[len(good)-1]+1)},
+		{"truncated", good[:len(good)-40]},
+	}
+	for _, c := range cases {
+		r, err := NewBlockStreamReader(bytes.NewReader(c.stream))
+		if err == nil {
+			_, err = r.Next()
+		}
+		if err == nil {
+			t.Errorf("stream with bad %s read", c.name)
+		}
+	}
+
+	// a frame larger than a block is refused before it is read
+	header := len(blockStreamMagic) + 12
+	oversize := bytes.NewBuffer(append([]byte{}, good[:header]...))
+	serialization.WriteVarUint(oversize, MaxBlockSize+1)
+	r, err := NewBlockStreamReader(oversize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err == nil || err == io.EOF || err == io.ErrUnexpectedEOF {
+		t.Fatalf("oversized block: %v", err)
+	}
+}
@@ -53,6 +53,20 @@ func (bc *Blockchain) AddBlock(block *Block) error {
 	return nil
 }
 
+// RollbackTo removes the blocks above height from the store and moves the
+// local chain tip back to height.
+func (bc *Blockchain) RollbackTo(height uint32) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if err := DefaultLedger.Store.RollbackTo(height); err != nil {
+		return NewDetailErr(err, ErrNoCode, "[Blockchain], RollbackTo failed.")
+	}
+	bc.BlockHeight = DefaultLedger.Store.GetHeight()
+
+	return nil
+}
+
 func (bc *Blockchain) GetHeader(hash Uint256) (*Header, error) {
 	header, err := DefaultLedger.Store.GetHeader(hash)
 	if err != nil {
@@ -75,7 +89,7 @@ func (bc *Blockchain) SaveBlock(block *Block) error {
 func (bc *Blockchain) ContainsTransaction(hash Uint256) bool {
 	//TODO: implement error catch
 	_, err := DefaultLedger.Store.GetTransaction(hash)
-	if err != nil {
+	if err != nil && err != ErrPruned {
 		return false
 	}
 	return true
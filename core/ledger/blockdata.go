@@ -2,6 +2,7 @@ package ledger
 
 import (
 	. "IPT/common"
+	"IPT/common/config"
 	"IPT/common/serialization"
 	"IPT/core/contract/program"
 	sig "IPT/core/signature"
@@ -12,6 +13,18 @@ import (
 	"io"
 )
 
+// Blocks from BlockVersionStateRoot on carry the state root left by the
+// previous block.
+const BlockVersionStateRoot uint32 = 1
+
+// BlockVersionAt returns the version a block at height must have.
+func BlockVersionAt(height uint32) uint32 {
+	if config.Parameters.StateRootHeight > 0 && height >= config.Parameters.StateRootHeight {
+		return BlockVersionStateRoot
+	}
+	return BlockVersion
+}
+
 type Blockdata struct {
 	Version          uint32
 	PrevBlockHash    Uint256
@@ -20,6 +33,7 @@ type Blockdata struct {
 	Height           uint32
 	ConsensusData    uint64
 	NextBookKeeper   Uint160
+	StateRoot        Uint256
 	Program          *program.Program
 
 	hash Uint256
@@ -44,6 +58,9 @@ func (bd *Blockdata) SerializeUnsigned(w io.Writer) error {
 	serialization.WriteUint32(w, bd.Height)
 	serialization.WriteUint64(w, bd.ConsensusData)
 	bd.NextBookKeeper.Serialize(w)
+	if bd.Version >= BlockVersionStateRoot {
+		bd.StateRoot.Serialize(w)
+	}
 	return nil
 }
 
@@ -109,6 +126,14 @@ func (bd *Blockdata) DeserializeUnsigned(r io.Reader) error {
 	//NextBookKeeper
 	bd.NextBookKeeper.Deserialize(r)
 
+	//StateRoot
+	if bd.Version >= BlockVersionStateRoot {
+		err = bd.StateRoot.Deserialize(r)
+		if err != nil {
+			return NewDetailErr(err, ErrNoCode, "Blockdata item StateRoot Deserialize failed.")
+		}
+	}
+
 	return nil
 }
 
@@ -3,6 +3,7 @@ package ledger
 import (
 	"IPT/common"
 	. "IPT/common"
+	"IPT/common/config"
 	"IPT/core/asset"
 	"IPT/core/contract"
 	tx "IPT/core/transaction"
@@ -21,6 +22,14 @@ type Ledger struct {
 	Store      ILedgerStore
 }
 
+// IsBalanceModel reports whether an asset of recordType registered at height
+// keeps account balances instead of UTXOs. Assets registered as Balance
+// before BalanceAssetHeight keep UTXOs.
+func IsBalanceModel(recordType asset.AssetRecordType, height uint32) bool {
+	return recordType == asset.Balance &&
+		config.Parameters.BalanceAssetHeight > 0 && height >= config.Parameters.BalanceAssetHeight
+}
+
 //check weather the transaction contains the doubleSpend.
 func (l *Ledger) IsDoubleSpend(Tx *tx.Transaction) bool {
 	return DefaultLedger.Store.IsDoubleSpend(Tx)
@@ -7,8 +7,13 @@ import (
 	tx "IPT/core/transaction"
 	"IPT/crypto"
 	"IPT/contracts/states"
+	"errors"
+	"io"
 )
 
+// ErrPruned is returned for a transaction body a pruned node has discarded.
+var ErrPruned = errors.New("transaction body pruned on this node")
+
 // ILedgerStore provides func with store package.
 type ILedgerStore interface {
 	//TODO: define the state store func
@@ -24,13 +29,16 @@ type ILedgerStore interface {
 	GetHeader(hash Uint256) (*Header, error)
 
 	GetTransaction(hash Uint256) (*tx.Transaction, error)
+	GetTransactionHeight(hash Uint256) (uint32, error)
 
 	SaveAsset(assetid Uint256, asset *Asset) error
 	GetAsset(hash Uint256) (*Asset, error)
+	IsBalanceAsset(assetId Uint256) bool
 
 	GetContract(codeHash Uint160) ([]byte, error)
 	GetStorage(key []byte) ([]byte, error)
 	GetAccount(programHash Uint160) (*account.AccountState, error)
+	GetAccountNonce(programHash Uint160) (uint64, error)
 	GetAssetState(assetId Uint256) (*states.AssetState, error)
 
 	GetCurrentBlockHash() Uint256
@@ -40,6 +48,8 @@ type ILedgerStore interface {
 	GetHeaderHashByHeight(height uint32) Uint256
 
 	GetBookKeeperList() ([]*crypto.PubKey, []*crypto.PubKey, error)
+	GetStateRoot() Uint256
+	GetStateProof(key []byte) ([]byte, uint32, Uint256, *crypto.StateProof, error)
 	InitLedgerStoreWithGenesisBlock(genesisblock *Block, defaultBookKeeper []*crypto.PubKey) (uint32, error)
 
 	GetQuantityIssued(assetid Uint256) (Fixed64, error)
@@ -50,12 +60,18 @@ type ILedgerStore interface {
 	GetUnspentsFromProgramHash(programHash Uint160) (map[Uint256][]*tx.UTXOUnspent, error)
 	GetLockedFromProgramHash(programHash Uint160, assetid Uint256) ([]*LockAsset, error)
 	GetAvailableAsset(programHash Uint160, assetid Uint256) (Fixed64, Fixed64, error)
+	IsFrozen(assetid Uint256, programHash Uint160) (bool, error)
+	GetFrozenAddresses(assetid Uint256) ([]Uint160, error)
 	GetAssets() map[Uint256]*Asset
+	GetTxHistory(programHash Uint160, assetId *Uint256, direction tx.TxDirection, txType *tx.TransactionType, cursor []byte, limit int) ([]*tx.TxHistory, []byte, error)
 
 	SaveRecord(hash string, txhash Uint256) error
 	GetRecord(filehash string) (Uint256, error)
 
 	IsTxHashDuplicate(txhash Uint256) bool
 	IsBlockInStore(hash Uint256) bool
+	RollbackTo(height uint32) error
+	ExportSnapshot(height uint32, w io.Writer) error
+	VerifyLedger(repair bool) ([]string, error)
 	Close()
 }
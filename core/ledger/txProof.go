@@ -0,0 +1,62 @@
+package ledger
+
+import (
+	. "IPT/common"
+	"IPT/crypto"
+	"bytes"
+	"errors"
+)
+
+// TxProof anchors a transaction in a block, it is the proof returned by the
+// gettxproof methods. RawHeader is the serialized block header with its
+// signatures, Branch holds the Merkle siblings from the transaction up to
+// TransactionsRoot. Hashes are hex strings in display order.
+type TxProof struct {
+	Txid      string
+	BlockHash string
+	RawHeader string
+	TxIndex   uint32
+	Branch    []string
+}
+
+// VerifyTxProof checks offline that the transaction of proof is included in
+// the block header it carries and returns that header. Checking the header
+// signatures against the known bookkeepers is left to the caller.
+func VerifyTxProof(proof *TxProof) (*Blockdata, error) {
+	raw, err := HexStringToBytes(proof.RawHeader)
+	if err != nil {
+		return nil, err
+	}
+	header := new(Blockdata)
+	if err := header.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	blockHash := header.Hash()
+	if BytesToHexString(blockHash.ToArrayReverse()) != proof.BlockHash {
+		return nil, errors.New("block hash does not match the header")
+	}
+
+	bys, err := HexStringToBytesReverse(proof.Txid)
+	if err != nil {
+		return nil, err
+	}
+	txid, err := Uint256ParseFromBytes(bys)
+	if err != nil {
+		return nil, err
+	}
+	merkleProof := &crypto.MerkleProof{Index: proof.TxIndex, Branch: make([]Uint256, len(proof.Branch))}
+	for i, h := range proof.Branch {
+		bys, err := HexStringToBytesReverse(h)
+		if err != nil {
+			return nil, err
+		}
+		if merkleProof.Branch[i], err = Uint256ParseFromBytes(bys); err != nil {
+			return nil, err
+		}
+	}
+	if !crypto.VerifyMerkleProof(txid, merkleProof, header.TransactionsRoot) {
+		return nil, errors.New("transaction is not included in the block")
+	}
+
+	return header, nil
+}
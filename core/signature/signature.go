@@ -2,7 +2,9 @@ package signature
 
 import (
 	"IPT/common"
+	"IPT/common/config"
 	"IPT/common/log"
+	"IPT/common/serialization"
 	"IPT/core/contract/program"
 	"IPT/crypto"
 	. "IPT/common/errors"
@@ -10,8 +12,17 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"io"
+	"math"
 )
 
+// SigningHeight returns the height of the block a transaction signed now is
+// expected in, which selects the data it signs. Nodes set it to the block
+// after their ledger, nodectl to its --signing-height or the next block of
+// the node it talks to. Other signers sign as if SigningMagicHeight had passed.
+var SigningHeight = func() uint32 {
+	return math.MaxUint32
+}
+
 //SignableData describe the data need be signed.
 type SignableData interface {
 	interfaces.ICodeContainer
@@ -43,15 +54,64 @@ func GetHashData(data SignableData) []byte {
 	return b_buf.Bytes()
 }
 
+// NetworkBoundAt reports whether transaction signatures in a block at height
+// cover the network magic, so that they are not valid on other networks.
+func NetworkBoundAt(height uint32) bool {
+	return config.Parameters.SigningMagicHeight > 0 && height >= config.Parameters.SigningMagicHeight
+}
+
+// GetHashDataAt returns the data a transaction in a block at height is signed
+// over, prefixed with the network magic from SigningMagicHeight on.
+func GetHashDataAt(data SignableData, height uint32) []byte {
+	if !NetworkBoundAt(height) {
+		return GetHashData(data)
+	}
+	b_buf := new(bytes.Buffer)
+	serialization.WriteUint32(b_buf, uint32(config.Parameters.Magic))
+	data.SerializeUnsigned(b_buf)
+	return b_buf.Bytes()
+}
+
+// SigningHeights returns the heights whose signing data the transactions in a
+// block at height may sign, within SigningMagicGrace blocks of
+// SigningMagicHeight both with and without the network magic.
+func SigningHeights(height uint32) []uint32 {
+	activation := uint64(config.Parameters.SigningMagicHeight)
+	grace := uint64(config.Parameters.SigningMagicGrace)
+	if activation == 0 || uint64(height)+grace <= activation || uint64(height) >= activation+grace {
+		return []uint32{height}
+	}
+	if uint64(height) >= activation {
+		return []uint32{height, uint32(activation - 1)}
+	}
+	return []uint32{height, uint32(activation)}
+}
+
+// signedAt is data signed for the block at height.
+type signedAt struct {
+	SignableData
+	height uint32
+}
+
+func (d *signedAt) GetMessage() []byte {
+	return GetHashDataAt(d.SignableData, d.height)
+}
+
+// AtHeight returns data signing the data of a transaction in the block at
+// height, whatever SigningHeight returns.
+func AtHeight(data SignableData, height uint32) SignableData {
+	return &signedAt{data, height}
+}
+
 func GetHashForSigning(data SignableData) []byte {
-	//TODO: GetHashForSigning
-	temp := sha256.Sum256(GetHashData(data))
+	temp := sha256.Sum256(data.GetMessage())
 	return temp[:]
 }
 
+// Sign signs the message of data, which is what the VM verifies.
 func Sign(data SignableData, prikey []byte) ([]byte, error) {
 	// FIXME ignore the return error value
-	signature, err := crypto.Sign(prikey, GetHashData(data))
+	signature, err := crypto.Sign(prikey, data.GetMessage())
 	if err != nil {
 		return nil, NewDetailErr(err, ErrNoCode, "[Signature],Sign failed.")
 	}
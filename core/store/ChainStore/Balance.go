@@ -0,0 +1,58 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	"IPT/common/serialization"
+	"IPT/core/asset"
+	. "IPT/core/ledger"
+	. "IPT/core/store"
+	"bytes"
+)
+
+// Outputs of a balance-model asset credit the account balance of their
+// program hash and create no UTXO, balance inputs debit it. ST_Nonce holds
+// the next balance input nonce of each account.
+
+func nonceKey(programHash Uint160) []byte {
+	return append([]byte{byte(ST_Nonce)}, programHash.ToArray()...)
+}
+
+// GetAccountNonce returns the lowest nonce the next balance input of the
+// account may use.
+func (bd *ChainStore) GetAccountNonce(programHash Uint160) (uint64, error) {
+	data, err := bd.st.Get(nonceKey(programHash))
+	if err != nil {
+		if err.Error() == ErrDBNotFound.Error() {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return serialization.ReadUint64(bytes.NewReader(data))
+}
+
+func (bd *ChainStore) saveAccountNonce(programHash Uint160, nonce uint64) error {
+	value := bytes.NewBuffer(nil)
+	serialization.WriteUint64(value, nonce)
+	return bd.batchPut(nonceKey(programHash), value.Bytes())
+}
+
+// IsBalanceAsset reports whether assetId is a balance-model asset.
+func (bd *ChainStore) IsBalanceAsset(assetId Uint256) bool {
+	a, err := bd.GetAsset(assetId)
+	if err != nil || a.RecordType != asset.Balance {
+		return false
+	}
+	height, err := bd.GetTransactionHeight(assetId)
+	return err == nil && IsBalanceModel(a.RecordType, height)
+}
+
+// isBalanceAsset is IsBalanceAsset, known caches the answers during one
+// persist.
+func (bd *ChainStore) isBalanceAsset(assetId Uint256, known map[Uint256]bool) bool {
+	if balance, ok := known[assetId]; ok {
+		return balance
+	}
+	balance := bd.IsBalanceAsset(assetId)
+	known[assetId] = balance
+	return balance
+}
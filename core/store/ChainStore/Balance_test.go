@@ -0,0 +1,58 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	"IPT/common/config"
+	"IPT/core/asset"
+	tx "IPT/core/transaction"
+	"IPT/core/transaction/payload"
+	"testing"
+)
+
+func TestBalanceAsset(t *testing.T) {
+	defer func(height uint32) { config.Parameters.BalanceAssetHeight = height }(config.Parameters.BalanceAssetHeight)
+	bd, bookKeeper := newTestChainStore(t)
+	defer bd.Close()
+
+	// registered before BalanceAssetHeight, the asset keeps UTXOs
+	config.Parameters.BalanceAssetHeight = bd.GetHeight() + 2
+	legacyID := registerAsset(t, bd, bookKeeper, "legacy points", asset.Balance)
+	if bd.IsBalanceAsset(legacyID) {
+		t.Fatal("asset registered before BalanceAssetHeight keeps balances")
+	}
+	assetID := registerAsset(t, bd, bookKeeper, "points", asset.Balance)
+	from, to := Uint160{1}, Uint160{2}
+
+	issue := &tx.Transaction{
+		TxType:  tx.IssueAsset,
+		Payload: &payload.IssueAsset{},
+		Outputs: []*tx.TxOutput{{AssetID: assetID, Value: 100, ProgramHash: from}},
+	}
+	mustPersist(t, bd, issue)
+	if unspents, _ := bd.GetUnspentFromProgramHash(from, assetID); len(unspents) != 0 {
+		t.Fatal("balance-model output created an UTXO")
+	}
+
+	spend := &tx.Transaction{
+		TxType:         tx.TransferAsset,
+		PayloadVersion: payload.TransferAssetBalancePayloadVersion,
+		Payload:        &payload.TransferAsset{},
+		BalanceInputs:  []*tx.BalanceTxInput{{AssetID: assetID, Value: 30, ProgramHash: from, Nonce: 4}},
+		Outputs:        []*tx.TxOutput{{AssetID: assetID, Value: 30, ProgramHash: to}},
+	}
+	mustPersist(t, bd, spend)
+
+	if total, _, err := bd.GetAvailableAsset(from, assetID); err != nil || total != 70 {
+		t.Fatalf("sender balance %v, %v", total, err)
+	}
+	if total, _, err := bd.GetAvailableAsset(to, assetID); err != nil || total != 30 {
+		t.Fatalf("receiver balance %v, %v", total, err)
+	}
+	if nonce, err := bd.GetAccountNonce(from); err != nil || nonce != 5 {
+		t.Fatalf("account nonce %d, %v", nonce, err)
+	}
+
+	if err := bd.persist(nextBlock(t, bd, spend)); err == nil {
+		t.Fatal("used nonce spent again")
+	}
+}
@@ -0,0 +1,43 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	"IPT/core/asset"
+	tx "IPT/core/transaction"
+	"IPT/core/transaction/payload"
+	"testing"
+)
+
+func TestBurnAsset(t *testing.T) {
+	bd, bookKeeper := newTestChainStore(t)
+	defer bd.Close()
+
+	assetID := registerAsset(t, bd, bookKeeper, "coin", asset.UTXO)
+	holder := Uint160{1}
+
+	issue := &tx.Transaction{
+		TxType:  tx.IssueAsset,
+		Payload: &payload.IssueAsset{},
+		Outputs: []*tx.TxOutput{{AssetID: assetID, Value: 100, ProgramHash: holder}},
+	}
+	mustPersist(t, bd, issue)
+
+	burn, _ := tx.NewBurnAssetTransaction(
+		[]*tx.UTXOTxInput{{ReferTxID: issue.Hash(), ReferTxOutputIndex: 0}},
+		[]*tx.TxOutput{{AssetID: assetID, Value: 60, ProgramHash: holder}},
+		assetID, 40)
+	mustPersist(t, bd, burn)
+
+	if issued, err := bd.GetQuantityIssued(assetID); err != nil || issued != 60 {
+		t.Fatalf("issued quantity %v, %v", issued, err)
+	}
+	if total, _, err := bd.GetAvailableAsset(holder, assetID); err != nil || total != 60 {
+		t.Fatalf("holder balance %v, %v", total, err)
+	}
+	if err := bd.RollbackTo(bd.GetHeight() - 1); err != nil {
+		t.Fatal(err)
+	}
+	if issued, err := bd.GetQuantityIssued(assetID); err != nil || issued != 100 {
+		t.Fatalf("issued quantity after rollback %v, %v", issued, err)
+	}
+}
@@ -2,6 +2,7 @@ package ChainStore
 
 import (
 	. "IPT/common"
+	"IPT/common/config"
 	"IPT/common/log"
 	"IPT/common/serialization"
 	"IPT/contracts"
@@ -51,6 +52,10 @@ type persistBlockTask struct {
 	block  *Block
 	ledger *Ledger
 }
+type rollbackTask struct {
+	height uint32
+	done   chan error
+}
 
 type ChainStore struct {
 	st IStore
@@ -65,6 +70,10 @@ type ChainStore struct {
 
 	currentBlockHeight uint32
 	storedHeaderCount  uint32
+
+	undo         *undoJournal      // only set while persist is running
+	stateChanges map[string][]byte // only set while persist is running
+	stateRoot    Uint256
 }
 
 func NewStore(file string) (IStore, error) {
@@ -80,6 +89,13 @@ func NewLedgerStore() (ILedgerStore, error) {
 		return nil, err
 	}
 
+	if file := config.Parameters.SnapshotFile; file != "" {
+		if err := cs.importSnapshotFile(file); err != nil {
+			cs.Close()
+			return nil, err
+		}
+	}
+
 	return cs, nil
 }
 
@@ -90,6 +106,12 @@ func NewChainStore(file string) (*ChainStore, error) {
 		return nil, err
 	}
 
+	return NewChainStoreWithStore(st)
+}
+
+// NewChainStoreWithStore builds the ledger store on top of st, such as a
+// MemStore in tests.
+func NewChainStoreWithStore(st IStore) (*ChainStore, error) {
 	chain := &ChainStore{
 		st:                 st,
 		headerIndex:        map[uint32]Uint256{},
@@ -101,6 +123,11 @@ func NewChainStore(file string) (*ChainStore, error) {
 		quit:               make(chan chan bool, 1),
 	}
 
+	if err := chain.upgradeSchema(); err != nil {
+		st.Close()
+		return nil, err
+	}
+
 	go chain.loop()
 
 	return chain, nil
@@ -129,6 +156,18 @@ func (self *ChainStore) loop() {
 				self.handlePersistBlockTask(task.block, task.ledger)
 				tcall := float64(time.Now().Sub(now)) / float64(time.Second)
 				log.Debugf("handle block exetime: %g num transactions:%d \n", tcall, len(task.block.Transactions))
+
+			case *rollbackTask:
+				task.done <- self.handleRollbackTask(task.height)
+
+			case *snapshotTask:
+				task.done <- self.handleSnapshotTask(task.height, task.w)
+
+			case *viewTask:
+				task.done <- self.handleViewTask(task)
+
+			case *repairTask:
+				task.done <- self.handleRepairTask(task.replay)
 			}
 
 		case closed := <-self.quit:
@@ -169,7 +208,7 @@ func (bd *ChainStore) InitLedgerStoreWithGenesisBlock(genesisBlock *Block, defau
 		version = []byte{0x00}
 	}
 
-	if version[0] == 0x01 {
+	if version[0] == SchemaVersion {
 		// GenesisBlock should exist in chain
 		// Or the bookkeepers are not consistent with the chain
 		if !bd.IsBlockInStore(hash) {
@@ -188,6 +227,10 @@ func (bd *ChainStore) InitLedgerStoreWithGenesisBlock(genesisBlock *Block, defau
 		bd.currentBlockHeight, err = serialization.ReadUint32(r)
 		current_Header_Height := bd.currentBlockHeight
 
+		if err := bd.loadStateRoot(); err != nil {
+			return 0, err
+		}
+
 		log.Debugf("blockHash: %x\n", blockHash.ToArray())
 
 		var listHash Uint256
@@ -301,7 +344,7 @@ func (bd *ChainStore) InitLedgerStoreWithGenesisBlock(genesisBlock *Block, defau
 		bd.persist(genesisBlock)
 
 		// put version to db
-		err = bd.st.Put(prefix, []byte{0x01})
+		err = bd.st.Put(prefix, []byte{SchemaVersion})
 		if err != nil {
 			return 0, err
 		}
@@ -503,7 +546,7 @@ func (bd *ChainStore) SaveAsset(assetId Uint256, asset *Asset) error {
 	log.Debug(fmt.Sprintf("asset key: %x\n", assetKey))
 
 	// PUT VALUE
-	err := bd.st.BatchPut(assetKey.Bytes(), w.Bytes())
+	err := bd.batchPut(assetKey.Bytes(), w.Bytes())
 	if err != nil {
 		return err
 	}
@@ -525,7 +568,7 @@ func (bd *ChainStore) SaveLockedAsset(programHash Uint160, assetID Uint256, lock
 		}
 	}
 
-	if err := bd.st.BatchPut(key.Bytes(), value.Bytes()); err != nil {
+	if err := bd.batchPut(key.Bytes(), value.Bytes()); err != nil {
 		return err
 	}
 
@@ -576,9 +619,25 @@ func (bd *ChainStore) GetTransaction(hash Uint256) (*tx.Transaction, error) {
 	return t, nil
 }
 
-func (bd *ChainStore) getTx(tx *tx.Transaction, hash Uint256) error {
+// GetTransactionHeight returns the height of the block that holds the
+// transaction.
+func (bd *ChainStore) GetTransactionHeight(hash Uint256) (uint32, error) {
 	prefix := []byte{byte(DATA_Transaction)}
 	tHash, err_get := bd.st.Get(append(prefix, hash.ToArray()...))
+	if err_get != nil {
+		return 0, err_get
+	}
+
+	return serialization.ReadUint32(bytes.NewReader(tHash))
+}
+
+func (bd *ChainStore) getTx(tx *tx.Transaction, hash Uint256) error {
+	return readTx(bd.st, tx, hash)
+}
+
+func readTx(st storeReader, tx *tx.Transaction, hash Uint256) error {
+	prefix := []byte{byte(DATA_Transaction)}
+	tHash, err_get := st.Get(append(prefix, hash.ToArray()...))
 	if err_get != nil {
 		//TODO: implement error process
 		return err_get
@@ -591,6 +650,9 @@ func (bd *ChainStore) getTx(tx *tx.Transaction, hash Uint256) error {
 	if err != nil {
 		return err
 	}
+	if r.Len() == 0 {
+		return ErrPruned
+	}
 
 	// Deserialize Transaction
 	err = tx.Deserialize(r)
@@ -616,7 +678,7 @@ func (bd *ChainStore) SaveTransaction(tx *tx.Transaction, height uint32) error {
 	log.Debug(fmt.Sprintf("transaction tx data: %x\n", w))
 
 	// put value
-	err := bd.st.BatchPut(txhash.Bytes(), w.Bytes())
+	err := bd.batchPut(txhash.Bytes(), w.Bytes())
 	if err != nil {
 		return err
 	}
@@ -651,7 +713,7 @@ func (bd *ChainStore) SaveRecord(hash string, txhash Uint256) error {
 		return err
 	}
 
-	if err := bd.st.BatchPut(key.Bytes(), value.Bytes()); err != nil {
+	if err := bd.batchPut(key.Bytes(), value.Bytes()); err != nil {
 		return err
 	}
 
@@ -748,12 +810,28 @@ func (self *ChainStore) GetBookKeeperList() ([]*crypto.PubKey, []*crypto.PubKey,
 	return currBookKeeper, nextBookKeeper, nil
 }
 
+// issueQuantity adds an IssueAsset of value to the quantities of the block at
+// height. Before IssueQuantityHeight a later IssueAsset of an asset in the
+// block replaced the quantity the earlier ones issued, issued keeps that
+// quantity per asset. From that height on every IssueAsset counts.
+func issueQuantity(height uint32, quantities map[Uint256]Fixed64, issued map[Uint256]Fixed64, assetId Uint256, value Fixed64) {
+	if config.Parameters.IssueQuantityHeight > 0 && height >= config.Parameters.IssueQuantityHeight {
+		quantities[assetId] += value
+		return
+	}
+	quantities[assetId] += value - issued[assetId]
+	issued[assetId] = value
+}
+
 func (bd *ChainStore) persist(b *Block) error {
 	utxoUnspents := make(map[Uint160]map[Uint256][]*tx.UTXOUnspent)
 	unspents := make(map[Uint256][]uint16)
 	quantities := make(map[Uint256]Fixed64)
+	issued := make(map[Uint256]Fixed64)
 	dbCache := NewDBCache(bd)
 	lockedAssets := make(map[Uint160]map[Uint256][]*LockAsset)
+	balanceAssets := make(map[Uint256]bool)
+	nonces := make(map[Uint160]uint64)
 
 	///////////////////////////////////////////////////////////////
 	// Get Unspents for every tx
@@ -763,6 +841,16 @@ func (bd *ChainStore) persist(b *Block) error {
 	///////////////////////////////////////////////////////////////
 	// batch write begin
 	bd.st.NewBatch()
+	bd.undo = newUndoJournal()
+	bd.stateChanges = make(map[string][]byte)
+	defer func() {
+		bd.undo = nil
+		bd.stateChanges = nil
+	}()
+
+	if b.Blockdata.Version >= BlockVersionStateRoot && b.Blockdata.StateRoot != bd.stateRoot {
+		return errors.New(fmt.Sprintf("[persist] block %d state root %x does not match %x", b.Blockdata.Height, b.Blockdata.StateRoot, bd.stateRoot))
+	}
 
 	//////////////////////////////////////////////////////////////
 	// generate key with DATA_Header prefix
@@ -784,7 +872,7 @@ func (bd *ChainStore) persist(b *Block) error {
 	b.Trim(w)
 
 	// BATCH PUT VALUE
-	bd.st.BatchPut(bhhash.Bytes(), w.Bytes())
+	bd.batchPut(bhhash.Bytes(), w.Bytes())
 
 	//////////////////////////////////////////////////////////////
 	// generate key with DATA_BlockHash prefix
@@ -826,13 +914,16 @@ func (bd *ChainStore) persist(b *Block) error {
 	}
 
 	// BATCH PUT VALUE
-	bd.st.BatchPut(bhash.Bytes(), hashWriter.Bytes())
+	bd.batchPut(bhash.Bytes(), hashWriter.Bytes())
 
 	//////////////////////////////////////////////////////////////
 	// save transactions to leveldb
 	nLen := len(b.Transactions)
+	// transactions may spend outputs of those before them in the block
+	blockTxns := make(map[Uint256]*tx.Transaction, nLen)
 
 	for i := 0; i < nLen; i++ {
+		blockTxns[b.Transactions[i].Hash()] = b.Transactions[i]
 
 		txHash := b.Transactions[i].Hash()
 		switch b.Transactions[i].TxType {
@@ -856,20 +947,33 @@ func (bd *ChainStore) persist(b *Block) error {
 				}
 			}
 			newAsset := &LockAsset{
-				Lock:   b.Blockdata.Height,
-				Unlock: lp.UnlockHeight,
-				Amount: lp.Amount,
+				Lock:    b.Blockdata.Height,
+				Unlock:  lp.UnlockHeight,
+				Amount:  lp.Amount,
+				Start:   b.Blockdata.Height,
+				Cliff:   lp.UnlockHeight,
+				Release: ReleaseCliff,
+			}
+			if b.Transactions[i].PayloadVersion >= payload.LockAssetVestingPayloadVersion {
+				newAsset.Start = lp.StartHeight
+				newAsset.Cliff = lp.CliffHeight
+				newAsset.Release = lp.Release
+				newAsset.Steps = lp.Steps
 			}
 			lockedAssets[lp.ProgramHash][lp.AssetID] = append(lockedAssets[lp.ProgramHash][lp.AssetID], newAsset)
 
 		case tx.IssueAsset:
 			results := b.Transactions[i].GetMergedAssetIDValueFromOutputs()
 			for assetId, value := range results {
-				if _, ok := quantities[assetId]; !ok {
-					quantities[assetId] += value
-				} else {
-					quantities[assetId] = value
-				}
+				issueQuantity(b.Blockdata.Height, quantities, issued, assetId, value)
+			}
+		case tx.BurnAsset:
+			burn := b.Transactions[i].Payload.(*payload.BurnAsset)
+			quantities[burn.AssetID] -= burn.Amount
+		case tx.FreezeAsset:
+			freeze := b.Transactions[i].Payload.(*payload.FreezeAsset)
+			if err := bd.saveFrozen(freeze.AssetID, freeze.ProgramHash, freeze.Freeze); err != nil {
+				return err
 			}
 		case tx.DeployCode:
 			deployCode := b.Transactions[i].Payload.(*payload.DeployCode)
@@ -1001,10 +1105,12 @@ func (bd *ChainStore) persist(b *Block) error {
 			}
 		}
 
-		// now support RegisterAsset / IssueAsset / TransferAsset and Miner TX ONLY.
+		// now support RegisterAsset / IssueAsset / BurnAsset / TransferAsset and Miner TX ONLY.
 		if b.Transactions[i].TxType == tx.RegisterAsset ||
 			b.Transactions[i].TxType == tx.LockAsset ||
 			b.Transactions[i].TxType == tx.IssueAsset ||
+			b.Transactions[i].TxType == tx.BurnAsset ||
+			b.Transactions[i].TxType == tx.FreezeAsset ||
 			b.Transactions[i].TxType == tx.TransferAsset ||
 			b.Transactions[i].TxType == tx.Record ||
 			b.Transactions[i].TxType == tx.BookKeeper ||
@@ -1019,10 +1125,14 @@ func (bd *ChainStore) persist(b *Block) error {
 			}
 		}
 
+		received := make(map[Uint160]map[Uint256]Fixed64)
+		spent := make(map[Uint160]map[Uint256]Fixed64)
+
 		for index := 0; index < len(b.Transactions[i].Outputs); index++ {
 			output := b.Transactions[i].Outputs[index]
 			programHash := output.ProgramHash
 			assetId := output.AssetID
+			addTxHistory(received, programHash, assetId, output.Value)
 			if value, ok := accounts[programHash]; ok {
 				value.Balances[assetId] += output.Value
 			} else {
@@ -1040,6 +1150,11 @@ func (bd *ChainStore) persist(b *Block) error {
 				accounts[programHash] = accountState
 			}
 
+			// balance-model assets have no UTXO
+			if bd.isBalanceAsset(assetId, balanceAssets) {
+				continue
+			}
+
 			// add utxoUnspent
 			if _, ok := utxoUnspents[programHash]; !ok {
 				utxoUnspents[programHash] = make(map[Uint256][]*tx.UTXOUnspent)
@@ -1062,14 +1177,17 @@ func (bd *ChainStore) persist(b *Block) error {
 
 		for index := 0; index < len(b.Transactions[i].UTXOInputs); index++ {
 			input := b.Transactions[i].UTXOInputs[index]
-			transaction, err := bd.GetTransaction(input.ReferTxID)
-			if err != nil {
-				return err
+			transaction, ok := blockTxns[input.ReferTxID]
+			if !ok {
+				if transaction, err = bd.GetTransaction(input.ReferTxID); err != nil {
+					return err
+				}
 			}
 			index := input.ReferTxOutputIndex
 			output := transaction.Outputs[index]
 			programHash := output.ProgramHash
 			assetId := output.AssetID
+			addTxHistory(spent, programHash, assetId, output.Value)
 			if value, ok := accounts[programHash]; ok {
 				value.Balances[assetId] -= output.Value
 			} else {
@@ -1114,9 +1232,56 @@ func (bd *ChainStore) persist(b *Block) error {
 
 		}
 
+		// the inputs of one transaction and account share a nonce
+		spentNonces := make(map[Uint160]uint64)
+		for _, input := range b.Transactions[i].BalanceInputs {
+			programHash := input.ProgramHash
+			assetId := input.AssetID
+			addTxHistory(spent, programHash, assetId, input.Value)
+			if _, ok := accounts[programHash]; !ok {
+				accountState, err := bd.GetAccount(programHash)
+				if err != nil {
+					return err
+				}
+				accounts[programHash] = accountState
+			}
+			accounts[programHash].Balances[assetId] -= input.Value
+			if accounts[programHash].Balances[assetId] < 0 {
+				return errors.New(fmt.Sprintf("account programHash:%v, assetId:%v insufficient of balance", programHash, assetId))
+			}
+
+			// the input has to use a nonce past the account nonce
+			if _, ok := nonces[programHash]; !ok {
+				if nonces[programHash], err = bd.GetAccountNonce(programHash); err != nil {
+					return err
+				}
+			}
+			if input.Nonce < nonces[programHash] {
+				return errors.New(fmt.Sprintf("[persist] nonce %d of account %x already used", input.Nonce, programHash))
+			}
+			spentNonces[programHash] = input.Nonce + 1
+		}
+		// move the account nonces past the inputs
+		for programHash, nonce := range spentNonces {
+			nonces[programHash] = nonce
+		}
+
+		// address history
+		err = saveTxHistory(bd.batchPut, b.Blockdata.Height, uint32(i), txHash, b.Transactions[i].TxType, tx.TxIncoming, received)
+		if err != nil {
+			return err
+		}
+		err = saveTxHistory(bd.batchPut, b.Blockdata.Height, uint32(i), txHash, b.Transactions[i].TxType, tx.TxOutgoing, spent)
+		if err != nil {
+			return err
+		}
+
 		// init unspent in tx
 		txhash := b.Transactions[i].Hash()
 		for index := 0; index < len(b.Transactions[i].Outputs); index++ {
+			if bd.isBalanceAsset(b.Transactions[i].Outputs[index].AssetID, balanceAssets) {
+				continue
+			}
 			unspents[txhash] = append(unspents[txhash], uint16(index))
 		}
 
@@ -1207,7 +1372,7 @@ func (bd *ChainStore) persist(b *Block) error {
 		}
 
 		// BookKeeper put value
-		bd.st.BatchPut(bkListKey.Bytes(), bkListValue.Bytes())
+		bd.batchPut(bkListKey.Bytes(), bkListValue.Bytes())
 
 		///////////////////////////////////////////////////////
 	}
@@ -1231,10 +1396,10 @@ func (bd *ChainStore) persist(b *Block) error {
 		txhash.Serialize(unspentKey)
 
 		if len(value) == 0 {
-			bd.st.BatchDelete(unspentKey.Bytes())
+			bd.batchDelete(unspentKey.Bytes())
 		} else {
 			unspentArray := ToByteArray(value)
-			bd.st.BatchPut(unspentKey.Bytes(), unspentArray)
+			bd.batchPut(unspentKey.Bytes(), unspentArray)
 		}
 	}
 
@@ -1254,7 +1419,7 @@ func (bd *ChainStore) persist(b *Block) error {
 		quantityArray := bytes.NewBuffer(nil)
 		qt.Serialize(quantityArray)
 
-		bd.st.BatchPut(quantityKey.Bytes(), quantityArray.Bytes())
+		bd.batchPut(quantityKey.Bytes(), quantityArray.Bytes())
 		log.Debug(fmt.Sprintf("quantityKey: %x\n", quantityKey.Bytes()))
 		log.Debug(fmt.Sprintf("quantityArray: %x\n", quantityArray.Bytes()))
 	}
@@ -1267,7 +1432,13 @@ func (bd *ChainStore) persist(b *Block) error {
 		accountValue := new(bytes.Buffer)
 		value.Serialize(accountValue)
 
-		bd.st.BatchPut(accountKey.Bytes(), accountValue.Bytes())
+		bd.batchPut(accountKey.Bytes(), accountValue.Bytes())
+	}
+
+	for programHash, nonce := range nonces {
+		if err := bd.saveAccountNonce(programHash, nonce); err != nil {
+			return err
+		}
 	}
 
 	for programHash, assets := range lockedAssets {
@@ -1286,19 +1457,39 @@ func (bd *ChainStore) persist(b *Block) error {
 	serialization.WriteUint32(currentBlock, b.Blockdata.Height)
 
 	// BATCH PUT VALUE
-	bd.st.BatchPut(currentBlockKey.Bytes(), currentBlock.Bytes())
+	bd.batchPut(currentBlockKey.Bytes(), currentBlock.Bytes())
 
 	err = dbCache.Commit()
 	if err != nil {
 		return err
 	}
 
+	if err := bd.prune(b.Blockdata.Height, unspents); err != nil {
+		return err
+	}
+
+	stateRoot, err := bd.commitStateChanges(b.Blockdata.Height)
+	if err != nil {
+		return err
+	}
+
+	// undo record for RollbackTo
+	undoValue := bytes.NewBuffer(nil)
+	if err := bd.undo.Serialize(undoValue); err != nil {
+		return err
+	}
+	bd.st.BatchPut(undoKey(b.Blockdata.Height), undoValue.Bytes())
+
 	err = bd.st.BatchCommit()
 
 	if err != nil {
 		return err
 	}
 
+	bd.mu.Lock()
+	bd.stateRoot = stateRoot
+	bd.mu.Unlock()
+
 	return nil
 }
 
@@ -1581,21 +1772,27 @@ func (bd *ChainStore) IsBlockInStore(hash Uint256) bool {
 func (bd *ChainStore) GetAvailableAsset(programHash Uint160, assetID Uint256) (Fixed64, Fixed64, error) {
 	// get total asset
 	var total Fixed64
-	utxos, err := bd.GetUnspentFromProgramHash(programHash, assetID)
-	if err != nil {
-		return Fixed64(-1), Fixed64(-1), err
-	}
-	for _, v := range utxos {
-		total += v.Value
+	if bd.isBalanceAsset(assetID, map[Uint256]bool{}) {
+		accountState, err := bd.GetAccount(programHash)
+		if err != nil {
+			return Fixed64(-1), Fixed64(-1), err
+		}
+		total = accountState.Balances[assetID]
+	} else {
+		utxos, err := bd.GetUnspentFromProgramHash(programHash, assetID)
+		if err != nil {
+			return Fixed64(-1), Fixed64(-1), err
+		}
+		for _, v := range utxos {
+			total += v.Value
+		}
 	}
 
 	// get locked asset
 	var locked Fixed64
 	l, _ := bd.GetLockedFromProgramHash(programHash, assetID)
 	for _, v := range l {
-		if v.Unlock > bd.currentBlockHeight {
-			locked += v.Amount
-		}
+		locked += v.Unvested(bd.currentBlockHeight)
 	}
 
 	return total, locked, nil
@@ -1677,7 +1874,7 @@ func (bd *ChainStore) saveUnspentWithProgramHash(programHash Uint160, assetid Ui
 	}
 
 	// BATCH PUT VALUE
-	err := bd.st.BatchPut(key, w.Bytes())
+	err := bd.batchPut(key, w.Bytes())
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,270 @@
+package ChainStore
+
+import (
+	"IPT/account"
+	. "IPT/common"
+	"IPT/core/asset"
+	"IPT/core/contract"
+	"IPT/core/contract/program"
+	"IPT/core/ledger"
+	sig "IPT/core/signature"
+	. "IPT/core/store/MemStore"
+	tx "IPT/core/transaction"
+	"IPT/core/transaction/payload"
+	"IPT/core/validation"
+	"IPT/crypto"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// newTestChainStore returns a ChainStore on a MemStore holding the genesis
+// block of a single bookkeeper, set as the store of the default ledger.
+func newTestChainStore(t *testing.T) (*ChainStore, *crypto.PubKey) {
+	crypto.SetAlg("P256R1")
+	_, bookKeeper, err := crypto.GenKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newBookKeptChainStore(t, &bookKeeper), &bookKeeper
+}
+
+// newBookKeptChainStore is newTestChainStore with bookKeeper as the
+// bookkeeper, chain stores of the same bookkeeper share the genesis block.
+func newBookKeptChainStore(t *testing.T, bookKeeper *crypto.PubKey) *ChainStore {
+	bd, err := NewChainStoreWithStore(NewMemStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger.DefaultLedger = &ledger.Ledger{Store: bd, Blockchain: ledger.NewBlockchain(0)}
+	if _, err := bd.InitLedgerStoreWithGenesisBlock(mustGenesis(t, bookKeeper), []*crypto.PubKey{bookKeeper}); err != nil {
+		bd.Close()
+		t.Fatal(err)
+	}
+	return bd
+}
+
+func mustGenesis(t *testing.T, bookKeeper *crypto.PubKey) *ledger.Block {
+	genesis, err := ledger.GenesisBlockInit([]*crypto.PubKey{bookKeeper})
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis.RebuildMerkleRoot()
+	return genesis
+}
+
+// registerAsset persists the registration of an asset of 100 issued by
+// issuer and returns its id.
+func registerAsset(t *testing.T, bd *ChainStore, issuer *crypto.PubKey, name string, recordType asset.AssetRecordType) Uint256 {
+	reg := &tx.Transaction{
+		TxType: tx.RegisterAsset,
+		Payload: &payload.RegisterAsset{
+			Asset:  &asset.Asset{Name: name, Precision: 8, AssetType: asset.Token, RecordType: recordType},
+			Amount: 100,
+			Issuer: issuer,
+		},
+	}
+	mustPersist(t, bd, reg)
+	return reg.Hash()
+}
+
+// mustPersist persists a block holding txs on top of the current block.
+func mustPersist(t *testing.T, bd *ChainStore, txs ...*tx.Transaction) {
+	b := nextBlock(t, bd, txs...)
+	if err := bd.persist(b); err != nil {
+		t.Fatal(err)
+	}
+	bd.mu.Lock()
+	bd.currentBlockHeight = b.Blockdata.Height
+	bd.mu.Unlock()
+}
+
+// nextBlock returns a block holding txs on top of the current block.
+func nextBlock(t *testing.T, bd *ChainStore, txs ...*tx.Transaction) *ledger.Block {
+	height := bd.GetHeight() + 1
+	prev, err := bd.GetBlockHash(height - 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &ledger.Block{
+		Blockdata: &ledger.Blockdata{
+			PrevBlockHash: prev,
+			Height:        height,
+			ConsensusData: uint64(height),
+			Program:       &program.Program{},
+		},
+		Transactions: append([]*tx.Transaction{{
+			TxType:  tx.BookKeeping,
+			Payload: &payload.BookKeeping{Nonce: uint64(height)},
+		}}, txs...),
+	}
+	return b
+}
+
+func TestSpendInSameBlock(t *testing.T) {
+	bd, bookKeeper := newTestChainStore(t)
+	defer bd.Close()
+	assetID := registerAsset(t, bd, bookKeeper, "coin", asset.UTXO)
+	before := bd.GetHeight()
+	holder, receiver := Uint160{1}, Uint160{2}
+
+	parent := &tx.Transaction{
+		TxType:  tx.IssueAsset,
+		Payload: &payload.IssueAsset{},
+		Outputs: []*tx.TxOutput{{AssetID: assetID, Value: 100, ProgramHash: holder}},
+	}
+	child := &tx.Transaction{
+		TxType:     tx.TransferAsset,
+		Payload:    &payload.TransferAsset{},
+		UTXOInputs: []*tx.UTXOTxInput{{ReferTxID: parent.Hash(), ReferTxOutputIndex: 0}},
+		Outputs:    []*tx.TxOutput{{AssetID: assetID, Value: 100, ProgramHash: receiver}},
+	}
+	mustPersist(t, bd, parent, child)
+
+	if unspent, _ := bd.ContainsUnspent(parent.Hash(), 0); unspent {
+		t.Fatal("output spent in the block is unspent")
+	}
+	if unspent, err := bd.ContainsUnspent(child.Hash(), 0); err != nil || !unspent {
+		t.Fatalf("output of the child is not unspent, %v", err)
+	}
+	if total, _, err := bd.GetAvailableAsset(holder, assetID); err != nil || total != 0 {
+		t.Fatalf("holder balance %v, %v", total, err)
+	}
+	if total, _, err := bd.GetAvailableAsset(receiver, assetID); err != nil || total != 100 {
+		t.Fatalf("receiver balance %v, %v", total, err)
+	}
+
+	if err := bd.RollbackTo(before); err != nil {
+		t.Fatal(err)
+	}
+	for _, programHash := range []Uint160{holder, receiver} {
+		if unspents, _ := bd.GetUnspentFromProgramHash(programHash, assetID); len(unspents) != 0 {
+			t.Fatalf("%d unspent outputs after rollback", len(unspents))
+		}
+	}
+	if bd.IsTxHashDuplicate(parent.Hash()) || bd.IsTxHashDuplicate(child.Hash()) {
+		t.Fatal("transactions of the rolled back block are still stored")
+	}
+	if mismatches, err := bd.VerifyLedger(false); err != nil || len(mismatches) != 0 {
+		t.Fatalf("ledger after rollback: %v %v", mismatches, err)
+	}
+}
+
+// signatureProgram returns the program of the signature of signer on data.
+func signatureProgram(t *testing.T, data sig.SignableData, signer *account.Account) *program.Program {
+	signature, err := sig.SignBySigner(data, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := contract.CreateSignatureRedeemScript(signer.PubKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder := program.NewProgramBuilder()
+	builder.PushData(signature)
+	return &program.Program{Code: code, Parameter: builder.ToArray()}
+}
+
+// mustPersistSigned persists a block holding txs on top of the current
+// block as bookKeeper signed it.
+func mustPersistSigned(t *testing.T, bd *ChainStore, bookKeeper *account.Account, txs ...*tx.Transaction) {
+	b := nextBlock(t, bd, txs...)
+	prev, err := bd.GetHeader(b.Blockdata.PrevBlockHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Blockdata.Version = ledger.BlockVersionAt(b.Blockdata.Height)
+	b.Blockdata.Timestamp = prev.Blockdata.Timestamp + 1
+	b.Blockdata.NextBookKeeper = bookKeeper.ProgramHash
+	b.RebuildMerkleRoot()
+	b.Blockdata.Program = signatureProgram(t, b, bookKeeper)
+	if err := bd.persist(b); err != nil {
+		t.Fatal(err)
+	}
+	bd.mu.Lock()
+	bd.currentBlockHeight = b.Blockdata.Height
+	bd.mu.Unlock()
+}
+
+func TestImportSpendInSameBlock(t *testing.T) {
+	defer func(store tx.ILedgerStore) { tx.TxStore = store }(tx.TxStore)
+	crypto.SetAlg("P256R1")
+	bookKeeper, err := account.NewAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := newBookKeptChainStore(t, bookKeeper.PubKey())
+	defer src.Close()
+	tx.TxStore = src
+
+	reg := &tx.Transaction{
+		TxType: tx.RegisterAsset,
+		Payload: &payload.RegisterAsset{
+			Asset:      &asset.Asset{Name: "coin", Precision: 8, AssetType: asset.Token, RecordType: asset.UTXO},
+			Amount:     100,
+			Issuer:     bookKeeper.PubKey(),
+			Controller: bookKeeper.ProgramHash,
+		},
+	}
+	reg.SetPrograms([]*program.Program{signatureProgram(t, reg, bookKeeper)})
+	mustPersistSigned(t, src, bookKeeper, reg)
+	parent := &tx.Transaction{
+		TxType:  tx.IssueAsset,
+		Payload: &payload.IssueAsset{},
+		Outputs: []*tx.TxOutput{{AssetID: reg.Hash(), Value: 100, ProgramHash: bookKeeper.ProgramHash}},
+	}
+	parent.SetPrograms([]*program.Program{signatureProgram(t, parent, bookKeeper)})
+	child := &tx.Transaction{
+		TxType:     tx.TransferAsset,
+		Payload:    &payload.TransferAsset{},
+		UTXOInputs: []*tx.UTXOTxInput{{ReferTxID: parent.Hash(), ReferTxOutputIndex: 0}},
+		Outputs:    []*tx.TxOutput{{AssetID: reg.Hash(), Value: 100, ProgramHash: Uint160{1}}},
+	}
+	child.SetPrograms([]*program.Program{signatureProgram(t, child, bookKeeper)})
+	mustPersistSigned(t, src, bookKeeper, parent, child)
+
+	buf := bytes.NewBuffer(nil)
+	if err := ledger.ExportBlocks(src, 1, src.GetHeight(), buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// import the blocks as importblocks does
+	dst := newBookKeptChainStore(t, bookKeeper.PubKey())
+	defer dst.Close()
+	tx.TxStore = dst
+	stream, err := ledger.NewBlockStreamReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		block, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validation.VerifyBlock(block, ledger.DefaultLedger, true); err != nil {
+			t.Fatalf("block %d: %v", block.Blockdata.Height, err)
+		}
+		if err := dst.SaveBlock(block, ledger.DefaultLedger); err != nil {
+			t.Fatal(err)
+		}
+		for deadline := time.Now().Add(time.Second); dst.GetHeight() < block.Blockdata.Height; {
+			if time.Now().After(deadline) {
+				t.Fatalf("block %d was not persisted", block.Blockdata.Height)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for h := uint32(1); h <= src.GetHeight(); h++ {
+		exported, _ := src.GetBlockHash(h)
+		if imported, err := dst.GetBlockHash(h); err != nil || imported != exported {
+			t.Fatalf("block %d differs from the exported one, %v", h, err)
+		}
+	}
+	if unspent, err := dst.ContainsUnspent(child.Hash(), 0); err != nil || !unspent {
+		t.Fatalf("output of the child is not unspent, %v", err)
+	}
+}
@@ -27,13 +27,13 @@ func (cache *DBCache) Commit() (err error) {
 		key := make([]byte, 0)
 		key = append([]byte{byte(v.Prefix)}, []byte(k)...)
 		if v.IsDeleted {
-			err = cache.db.st.BatchDelete(key)
+			err = cache.db.batchDelete(key)
 		} else {
 			b := new(bytes.Buffer)
 			v.Item.Serialize(b)
 			value := make([]byte, 0)
 			value = append(value, b.Bytes()...)
-			err = cache.db.st.BatchPut(key, value)
+			err = cache.db.batchPut(key, value)
 		}
 	}
 	return
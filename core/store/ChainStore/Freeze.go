@@ -0,0 +1,50 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	. "IPT/core/store"
+)
+
+// ST_Frozen holds an entry for every program hash the asset controller froze,
+// keyed by asset ID and program hash.
+
+func frozenKey(assetId Uint256, programHash Uint160) []byte {
+	key := append([]byte{byte(ST_Frozen)}, assetId.ToArray()...)
+	return append(key, programHash.ToArray()...)
+}
+
+// IsFrozen reports whether the controller of the asset froze programHash.
+func (bd *ChainStore) IsFrozen(assetId Uint256, programHash Uint160) (bool, error) {
+	_, err := bd.st.Get(frozenKey(assetId, programHash))
+	if err != nil {
+		if err.Error() == ErrDBNotFound.Error() {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// GetFrozenAddresses returns the frozen program hashes of the asset.
+func (bd *ChainStore) GetFrozenAddresses(assetId Uint256) ([]Uint160, error) {
+	prefix := append([]byte{byte(ST_Frozen)}, assetId.ToArray()...)
+	iter := bd.st.NewIterator(prefix)
+	defer iter.Release()
+
+	frozen := []Uint160{}
+	for iter.Next() {
+		programHash, err := Uint160ParseFromBytes(iter.Key()[len(prefix):])
+		if err != nil {
+			return nil, err
+		}
+		frozen = append(frozen, programHash)
+	}
+	return frozen, nil
+}
+
+func (bd *ChainStore) saveFrozen(assetId Uint256, programHash Uint160, freeze bool) error {
+	if freeze {
+		return bd.batchPut(frozenKey(assetId, programHash), []byte{1})
+	}
+	return bd.batchDelete(frozenKey(assetId, programHash))
+}
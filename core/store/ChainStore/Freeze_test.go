@@ -0,0 +1,31 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	tx "IPT/core/transaction"
+	"testing"
+)
+
+func TestFreezeAsset(t *testing.T) {
+	bd, _ := newTestChainStore(t)
+	defer bd.Close()
+	assetID, holder := Uint256{1}, Uint160{2}
+
+	freeze, _ := tx.NewFreezeAssetTransaction(assetID, holder, true)
+	mustPersist(t, bd, freeze)
+	if frozen, err := bd.IsFrozen(assetID, holder); err != nil || !frozen {
+		t.Fatalf("holder not frozen: %v", err)
+	}
+	if frozen, err := bd.GetFrozenAddresses(assetID); err != nil || len(frozen) != 1 || frozen[0] != holder {
+		t.Fatalf("frozen addresses %v, %v", frozen, err)
+	}
+
+	unfreeze, _ := tx.NewFreezeAssetTransaction(assetID, holder, false)
+	mustPersist(t, bd, unfreeze)
+	if frozen, err := bd.IsFrozen(assetID, holder); err != nil || frozen {
+		t.Fatalf("holder still frozen: %v", err)
+	}
+	if frozen, _ := bd.GetFrozenAddresses(assetID); len(frozen) != 0 {
+		t.Fatalf("frozen addresses %v after unfreeze", frozen)
+	}
+}
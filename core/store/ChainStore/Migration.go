@@ -0,0 +1,377 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	"IPT/common/log"
+	"IPT/common/serialization"
+	"IPT/core/account"
+	. "IPT/core/asset"
+	. "IPT/core/store"
+	tx "IPT/core/transaction"
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// SchemaVersion is the layout of the records this node reads and writes. It
+// is stored under CFG_Version and raised with every migration.
+const SchemaVersion byte = 0x05
+
+const migrationBatchSize = 10000
+
+// A migration upgrades the store from version-1 to version.
+type migration struct {
+	version     byte
+	description string
+	migrate     func(bd *ChainStore, batch *migrationBatch) error
+}
+
+// migrations are run in order, add new ones at the end.
+var migrations = []migration{
+	{0x02, "backfill the address transaction history", migrateTxHistory},
+	{0x03, "add vesting schedules to the locked assets", migrateLockedAssets},
+	{0x04, "store the account balances in asset order", migrateAccountStates},
+	{0x05, "add the transaction types to the address transaction history", migrateTxHistoryTypes},
+}
+
+// migrationBatch commits the writes of a migration, or a snapshot import,
+// every migrationBatchSize records so a large store is not rewritten in a
+// single batch.
+type migrationBatch struct {
+	st    IStore
+	count int
+	// a state entry was written, the state tree is built again
+	stateChanged bool
+}
+
+func (b *migrationBatch) put(key []byte, value []byte) error {
+	if len(key) > 0 && isStatePrefix(key[0]) {
+		b.stateChanged = true
+	}
+	if b.count == 0 {
+		b.st.NewBatch()
+	}
+	b.st.BatchPut(key, value)
+	b.count++
+	if b.count >= migrationBatchSize {
+		return b.commit()
+	}
+	return nil
+}
+
+func (b *migrationBatch) delete(key []byte) error {
+	if len(key) > 0 && isStatePrefix(key[0]) {
+		b.stateChanged = true
+	}
+	if b.count == 0 {
+		b.st.NewBatch()
+	}
+	b.st.BatchDelete(key)
+	b.count++
+	if b.count >= migrationBatchSize {
+		return b.commit()
+	}
+	return nil
+}
+
+func (b *migrationBatch) commit() error {
+	if b.count == 0 {
+		return nil
+	}
+	b.count = 0
+	return b.st.BatchCommit()
+}
+
+// upgradeSchema checks the schema version of an initialized store and runs
+// the migrations from it to SchemaVersion. The version is saved after each
+// migration, an interrupted upgrade continues with the next one. A migration
+// that rewrites state entries drops the state tree, it is built again from
+// the migrated entries when the store is opened.
+func (bd *ChainStore) upgradeSchema() error {
+	data, err := bd.st.Get([]byte{byte(CFG_Version)})
+	if err != nil || len(data) == 0 {
+		// new store, the version is written with the genesis block
+		return nil
+	}
+
+	version := data[0]
+	if version > SchemaVersion {
+		return errors.New(fmt.Sprintf("ledger store schema version %d is newer than version %d of this node", version, SchemaVersion))
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		if m.version != version+1 {
+			return errors.New(fmt.Sprintf("no migration of the ledger store from schema version %d", version))
+		}
+
+		log.Infof("migrating ledger store to schema version %d: %s", m.version, m.description)
+		batch := &migrationBatch{st: bd.st}
+		if err := m.migrate(bd, batch); err != nil {
+			return errors.New(fmt.Sprintf("migration to schema version %d failed: %v", m.version, err))
+		}
+		if err := batch.commit(); err != nil {
+			return err
+		}
+		if batch.stateChanged {
+			if err := bd.dropStateTree(); err != nil {
+				return err
+			}
+		}
+		if err := bd.st.Put([]byte{byte(CFG_Version)}, []byte{m.version}); err != nil {
+			return err
+		}
+		version = m.version
+	}
+
+	if version != SchemaVersion {
+		return errors.New(fmt.Sprintf("no migration of the ledger store from schema version %d", version))
+	}
+
+	return nil
+}
+
+// migrateTxHistory writes the history entries of the blocks persisted before
+// the address history index existed.
+func migrateTxHistory(bd *ChainStore, batch *migrationBatch) error {
+	data, err := bd.st.Get([]byte{byte(SYS_CurrentBlock)})
+	if err != nil {
+		return err
+	}
+	r := bytes.NewReader(data)
+	var blockHash Uint256
+	if err := blockHash.Deserialize(r); err != nil {
+		return err
+	}
+	height, err := serialization.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+
+	for h := uint32(0); h <= height; h++ {
+		b, err := bd.storedBlock(h)
+		if err != nil {
+			// a store bootstrapped from a snapshot has no earlier blocks
+			continue
+		}
+
+		for i, t := range b.Transactions {
+			if t == nil {
+				continue
+			}
+			received := make(map[Uint160]map[Uint256]Fixed64)
+			spent := make(map[Uint160]map[Uint256]Fixed64)
+			for _, output := range t.Outputs {
+				addTxHistory(received, output.ProgramHash, output.AssetID, output.Value)
+			}
+			for _, input := range t.UTXOInputs {
+				refer, err := bd.GetTransaction(input.ReferTxID)
+				if err != nil || int(input.ReferTxOutputIndex) >= len(refer.Outputs) {
+					continue
+				}
+				output := refer.Outputs[input.ReferTxOutputIndex]
+				addTxHistory(spent, output.ProgramHash, output.AssetID, output.Value)
+			}
+
+			txid := t.Hash()
+			if err := saveTxHistory(batch.put, h, uint32(i), txid, t.TxType, tx.TxIncoming, received); err != nil {
+				return err
+			}
+			if err := saveTxHistory(batch.put, h, uint32(i), txid, t.TxType, tx.TxOutgoing, spent); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// txHistoryValueSize is the size of a history entry written before the
+// history kept transaction types: the txid and the value.
+const txHistoryValueSize = UINT256SIZE + 8
+
+// migrateTxHistoryTypes adds the transaction type to the history entries that
+// lack it. The type of a transaction pruned already is unknown.
+func migrateTxHistoryTypes(bd *ChainStore, batch *migrationBatch) error {
+	iter := bd.st.NewIterator([]byte{byte(IX_TxHistory)})
+	defer iter.Release()
+	for iter.Next() {
+		value := iter.Value()
+		if len(value) != txHistoryValueSize {
+			continue
+		}
+		txType := tx.UnknownTxType
+		data, err := bd.st.Get(append([]byte{byte(DATA_Transaction)}, value[:UINT256SIZE]...))
+		if err == nil && len(data) > 4 {
+			// the height is followed by the tx type
+			txType = tx.TransactionType(data[4])
+		}
+		value = append(append([]byte{}, value...), byte(txType))
+		if err := batch.put(append([]byte{}, iter.Key()...), value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sizes of a serialized lock before and with vesting schedules.
+const (
+	cliffLockSize   = 16
+	vestingLockSize = 29
+)
+
+// upgradeLocked rewrites a list of locks in the layout with vesting schedules.
+// A list already in that layout, upgraded by an interrupted earlier run of
+// the migration, is returned as it is.
+func upgradeLocked(value []byte) ([]byte, error) {
+	r := bytes.NewReader(value)
+	num, err := serialization.ReadVarUint(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch uint64(r.Len()) {
+	case num * vestingLockSize:
+		return value, nil
+	case num * cliffLockSize:
+	default:
+		return nil, errors.New(fmt.Sprintf("locked asset list of %d bytes for %d locks", r.Len(), num))
+	}
+	w := bytes.NewBuffer(nil)
+	serialization.WriteVarUint(w, num)
+	for i := uint64(0); i < num; i++ {
+		var locked LockAsset
+		if err := locked.DeserializeCliff(r); err != nil {
+			return nil, err
+		}
+		locked.Serialize(w)
+	}
+	return w.Bytes(), nil
+}
+
+// migrateLockedAssets rewrites the ST_Locked records, and their previous
+// values kept for RollbackTo, in the layout with vesting schedules.
+func migrateLockedAssets(bd *ChainStore, batch *migrationBatch) error {
+	iter := bd.st.NewIterator([]byte{byte(ST_Locked)})
+	for iter.Next() {
+		value, err := upgradeLocked(iter.Value())
+		if err != nil {
+			iter.Release()
+			return err
+		}
+		if bytes.Equal(value, iter.Value()) {
+			continue
+		}
+		if err := batch.put(append([]byte{}, iter.Key()...), value); err != nil {
+			iter.Release()
+			return err
+		}
+	}
+	iter.Release()
+
+	iter = bd.st.NewIterator([]byte{byte(DATA_Undo)})
+	defer iter.Release()
+	for iter.Next() {
+		j := newUndoJournal()
+		if err := j.Deserialize(bytes.NewReader(iter.Value())); err != nil {
+			return err
+		}
+		changed := false
+		for _, e := range j.entries {
+			if !e.Existed || len(e.Key) == 0 || e.Key[0] != byte(ST_Locked) {
+				continue
+			}
+			value, err := upgradeLocked(e.Value)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(value, e.Value) {
+				continue
+			}
+			e.Value = value
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		w := bytes.NewBuffer(nil)
+		if err := j.Serialize(w); err != nil {
+			return err
+		}
+		if err := batch.put(append([]byte{}, iter.Key()...), w.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sortAccountState rewrites an account state with its balances in asset
+// order. Before that order was fixed they were stored in map order, so the
+// same account could be stored as different bytes on different nodes.
+func sortAccountState(value []byte) ([]byte, error) {
+	var accountState account.AccountState
+	if err := accountState.Deserialize(bytes.NewReader(value)); err != nil {
+		return nil, err
+	}
+	return accountState.ToArray(), nil
+}
+
+// migrateAccountStates rewrites the ST_ACCOUNT records, and their previous
+// values kept for RollbackTo, with the balances in asset order so the state
+// tree holds the same bytes as on a node that replayed the chain.
+func migrateAccountStates(bd *ChainStore, batch *migrationBatch) error {
+	iter := bd.st.NewIterator([]byte{byte(ST_ACCOUNT)})
+	for iter.Next() {
+		value, err := sortAccountState(iter.Value())
+		if err != nil {
+			iter.Release()
+			return err
+		}
+		if bytes.Equal(value, iter.Value()) {
+			continue
+		}
+		if err := batch.put(append([]byte{}, iter.Key()...), value); err != nil {
+			iter.Release()
+			return err
+		}
+	}
+	iter.Release()
+
+	iter = bd.st.NewIterator([]byte{byte(DATA_Undo)})
+	defer iter.Release()
+	for iter.Next() {
+		j := newUndoJournal()
+		if err := j.Deserialize(bytes.NewReader(iter.Value())); err != nil {
+			return err
+		}
+		changed := false
+		for _, e := range j.entries {
+			if !e.Existed || len(e.Key) == 0 || e.Key[0] != byte(ST_ACCOUNT) {
+				continue
+			}
+			value, err := sortAccountState(e.Value)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(value, e.Value) {
+				e.Value = value
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		w := bytes.NewBuffer(nil)
+		if err := j.Serialize(w); err != nil {
+			return err
+		}
+		if err := batch.put(append([]byte{}, iter.Key()...), w.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	"IPT/common/serialization"
+	"IPT/core/account"
+	. "IPT/core/store"
+	. "IPT/core/store/MemStore"
+	"bytes"
+	"testing"
+)
+
+func openWithVersion(version byte) (*ChainStore, error) {
+	return openStoreWithVersion(NewMemStore(), version)
+}
+
+func openStoreWithVersion(st IStore, version byte) (*ChainStore, error) {
+	var blockHash Uint256
+	currentBlock := bytes.NewBuffer(nil)
+	blockHash.Serialize(currentBlock)
+	serialization.WriteUint32(currentBlock, 0)
+	st.Put([]byte{byte(SYS_CurrentBlock)}, currentBlock.Bytes())
+	st.Put([]byte{byte(CFG_Version)}, []byte{version})
+
+	return NewChainStoreWithStore(st)
+}
+
+func TestUpgradeSchema(t *testing.T) {
+	if _, err := openWithVersion(SchemaVersion + 1); err == nil {
+		t.Fatal("store of a newer schema opened")
+	}
+
+	bd, err := openWithVersion(0x01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bd.Close()
+	version, err := bd.st.Get([]byte{byte(CFG_Version)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version[0] != SchemaVersion {
+		t.Fatalf("schema version %d after upgrade, want %d", version[0], SchemaVersion)
+	}
+}
+
+func TestMigrateLockedAssets(t *testing.T) {
+	old := bytes.NewBuffer(nil)
+	serialization.WriteVarUint(old, 2)
+	for _, lock := range []struct {
+		lock, unlock uint32
+		amount       Fixed64
+	}{{5, 20, 100}, {6, 30, 50}} {
+		serialization.WriteUint32(old, lock.lock)
+		serialization.WriteUint32(old, lock.unlock)
+		lock.amount.Serialize(old)
+	}
+
+	st := NewMemStore()
+	key := append([]byte{byte(ST_Locked)}, make([]byte, 52)...)
+	st.Put(key, old.Bytes())
+	bd := &ChainStore{st: st}
+	// a rerun after an interrupted migration finds the record upgraded
+	for run := 0; run < 2; run++ {
+		batch := &migrationBatch{st: st}
+		if err := migrateLockedAssets(bd, batch); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		if err := batch.commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var programHash Uint160
+	var assetID Uint256
+	locked, err := bd.GetLockedFromProgramHash(programHash, assetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locked) != 2 || locked[0].Unlock != 20 || locked[0].Amount != 100 || locked[0].Cliff != 20 ||
+		locked[1].Lock != 6 || locked[1].Unlock != 30 || locked[1].Amount != 50 || locked[1].Cliff != 30 {
+		t.Fatalf("unexpected locks after migration: %+v", locked)
+	}
+	if locked[0].Unvested(19) != 100 || locked[0].Unvested(20) != 0 {
+		t.Fatal("migrated lock does not unlock at once")
+	}
+}
+
+func TestMigrateAccountStates(t *testing.T) {
+	var programHash Uint160
+	balances := map[Uint256]Fixed64{{1}: 10, {2}: 20, {3}: 30}
+	key := append([]byte{byte(ST_ACCOUNT)}, programHash.ToArray()...)
+
+	// balances in the reverse of asset order, as map order could leave them
+	unsorted := bytes.NewBuffer(nil)
+	programHash.Serialize(unsorted)
+	serialization.WriteBool(unsorted, false)
+	serialization.WriteUint64(unsorted, uint64(len(balances)))
+	for _, assetID := range []Uint256{{3}, {2}, {1}} {
+		value := balances[assetID]
+		assetID.Serialize(unsorted)
+		value.Serialize(unsorted)
+	}
+	upgraded := NewMemStore()
+	upgraded.Put(key, unsorted.Bytes())
+	// root over the unsorted record left by an earlier start
+	staleRoot := Uint256{9}
+	upgraded.Put(stateRootKey(0), staleRoot.ToArray())
+	up, err := openStoreWithVersion(upgraded, 0x03)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer up.Close()
+	if err := up.loadStateRoot(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := NewMemStore()
+	replayed.Put(key, account.NewAccountState(programHash, balances).ToArray())
+	re, err := openStoreWithVersion(replayed, SchemaVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer re.Close()
+	if err := re.loadStateRoot(); err != nil {
+		t.Fatal(err)
+	}
+
+	if up.GetStateRoot() == (Uint256{}) || up.GetStateRoot() != re.GetStateRoot() {
+		t.Fatalf("state root %x of the upgraded store, %x of the replayed one", up.GetStateRoot(), re.GetStateRoot())
+	}
+}
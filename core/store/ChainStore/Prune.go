@@ -0,0 +1,115 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	"IPT/common/config"
+	"IPT/common/serialization"
+	. "IPT/core/ledger"
+	. "IPT/core/store"
+	tx "IPT/core/transaction"
+	"bytes"
+)
+
+// With PruneBlocks set, the bodies of fully spent transactions are discarded
+// once their block is more than PruneBlocks below the current block. Headers,
+// unspent outputs, state and the recent blocks are kept. A pruned transaction
+// keeps its DATA_Transaction key holding only the height, so the duplicate
+// check and GetTransactionHeight still work and getTx returns ErrPruned.
+// RegisterAsset transactions define their asset and are always kept, as are
+// the transactions whose payload is the data they notarize: Record, DataFile
+// and PrivacyPayload have no outputs and would count as fully spent.
+
+// keepsBody reports whether transactions of txType are never pruned.
+func keepsBody(txType tx.TransactionType) bool {
+	switch txType {
+	case tx.RegisterAsset, tx.Record, tx.DataFile, tx.PrivacyPayload:
+		return true
+	}
+	return false
+}
+
+func (bd *ChainStore) pruneTx(txid Uint256) error {
+	key := append([]byte{byte(DATA_Transaction)}, txid.ToArray()...)
+	data, err := bd.st.Get(key)
+	if err != nil || len(data) <= 4 {
+		// unknown or already pruned
+		return nil
+	}
+	// the height is followed by the tx type
+	if keepsBody(tx.TransactionType(data[4])) {
+		return nil
+	}
+	return bd.batchPut(key, data[:4])
+}
+
+// blockTxHashes returns the transaction hashes of the block at height
+// without reading the bodies.
+func (bd *ChainStore) blockTxHashes(height uint32) ([]Uint256, error) {
+	hash, err := bd.GetBlockHash(height)
+	if err != nil {
+		return nil, err
+	}
+	data, err := bd.st.Get(append([]byte{byte(DATA_Header)}, hash.ToArray()...))
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(data)
+	// first 8 bytes is sys_fee
+	if _, err := serialization.ReadUint64(r); err != nil {
+		return nil, err
+	}
+	b := new(Block)
+	if err := b.FromTrimmedData(r); err != nil {
+		return nil, err
+	}
+	hashes := make([]Uint256, len(b.Transactions))
+	for i, t := range b.Transactions {
+		hashes[i] = t.Hash()
+	}
+	return hashes, nil
+}
+
+// prune runs in persist of the block at height, unspents holds the unspent
+// outputs this block changed. The pruned bodies are journaled, rolling the
+// block back restores them.
+func (bd *ChainStore) prune(height uint32, unspents map[Uint256][]uint16) error {
+	window := config.Parameters.PruneBlocks
+	if window == 0 || height < window {
+		return nil
+	}
+
+	// transactions this block spent completely
+	for txid, indexes := range unspents {
+		if len(indexes) > 0 {
+			continue
+		}
+		txHeight, err := bd.GetTransactionHeight(txid)
+		if err != nil || txHeight+window > height {
+			continue
+		}
+		if err := bd.pruneTx(txid); err != nil {
+			return err
+		}
+	}
+
+	// the block leaving the window
+	hashes, err := bd.blockTxHashes(height - window)
+	if err != nil {
+		return err
+	}
+	for _, txid := range hashes {
+		if indexes, ok := unspents[txid]; ok {
+			if len(indexes) > 0 {
+				continue
+			}
+		} else if _, err := bd.st.Get(append([]byte{byte(IX_Unspent)}, txid.ToArray()...)); err == nil {
+			continue
+		}
+		if err := bd.pruneTx(txid); err != nil {
+			return err
+		}
+	}
+
+	// blocks below the window can no longer be rolled back
+	return bd.st.BatchDelete(undoKey(height - window))
+}
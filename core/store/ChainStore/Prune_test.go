@@ -0,0 +1,64 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	"IPT/common/config"
+	"IPT/core/ledger"
+	tx "IPT/core/transaction"
+	"IPT/core/transaction/payload"
+	"testing"
+)
+
+func TestPrune(t *testing.T) {
+	bd, _ := newTestChainStore(t)
+	defer bd.Close()
+	config.Parameters.PruneBlocks = 2
+	defer func() { config.Parameters.PruneBlocks = 0 }()
+
+	spent := transfer(nil, 1)
+	record := &tx.Transaction{
+		TxType:  tx.Record,
+		Payload: &payload.Record{RecordType: "notary", RecordData: []byte(`{"Hash": "document hash"}`)},
+	}
+	mustPersist(t, bd, spent, record)
+	unspent := transfer(&tx.UTXOTxInput{ReferTxID: spent.Hash(), ReferTxOutputIndex: 0}, 2)
+	mustPersist(t, bd, unspent)
+	if _, err := bd.GetTransaction(spent.Hash()); err != nil {
+		t.Fatalf("spent transaction pruned inside the window: %v", err)
+	}
+
+	mustPersist(t, bd)
+	if _, err := bd.GetTransaction(spent.Hash()); err != ledger.ErrPruned {
+		t.Fatalf("spent transaction not pruned: %v", err)
+	}
+	if !bd.IsTxHashDuplicate(spent.Hash()) {
+		t.Fatal("pruned transaction is not a duplicate")
+	}
+	if _, err := bd.GetTransaction(record.Hash()); err != nil {
+		t.Fatalf("record transaction pruned: %v", err)
+	}
+
+	mustPersist(t, bd)
+	if _, err := bd.GetTransaction(unspent.Hash()); err != nil {
+		t.Fatalf("unspent transaction pruned: %v", err)
+	}
+
+	if err := bd.RollbackTo(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bd.GetTransaction(spent.Hash()); err != nil {
+		t.Fatalf("rollback did not restore the pruned transaction: %v", err)
+	}
+}
+
+func transfer(input *tx.UTXOTxInput, to byte) *tx.Transaction {
+	t := &tx.Transaction{
+		TxType:  tx.TransferAsset,
+		Payload: &payload.TransferAsset{},
+		Outputs: []*tx.TxOutput{{AssetID: Uint256{1}, Value: 10, ProgramHash: Uint160{to}}},
+	}
+	if input != nil {
+		t.UTXOInputs = []*tx.UTXOTxInput{input}
+	}
+	return t
+}
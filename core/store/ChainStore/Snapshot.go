@@ -0,0 +1,401 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	"IPT/common/log"
+	"IPT/common/serialization"
+	. "IPT/core/ledger"
+	. "IPT/core/store"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	SnapshotVersion uint32 = 0x02
+
+	snapshotEntry byte = 0x01
+	snapshotEnd   byte = 0x00
+)
+
+var snapshotMagic = []byte("IPTSNAP")
+
+type snapshotTask struct {
+	height uint32
+	w      io.Writer
+	done   chan error
+}
+
+// isSnapshotPrefix reports whether entries under prefix belong to the ledger
+// state carried by a snapshot. The header hash list is rebuilt from
+// DATA_BlockHash on import instead, and the state tree from the state
+// entries.
+func isSnapshotPrefix(prefix byte) bool {
+	if prefix == byte(IX_HeaderHashList) || prefix == byte(IX_StateNode) {
+		return false
+	}
+	return (prefix >= byte(IX_HeaderHashList) && prefix < byte(CFG_Version)) ||
+		prefix == byte(SYS_CurrentBookKeeper)
+}
+
+func writeSnapshotEntry(w io.Writer, key []byte, value []byte) error {
+	if err := serialization.WriteByte(w, snapshotEntry); err != nil {
+		return err
+	}
+	if err := serialization.WriteVarBytes(w, key); err != nil {
+		return err
+	}
+	return serialization.WriteVarBytes(w, value)
+}
+
+// ExportSnapshot writes the state and header chain at height to w. State newer
+// than height is reverted in memory with the undo records of the later blocks.
+func (bd *ChainStore) ExportSnapshot(height uint32, w io.Writer) error {
+	done := make(chan error)
+	bd.taskCh <- &snapshotTask{height: height, w: w, done: done}
+	return <-done
+}
+
+// can only be invoked by backend write goroutine
+func (bd *ChainStore) handleSnapshotTask(height uint32, w io.Writer) error {
+	if height > bd.currentBlockHeight {
+		return errors.New(fmt.Sprintf("[ExportSnapshot] height %d is above current height %d", height, bd.currentBlockHeight))
+	}
+
+	// values the state keys held at height
+	overlay := make(map[string]*undoEntry)
+	for h := bd.currentBlockHeight; h > height; h-- {
+		data, err := bd.st.Get(undoKey(h))
+		if err != nil {
+			return fmt.Errorf("[ExportSnapshot] no undo record for block at height %d: %v", h, err)
+		}
+		j := newUndoJournal()
+		if err := j.Deserialize(bytes.NewReader(data)); err != nil {
+			return err
+		}
+		for _, e := range j.entries {
+			overlay[string(e.Key)] = e
+		}
+	}
+
+	blockHash, err := bd.GetBlockHash(height)
+	if err != nil {
+		return err
+	}
+
+	hasher := sha256.New()
+	mw := io.MultiWriter(w, hasher)
+
+	// header
+	if _, err := mw.Write(snapshotMagic); err != nil {
+		return err
+	}
+	serialization.WriteUint32(mw, SnapshotVersion)
+	serialization.WriteUint32(mw, height)
+	if _, err := blockHash.Serialize(mw); err != nil {
+		return err
+	}
+
+	// state
+	unspentTxs := make([]Uint256, 0)
+	emit := func(key []byte, value []byte) error {
+		if key[0] == byte(IX_Unspent) {
+			txid, err := Uint256ParseFromBytes(key[1:])
+			if err != nil {
+				return err
+			}
+			unspentTxs = append(unspentTxs, txid)
+		}
+		return writeSnapshotEntry(mw, key, value)
+	}
+
+	iter := bd.st.NewIterator(nil)
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) == 0 || !isSnapshotPrefix(key[0]) {
+			continue
+		}
+		value := iter.Value()
+		if e, ok := overlay[string(key)]; ok {
+			delete(overlay, string(key))
+			if !e.Existed {
+				continue
+			}
+			value = e.Value
+		}
+		if err := emit(key, value); err != nil {
+			iter.Release()
+			return err
+		}
+	}
+	iter.Release()
+
+	// keys removed after height
+	for _, e := range overlay {
+		if !e.Existed || len(e.Key) == 0 || !isSnapshotPrefix(e.Key[0]) {
+			continue
+		}
+		if err := emit(e.Key, e.Value); err != nil {
+			return err
+		}
+	}
+
+	// transactions the unspent outputs refer to
+	for _, txid := range unspentTxs {
+		key := append([]byte{byte(DATA_Transaction)}, txid.ToArray()...)
+		value, err := bd.st.Get(key)
+		if err != nil {
+			return err
+		}
+		if err := writeSnapshotEntry(mw, key, value); err != nil {
+			return err
+		}
+	}
+
+	// header chain
+	for h := uint32(0); h <= height; h++ {
+		hash, err := bd.GetBlockHash(h)
+		if err != nil {
+			return err
+		}
+		bhash := bytes.NewBuffer(nil)
+		bhash.WriteByte(byte(DATA_BlockHash))
+		serialization.WriteUint32(bhash, h)
+		if err := writeSnapshotEntry(mw, bhash.Bytes(), hash.ToArray()); err != nil {
+			return err
+		}
+
+		headerKey := append([]byte{byte(DATA_Header)}, hash.ToArray()...)
+		header, err := bd.st.Get(headerKey)
+		if err != nil {
+			return err
+		}
+		if err := writeSnapshotEntry(mw, headerKey, header); err != nil {
+			return err
+		}
+	}
+
+	if err := serialization.WriteByte(mw, snapshotEnd); err != nil {
+		return err
+	}
+	_, err = w.Write(hasher.Sum(nil))
+
+	return err
+}
+
+// ImportSnapshot loads a snapshot written by ExportSnapshot into an empty
+// store and returns the height it was taken at. The entries are committed in
+// chunks, the store is only marked initialized once the checksum matches and
+// the block hash of the snapshot heads the header chain it carries. A failed
+// import is cleared from the store.
+func ImportSnapshot(st IStore, r io.Reader) (uint32, error) {
+	if err := clearStore(st); err != nil {
+		return 0, err
+	}
+	height, blockHash, err := importSnapshotEntries(st, r)
+	if err != nil {
+		if cerr := clearStore(st); cerr != nil {
+			log.Error("[ImportSnapshot] clear the failed import: ", cerr)
+		}
+		return 0, err
+	}
+
+	currentBlock := bytes.NewBuffer(nil)
+	blockHash.Serialize(currentBlock)
+	serialization.WriteUint32(currentBlock, height)
+	st.NewBatch()
+	st.BatchPut([]byte{byte(SYS_CurrentBlock)}, currentBlock.Bytes())
+	st.BatchPut([]byte{byte(CFG_Version)}, []byte{SchemaVersion})
+	if err := st.BatchCommit(); err != nil {
+		return 0, err
+	}
+
+	return height, nil
+}
+
+func importSnapshotEntries(st IStore, r io.Reader) (uint32, Uint256, error) {
+	var blockHash Uint256
+	hasher := sha256.New()
+	tr := io.TeeReader(r, hasher)
+
+	magic, err := serialization.ReadBytes(tr, uint64(len(snapshotMagic)))
+	if err != nil {
+		return 0, blockHash, err
+	}
+	if !bytes.Equal(magic, snapshotMagic) {
+		return 0, blockHash, errors.New("[ImportSnapshot] not a snapshot file")
+	}
+	version, err := serialization.ReadUint32(tr)
+	if err != nil {
+		return 0, blockHash, err
+	}
+	if version != SnapshotVersion {
+		return 0, blockHash, errors.New(fmt.Sprintf("[ImportSnapshot] unsupported snapshot version %d", version))
+	}
+	height, err := serialization.ReadUint32(tr)
+	if err != nil {
+		return 0, blockHash, err
+	}
+	if err := blockHash.Deserialize(tr); err != nil {
+		return 0, blockHash, err
+	}
+
+	chain := newSnapshotChain()
+	batch := &migrationBatch{st: st}
+	for {
+		tag, err := serialization.ReadByte(tr)
+		if err != nil {
+			return 0, blockHash, err
+		}
+		if tag == snapshotEnd {
+			break
+		}
+		if tag != snapshotEntry {
+			return 0, blockHash, errors.New("[ImportSnapshot] malformed snapshot entry")
+		}
+		key, err := serialization.ReadVarBytes(tr)
+		if err != nil {
+			return 0, blockHash, err
+		}
+		value, err := serialization.ReadVarBytes(tr)
+		if err != nil {
+			return 0, blockHash, err
+		}
+		if err := chain.add(key, value); err != nil {
+			return 0, blockHash, err
+		}
+		if err := batch.put(key, value); err != nil {
+			return 0, blockHash, err
+		}
+	}
+	if err := batch.commit(); err != nil {
+		return 0, blockHash, err
+	}
+
+	sum := hasher.Sum(nil)
+	checksum, err := serialization.ReadBytes(r, uint64(len(sum)))
+	if err != nil {
+		return 0, blockHash, err
+	}
+	if !bytes.Equal(sum, checksum) {
+		return 0, blockHash, errors.New("[ImportSnapshot] snapshot checksum mismatch")
+	}
+	if err := chain.verify(height, blockHash); err != nil {
+		return 0, blockHash, err
+	}
+
+	return height, blockHash, nil
+}
+
+// snapshotChain collects the header chain of a snapshot to check that it
+// leads to the block the snapshot was taken at.
+type snapshotChain struct {
+	hashes  map[uint32]Uint256
+	headers map[Uint256]*Blockdata
+}
+
+func newSnapshotChain() *snapshotChain {
+	return &snapshotChain{
+		hashes:  make(map[uint32]Uint256),
+		headers: make(map[Uint256]*Blockdata),
+	}
+}
+
+func (c *snapshotChain) add(key []byte, value []byte) error {
+	switch {
+	case len(key) > 0 && key[0] == byte(DATA_BlockHash):
+		r := bytes.NewReader(key[1:])
+		height, err := serialization.ReadUint32(r)
+		if err != nil {
+			return err
+		}
+		hash, err := Uint256ParseFromBytes(value)
+		if err != nil {
+			return err
+		}
+		c.hashes[height] = hash
+	case len(key) > 0 && key[0] == byte(DATA_Header):
+		hash, err := Uint256ParseFromBytes(key[1:])
+		if err != nil {
+			return err
+		}
+		r := bytes.NewReader(value)
+		// first 8 bytes is sys_fee
+		if _, err := serialization.ReadUint64(r); err != nil {
+			return err
+		}
+		h := new(Header)
+		if err := h.Deserialize(r); err != nil {
+			return err
+		}
+		if h.Blockdata.Hash() != hash {
+			return errors.New(fmt.Sprintf("[ImportSnapshot] header stored under %x has hash %x", hash, h.Blockdata.Hash()))
+		}
+		c.headers[hash] = h.Blockdata
+	}
+	return nil
+}
+
+// verify checks that the headers from the genesis block to height are linked
+// and that the last one is blockHash.
+func (c *snapshotChain) verify(height uint32, blockHash Uint256) error {
+	var prev Uint256
+	for h := uint32(0); h <= height; h++ {
+		hash, ok := c.hashes[h]
+		if !ok {
+			return errors.New(fmt.Sprintf("[ImportSnapshot] no block hash at height %d", h))
+		}
+		header, ok := c.headers[hash]
+		if !ok {
+			return errors.New(fmt.Sprintf("[ImportSnapshot] no header of block %x", hash))
+		}
+		if header.Height != h || (h > 0 && header.PrevBlockHash != prev) {
+			return errors.New(fmt.Sprintf("[ImportSnapshot] header chain broken at height %d", h))
+		}
+		prev = hash
+	}
+	if prev != blockHash {
+		return errors.New(fmt.Sprintf("[ImportSnapshot] snapshot block %x is not at the head of its header chain", blockHash))
+	}
+	return nil
+}
+
+// clearStore deletes every entry of st in chunks.
+func clearStore(st IStore) error {
+	batch := &migrationBatch{st: st}
+	iter := st.NewIterator(nil)
+	defer iter.Release()
+	for iter.Next() {
+		if err := batch.delete(append([]byte{}, iter.Key()...)); err != nil {
+			return err
+		}
+	}
+	return batch.commit()
+}
+
+// importSnapshotFile bootstraps an uninitialized store from file. A store that
+// already holds a chain is left untouched.
+func (bd *ChainStore) importSnapshotFile(file string) error {
+	if _, err := bd.st.Get([]byte{byte(CFG_Version)}); err == nil {
+		log.Info("ledger store already initialized, skip snapshot ", file)
+		return nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	height, err := ImportSnapshot(bd.st, bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	log.Infof("snapshot %s imported, syncing from height %d", file, height+1)
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package ChainStore
+
+import (
+	. "IPT/core/store/MemStore"
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestImportSnapshot(t *testing.T) {
+	bd, _ := newTestChainStore(t)
+	defer bd.Close()
+
+	buf := bytes.NewBuffer(nil)
+	if err := bd.ExportSnapshot(0, buf); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := buf.Bytes()
+
+	st := NewMemStore()
+	if height, err := ImportSnapshot(st, bytes.NewReader(snapshot)); err != nil || height != 0 {
+		t.Fatalf("import: %d %v", height, err)
+	}
+
+	// a block hash the header chain does not lead to, with a valid checksum
+	forged := append([]byte{}, snapshot[:len(snapshot)-sha256.Size]...)
+	forged[len(snapshotMagic)+8] ^= 0xff
+	sum := sha256.Sum256(forged)
+	forged = append(forged, sum[:]...)
+
+	st = NewMemStore()
+	if _, err := ImportSnapshot(st, bytes.NewReader(forged)); err == nil {
+		t.Fatal("snapshot of a block outside its header chain imported")
+	}
+	iter := st.NewIterator(nil)
+	defer iter.Release()
+	if iter.Next() {
+		t.Fatal("failed import left entries in the store")
+	}
+}
@@ -0,0 +1,381 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	"IPT/common/log"
+	"IPT/common/serialization"
+	. "IPT/core/store"
+	"IPT/crypto"
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// stateNode is a node of the sparse Merkle tree over the ST_ entries, stored
+// under IX_StateNode + its hash. Nodes are shared between the trees of
+// consecutive blocks and never deleted, the trees of earlier heights stay
+// available for RollbackTo. They are not part of a snapshot, the importing
+// node builds the tree of the snapshot height from the state entries.
+type stateNode struct {
+	leaf bool
+	// leaf: key hash and value hash, otherwise the children hashes
+	a, b Uint256
+}
+
+func (n *stateNode) hash() Uint256 {
+	if n.leaf {
+		return crypto.StateLeafHash(n.a, n.b)
+	}
+	return crypto.StateNodeHash(n.a, n.b)
+}
+
+func (n *stateNode) bytes() []byte {
+	w := bytes.NewBuffer(nil)
+	serialization.WriteBool(w, n.leaf)
+	n.a.Serialize(w)
+	n.b.Serialize(w)
+	return w.Bytes()
+}
+
+func isStatePrefix(prefix byte) bool {
+	return prefix >= byte(ST_Info) && prefix < byte(ST_Info)+0x10
+}
+
+func stateNodeKey(hash Uint256) []byte {
+	return append([]byte{byte(IX_StateNode)}, hash.ToArray()...)
+}
+
+func stateRootKey(height uint32) []byte {
+	key := bytes.NewBuffer(nil)
+	key.WriteByte(byte(DATA_StateRoot))
+	serialization.WriteUint32(key, height)
+	return key.Bytes()
+}
+
+// stateTree applies updates to the tree rooted at root. New nodes stay in
+// pending until they are written to the store.
+type stateTree struct {
+	st      IStore
+	root    Uint256
+	pending map[Uint256]*stateNode
+}
+
+func newStateTree(st IStore, root Uint256) *stateTree {
+	return &stateTree{
+		st:      st,
+		root:    root,
+		pending: make(map[Uint256]*stateNode),
+	}
+}
+
+func (t *stateTree) get(hash Uint256) (*stateNode, error) {
+	if n, ok := t.pending[hash]; ok {
+		return n, nil
+	}
+	data, err := t.st.Get(stateNodeKey(hash))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("[stateTree] missing node %x: %v", hash, err))
+	}
+	r := bytes.NewReader(data)
+	n := new(stateNode)
+	if n.leaf, err = serialization.ReadBool(r); err != nil {
+		return nil, err
+	}
+	if err := n.a.Deserialize(r); err != nil {
+		return nil, err
+	}
+	if err := n.b.Deserialize(r); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (t *stateTree) put(n *stateNode) Uint256 {
+	hash := n.hash()
+	t.pending[hash] = n
+	return hash
+}
+
+func (t *stateTree) isLeaf(hash Uint256) (bool, error) {
+	if hash == (Uint256{}) {
+		return false, nil
+	}
+	n, err := t.get(hash)
+	if err != nil {
+		return false, err
+	}
+	return n.leaf, nil
+}
+
+// node keeps a subtree holding a single leaf collapsed into that leaf
+func (t *stateTree) node(left Uint256, right Uint256) (Uint256, error) {
+	empty := Uint256{}
+	if left == empty && right == empty {
+		return empty, nil
+	}
+	if left == empty || right == empty {
+		child := left
+		if child == empty {
+			child = right
+		}
+		leaf, err := t.isLeaf(child)
+		if err != nil {
+			return empty, err
+		}
+		if leaf {
+			return child, nil
+		}
+	}
+	return t.put(&stateNode{a: left, b: right}), nil
+}
+
+// split places two leaves below depth, down to the first bit they differ in
+func (t *stateTree) split(a Uint256, aKey Uint256, b Uint256, bKey Uint256, depth int) Uint256 {
+	empty := Uint256{}
+	abit := crypto.StatePathBit(aKey, depth)
+	if abit != crypto.StatePathBit(bKey, depth) {
+		if abit == 0 {
+			return t.put(&stateNode{a: a, b: b})
+		}
+		return t.put(&stateNode{a: b, b: a})
+	}
+	child := t.split(a, aKey, b, bKey, depth+1)
+	if abit == 0 {
+		return t.put(&stateNode{a: child, b: empty})
+	}
+	return t.put(&stateNode{a: empty, b: child})
+}
+
+func (t *stateTree) update(hash Uint256, depth int, keyHash Uint256, value []byte) (Uint256, error) {
+	empty := Uint256{}
+	if hash == empty {
+		if value == nil {
+			return empty, nil
+		}
+		return t.put(&stateNode{leaf: true, a: keyHash, b: crypto.StateValueHash(value)}), nil
+	}
+
+	n, err := t.get(hash)
+	if err != nil {
+		return empty, err
+	}
+	if n.leaf {
+		if n.a == keyHash {
+			if value == nil {
+				return empty, nil
+			}
+			return t.put(&stateNode{leaf: true, a: keyHash, b: crypto.StateValueHash(value)}), nil
+		}
+		if value == nil {
+			return hash, nil
+		}
+		leaf := t.put(&stateNode{leaf: true, a: keyHash, b: crypto.StateValueHash(value)})
+		return t.split(hash, n.a, leaf, keyHash, depth), nil
+	}
+
+	left, right := n.a, n.b
+	if crypto.StatePathBit(keyHash, depth) == 0 {
+		left, err = t.update(left, depth+1, keyHash, value)
+	} else {
+		right, err = t.update(right, depth+1, keyHash, value)
+	}
+	if err != nil {
+		return empty, err
+	}
+	return t.node(left, right)
+}
+
+// Update sets key to value, a nil value removes key.
+func (t *stateTree) Update(key []byte, value []byte) error {
+	root, err := t.update(t.root, 0, crypto.StateKeyHash(key), value)
+	if err != nil {
+		return err
+	}
+	t.root = root
+	return nil
+}
+
+func (t *stateTree) Prove(key []byte) (*crypto.StateProof, error) {
+	keyHash := crypto.StateKeyHash(key)
+	proof := &crypto.StateProof{}
+	hash := t.root
+	for depth := 0; hash != (Uint256{}); depth++ {
+		n, err := t.get(hash)
+		if err != nil {
+			return nil, err
+		}
+		if n.leaf {
+			proof.LeafKey = n.a
+			proof.LeafValue = n.b
+			break
+		}
+		if crypto.StatePathBit(keyHash, depth) == 0 {
+			proof.Siblings = append(proof.Siblings, n.b)
+			hash = n.a
+		} else {
+			proof.Siblings = append(proof.Siblings, n.a)
+			hash = n.b
+		}
+	}
+	return proof, nil
+}
+
+// commit writes the pending nodes still reachable from the root with put.
+func (t *stateTree) commit(put func(key []byte, value []byte) error) error {
+	var save func(hash Uint256) error
+	save = func(hash Uint256) error {
+		n, ok := t.pending[hash]
+		if !ok {
+			return nil
+		}
+		delete(t.pending, hash)
+		if err := put(stateNodeKey(hash), n.bytes()); err != nil {
+			return err
+		}
+		if n.leaf {
+			return nil
+		}
+		if err := save(n.a); err != nil {
+			return err
+		}
+		return save(n.b)
+	}
+	return save(t.root)
+}
+
+// recordStateChange remembers the last value written to a state key during
+// persist, nil for a delete.
+func (bd *ChainStore) recordStateChange(key []byte, value []byte) {
+	if bd.stateChanges == nil || len(key) == 0 || !isStatePrefix(key[0]) {
+		return
+	}
+	if value != nil {
+		value = append([]byte{}, value...)
+	}
+	bd.stateChanges[string(key)] = value
+}
+
+// can only be invoked by backend write goroutine
+func (bd *ChainStore) commitStateChanges(height uint32) (Uint256, error) {
+	keys := make([]string, 0, len(bd.stateChanges))
+	for k := range bd.stateChanges {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tree := newStateTree(bd.st, bd.stateRoot)
+	for _, k := range keys {
+		if err := tree.Update([]byte(k), bd.stateChanges[k]); err != nil {
+			return Uint256{}, err
+		}
+	}
+
+	if err := tree.commit(bd.batchPut); err != nil {
+		return Uint256{}, err
+	}
+	if err := bd.batchPut(stateRootKey(height), tree.root.ToArray()); err != nil {
+		return Uint256{}, err
+	}
+
+	return tree.root, nil
+}
+
+// loadStateRoot reads the state root of the current block and builds the
+// tree from the state entries when the store predates it.
+func (bd *ChainStore) loadStateRoot() error {
+	data, err := bd.st.Get(stateRootKey(bd.currentBlockHeight))
+	if err == nil {
+		root, err := Uint256ParseFromBytes(data)
+		if err != nil {
+			return err
+		}
+		bd.mu.Lock()
+		bd.stateRoot = root
+		bd.mu.Unlock()
+		return nil
+	}
+
+	log.Info("building state tree at height ", bd.currentBlockHeight)
+	tree := newStateTree(bd.st, Uint256{})
+	iter := bd.st.NewIterator(nil)
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) == 0 || !isStatePrefix(key[0]) {
+			continue
+		}
+		if err := tree.Update(key, iter.Value()); err != nil {
+			iter.Release()
+			return err
+		}
+	}
+	iter.Release()
+
+	bd.st.NewBatch()
+	if err := tree.commit(bd.st.BatchPut); err != nil {
+		return err
+	}
+	bd.st.BatchPut(stateRootKey(bd.currentBlockHeight), tree.root.ToArray())
+	if err := bd.st.BatchCommit(); err != nil {
+		return err
+	}
+	bd.mu.Lock()
+	bd.stateRoot = tree.root
+	bd.mu.Unlock()
+
+	return nil
+}
+
+// dropStateTree removes the state roots and tree nodes, loadStateRoot builds
+// the tree of the current block again from the state entries.
+func (bd *ChainStore) dropStateTree() error {
+	batch := &migrationBatch{st: bd.st}
+	for _, prefix := range []DataEntryPrefix{DATA_StateRoot, IX_StateNode} {
+		iter := bd.st.NewIterator([]byte{byte(prefix)})
+		for iter.Next() {
+			if err := batch.delete(append([]byte{}, iter.Key()...)); err != nil {
+				iter.Release()
+				return err
+			}
+		}
+		iter.Release()
+	}
+	return batch.commit()
+}
+
+// GetStateRoot returns the root of the state after the current block.
+func (bd *ChainStore) GetStateRoot() Uint256 {
+	bd.mu.RLock()
+	defer bd.mu.RUnlock()
+
+	return bd.stateRoot
+}
+
+// GetStateProof returns the current value of a state key, nil when absent,
+// with its proof against the state root of height.
+func (bd *ChainStore) GetStateProof(key []byte) ([]byte, uint32, Uint256, *crypto.StateProof, error) {
+	if len(key) == 0 || !isStatePrefix(key[0]) {
+		return nil, 0, Uint256{}, nil, errors.New("[GetStateProof] not a state key")
+	}
+
+	// a block may be persisted meanwhile, retry until value and root agree
+	for i := 0; i < 3; i++ {
+		bd.mu.RLock()
+		root, height := bd.stateRoot, bd.currentBlockHeight
+		bd.mu.RUnlock()
+
+		value, err := bd.st.Get(key)
+		if err != nil {
+			value = nil
+		}
+		proof, err := newStateTree(bd.st, root).Prove(key)
+		if err != nil {
+			return nil, 0, Uint256{}, nil, err
+		}
+		if proof.Verify(key, value, root) {
+			return value, height, root, proof, nil
+		}
+	}
+
+	return nil, 0, Uint256{}, nil, errors.New("[GetStateProof] state changed during the query")
+}
@@ -0,0 +1,81 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	. "IPT/core/store"
+	. "IPT/core/store/MemStore"
+	"fmt"
+	"testing"
+)
+
+func buildStateTree(t *testing.T, st IStore, keys [][]byte) *stateTree {
+	tree := newStateTree(st, Uint256{})
+	for _, k := range keys {
+		if err := tree.Update(k, append([]byte("value of "), k...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tree.commit(st.Put); err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestStateTree(t *testing.T) {
+	st := NewMemStore()
+	defer st.Close()
+
+	var keys [][]byte
+	for i := 0; i < 50; i++ {
+		keys = append(keys, []byte(fmt.Sprintf("%c%d", byte(ST_ACCOUNT), i)))
+	}
+	tree := buildStateTree(t, st, keys)
+
+	// the root only depends on the entries, not on the update order
+	var reversed [][]byte
+	for i := len(keys) - 1; i >= 0; i-- {
+		reversed = append(reversed, keys[i])
+	}
+	if root := buildStateTree(t, st, reversed).root; root != tree.root {
+		t.Fatalf("root %x differs from %x", root, tree.root)
+	}
+
+	for _, k := range keys {
+		proof, err := tree.Prove(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proof.Verify(k, append([]byte("value of "), k...), tree.root) {
+			t.Errorf("key %s not proven", k)
+		}
+		if proof.Verify(k, []byte("other value"), tree.root) || proof.Verify(k, nil, tree.root) {
+			t.Errorf("wrong value of key %s proven", k)
+		}
+	}
+	absent := []byte("absent")
+	proof, err := tree.Prove(absent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proof.Verify(absent, nil, tree.root) {
+		t.Error("absent key not proven")
+	}
+
+	// removing entries gives the tree of the remaining ones
+	for _, k := range keys[25:] {
+		if err := tree.Update(k, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if root := buildStateTree(t, st, keys[:25]).root; root != tree.root {
+		t.Fatalf("root %x after delete differs from %x", tree.root, root)
+	}
+	for _, k := range keys[:25] {
+		if err := tree.Update(k, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tree.root != (Uint256{}) {
+		t.Fatalf("empty tree has root %x", tree.root)
+	}
+}
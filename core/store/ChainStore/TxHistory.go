@@ -0,0 +1,119 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	. "IPT/core/store"
+	tx "IPT/core/transaction"
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// A history key is IX_TxHistory + programHash + height + tx index +
+// direction + assetId. Height and tx index are big endian so the keys of an
+// address sort in chain order.
+const txHistoryKeyLen = 1 + UINT160SIZE + 4 + 4 + 1 + UINT256SIZE
+
+func txHistoryPrefix(programHash Uint160) []byte {
+	return append([]byte{byte(IX_TxHistory)}, programHash.ToArray()...)
+}
+
+func txHistoryKey(programHash Uint160, height uint32, txIndex uint32, direction tx.TxDirection, assetId Uint256) []byte {
+	key := bytes.NewBuffer(txHistoryPrefix(programHash))
+	binary.Write(key, binary.BigEndian, height)
+	binary.Write(key, binary.BigEndian, txIndex)
+	key.WriteByte(byte(direction))
+	key.Write(assetId.ToArray())
+	return key.Bytes()
+}
+
+// addTxHistory sums value into the per address and asset amounts of a tx.
+func addTxHistory(amounts map[Uint160]map[Uint256]Fixed64, programHash Uint160, assetId Uint256, value Fixed64) {
+	if _, ok := amounts[programHash]; !ok {
+		amounts[programHash] = make(map[Uint256]Fixed64)
+	}
+	amounts[programHash][assetId] += value
+}
+
+// saveTxHistory writes the history entries of a tx with put.
+func saveTxHistory(put func(key []byte, value []byte) error, height uint32, txIndex uint32, txid Uint256, txType tx.TransactionType, direction tx.TxDirection, amounts map[Uint160]map[Uint256]Fixed64) error {
+	for programHash, assets := range amounts {
+		for assetId, value := range assets {
+			th := &tx.TxHistory{Txid: txid, TxType: txType, Value: value}
+			w := bytes.NewBuffer(nil)
+			if err := th.Serialize(w); err != nil {
+				return err
+			}
+			// BATCH PUT VALUE
+			if err := put(txHistoryKey(programHash, height, txIndex, direction, assetId), w.Bytes()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetTxHistory returns up to limit history entries of programHash, newest
+// first. A nil assetId or txType or a zero direction matches every entry. Pass
+// the returned cursor to get the next page, it is nil after the last page.
+func (bd *ChainStore) GetTxHistory(programHash Uint160, assetId *Uint256, direction tx.TxDirection, txType *tx.TransactionType, cursor []byte, limit int) ([]*tx.TxHistory, []byte, error) {
+	prefix := txHistoryPrefix(programHash)
+	if cursor != nil && len(prefix)+len(cursor) != txHistoryKeyLen {
+		return nil, nil, errors.New("[GetTxHistory] invalid cursor")
+	}
+
+	iter := bd.st.NewIterator(prefix)
+	defer iter.Release()
+
+	// the cursor is the key of the last entry returned, continue below it
+	var ok bool
+	if cursor == nil {
+		ok = iter.Last()
+	} else if iter.Seek(append(prefix, cursor...)) {
+		ok = iter.Prev()
+	} else {
+		ok = iter.Last()
+	}
+
+	history := make([]*tx.TxHistory, 0)
+	var last, next []byte
+	for ; ok; ok = iter.Prev() {
+		key := iter.Key()
+		if len(key) != txHistoryKeyLen {
+			continue
+		}
+		th := new(tx.TxHistory)
+		r := bytes.NewReader(key[len(prefix):])
+		binary.Read(r, binary.BigEndian, &th.Height)
+		var txIndex uint32
+		binary.Read(r, binary.BigEndian, &txIndex)
+		d, _ := r.ReadByte()
+		th.Direction = tx.TxDirection(d)
+		if err := th.AssetID.Deserialize(r); err != nil {
+			return nil, nil, err
+		}
+		if direction != 0 && th.Direction != direction {
+			continue
+		}
+		if assetId != nil && th.AssetID != *assetId {
+			continue
+		}
+		if err := th.Deserialize(bytes.NewReader(iter.Value())); err != nil {
+			return nil, nil, err
+		}
+		if txType != nil && th.TxType != *txType {
+			continue
+		}
+
+		// another entry matches, the page is not the last one
+		if len(history) == limit {
+			next = last
+			break
+		}
+		history = append(history, th)
+		last = append([]byte{}, key[len(prefix):]...)
+	}
+
+	return history, next, nil
+}
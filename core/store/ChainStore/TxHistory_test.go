@@ -0,0 +1,155 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	"IPT/core/asset"
+	tx "IPT/core/transaction"
+	"IPT/core/transaction/payload"
+	"testing"
+)
+
+// historyOf returns the txid and direction of the entries of a history page.
+func historyOf(history []*tx.TxHistory) []string {
+	entries := []string{}
+	for _, th := range history {
+		entries = append(entries, BytesToHexString(th.Txid.ToArrayReverse())[:8]+" "+th.Direction.String())
+	}
+	return entries
+}
+
+func TestTxHistory(t *testing.T) {
+	bd, bookKeeper := newTestChainStore(t)
+	defer bd.Close()
+	coin := registerAsset(t, bd, bookKeeper, "coin", asset.UTXO)
+	token := registerAsset(t, bd, bookKeeper, "token", asset.UTXO)
+	holder := Uint160{1}
+
+	issueCoin := &tx.Transaction{
+		TxType:  tx.IssueAsset,
+		Payload: &payload.IssueAsset{},
+		Outputs: []*tx.TxOutput{{AssetID: coin, Value: 100, ProgramHash: holder}},
+	}
+	mustPersist(t, bd, issueCoin)
+	issueToken := &tx.Transaction{
+		TxType:  tx.IssueAsset,
+		Payload: &payload.IssueAsset{},
+		Outputs: []*tx.TxOutput{{AssetID: token, Value: 50, ProgramHash: holder}},
+	}
+	mustPersist(t, bd, issueToken)
+	issuedToken := bd.GetHeight()
+	transfer := &tx.Transaction{
+		TxType:     tx.TransferAsset,
+		Payload:    &payload.TransferAsset{},
+		UTXOInputs: []*tx.UTXOTxInput{{ReferTxID: issueCoin.Hash(), ReferTxOutputIndex: 0}},
+		Outputs: []*tx.TxOutput{
+			{AssetID: coin, Value: 60, ProgramHash: Uint160{2}},
+			{AssetID: coin, Value: 40, ProgramHash: holder},
+		},
+	}
+	mustPersist(t, bd, transfer)
+
+	transferType, issueType := tx.TransferAsset, tx.IssueAsset
+	all := historyOf([]*tx.TxHistory{
+		{Txid: transfer.Hash(), Direction: tx.TxOutgoing},
+		{Txid: transfer.Hash(), Direction: tx.TxIncoming},
+		{Txid: issueToken.Hash(), Direction: tx.TxIncoming},
+		{Txid: issueCoin.Hash(), Direction: tx.TxIncoming},
+	})
+	cases := []struct {
+		name      string
+		assetId   *Uint256
+		direction tx.TxDirection
+		txType    *tx.TransactionType
+		want      []string
+	}{
+		{"all", nil, 0, nil, all},
+		{"coin", &coin, 0, nil, []string{all[0], all[1], all[3]}},
+		{"outgoing", nil, tx.TxOutgoing, nil, all[:1]},
+		{"issues", nil, 0, &issueType, all[2:]},
+		{"incoming transfers", nil, tx.TxIncoming, &transferType, all[1:2]},
+	}
+	for _, c := range cases {
+		// page through the matching entries one at a time, the cursor is
+		// nil after the last one
+		got := []string{}
+		var cursor []byte
+		for {
+			history, next, err := bd.GetTxHistory(holder, c.assetId, c.direction, c.txType, cursor, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, historyOf(history)...)
+			if next == nil {
+				break
+			}
+			if len(got) > len(c.want) {
+				t.Fatalf("%s: cursor after the last entry", c.name)
+			}
+			cursor = next
+		}
+		if len(got) != len(c.want) {
+			t.Fatalf("%s: %v, want %v", c.name, got, c.want)
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Fatalf("%s: %v, want %v", c.name, got, c.want)
+			}
+		}
+	}
+
+	if history, next, err := bd.GetTxHistory(holder, nil, 0, nil, nil, len(all)); err != nil || len(history) != len(all) || next != nil {
+		t.Fatalf("page of all entries: %d entries, cursor %x, %v", len(history), next, err)
+	}
+	if history, _, err := bd.GetTxHistory(holder, nil, 0, nil, nil, 1); err != nil || history[0].TxType != tx.TransferAsset || history[0].Value != 100 {
+		t.Fatalf("latest entry %v, %v", history, err)
+	}
+
+	// the cursor of the first page points at an entry the rollback removes
+	_, cursor, err := bd.GetTxHistory(holder, nil, 0, nil, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bd.RollbackTo(issuedToken); err != nil {
+		t.Fatal(err)
+	}
+	history, next, err := bd.GetTxHistory(holder, nil, 0, nil, cursor, 10)
+	if err != nil || next != nil {
+		t.Fatalf("page after the rollback: cursor %x, %v", next, err)
+	}
+	if got := historyOf(history); len(got) != 2 || got[0] != all[2] || got[1] != all[3] {
+		t.Fatalf("page after the rollback: %v, want %v", got, all[2:])
+	}
+}
+
+func TestMigrateTxHistoryTypes(t *testing.T) {
+	bd, bookKeeper := newTestChainStore(t)
+	defer bd.Close()
+	coin := registerAsset(t, bd, bookKeeper, "coin", asset.UTXO)
+	holder := Uint160{1}
+	mustPersist(t, bd, &tx.Transaction{
+		TxType:  tx.IssueAsset,
+		Payload: &payload.IssueAsset{},
+		Outputs: []*tx.TxOutput{{AssetID: coin, Value: 100, ProgramHash: holder}},
+	})
+
+	// drop the type from the entry, as the history stored it before
+	iter := bd.st.NewIterator(txHistoryPrefix(holder))
+	if !iter.Next() {
+		t.Fatal("no history entry")
+	}
+	key, value := append([]byte{}, iter.Key()...), append([]byte{}, iter.Value()[:txHistoryValueSize]...)
+	iter.Release()
+	bd.st.Put(key, value)
+
+	batch := &migrationBatch{st: bd.st}
+	if err := migrateTxHistoryTypes(bd, batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.commit(); err != nil {
+		t.Fatal(err)
+	}
+	history, _, err := bd.GetTxHistory(holder, nil, 0, nil, nil, 1)
+	if err != nil || len(history) != 1 || history[0].TxType != tx.IssueAsset {
+		t.Fatalf("migrated history %v, %v", history, err)
+	}
+}
@@ -0,0 +1,207 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	"IPT/common/serialization"
+	. "IPT/core/ledger"
+	. "IPT/core/store"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// undoEntry keeps the value a key held before a block was persisted.
+type undoEntry struct {
+	Key     []byte
+	Existed bool
+	Value   []byte
+}
+
+func (e *undoEntry) Serialize(w io.Writer) error {
+	if err := serialization.WriteVarBytes(w, e.Key); err != nil {
+		return err
+	}
+	if err := serialization.WriteBool(w, e.Existed); err != nil {
+		return err
+	}
+	return serialization.WriteVarBytes(w, e.Value)
+}
+
+func (e *undoEntry) Deserialize(r io.Reader) error {
+	var err error
+	if e.Key, err = serialization.ReadVarBytes(r); err != nil {
+		return err
+	}
+	if e.Existed, err = serialization.ReadBool(r); err != nil {
+		return err
+	}
+	// ReadVarBytes fails on an empty value at the end of the record
+	n, err := serialization.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		if e.Value, err = serialization.ReadBytes(r, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// undoJournal collects the previous values of every key written while a
+// block is persisted, so the block can be removed again by RollbackTo.
+type undoJournal struct {
+	entries []*undoEntry
+	seen    map[string]bool
+}
+
+func newUndoJournal() *undoJournal {
+	return &undoJournal{
+		entries: make([]*undoEntry, 0),
+		seen:    make(map[string]bool),
+	}
+}
+
+func (j *undoJournal) Serialize(w io.Writer) error {
+	if err := serialization.WriteVarUint(w, uint64(len(j.entries))); err != nil {
+		return err
+	}
+	for _, e := range j.entries {
+		if err := e.Serialize(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *undoJournal) Deserialize(r io.Reader) error {
+	num, err := serialization.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	j.entries = make([]*undoEntry, num)
+	for i := 0; i < int(num); i++ {
+		e := new(undoEntry)
+		if err := e.Deserialize(r); err != nil {
+			return err
+		}
+		j.entries[i] = e
+	}
+	return nil
+}
+
+func undoKey(height uint32) []byte {
+	key := bytes.NewBuffer(nil)
+	key.WriteByte(byte(DATA_Undo))
+	serialization.WriteUint32(key, height)
+	return key.Bytes()
+}
+
+// journal records the current value of key the first time it is written
+// during persist. It is a no-op outside of persist.
+func (bd *ChainStore) journal(key []byte) {
+	if bd.undo == nil || bd.undo.seen[string(key)] {
+		return
+	}
+	bd.undo.seen[string(key)] = true
+
+	entry := &undoEntry{Key: append([]byte{}, key...)}
+	if value, err := bd.st.Get(key); err == nil {
+		entry.Existed = true
+		entry.Value = value
+	}
+	bd.undo.entries = append(bd.undo.entries, entry)
+}
+
+func (bd *ChainStore) batchPut(key []byte, value []byte) error {
+	bd.journal(key)
+	bd.recordStateChange(key, value)
+	return bd.st.BatchPut(key, value)
+}
+
+func (bd *ChainStore) batchDelete(key []byte) error {
+	bd.journal(key)
+	bd.recordStateChange(key, nil)
+	return bd.st.BatchDelete(key)
+}
+
+// can only be invoked by backend write goroutine
+func (bd *ChainStore) undoBlock(height uint32) error {
+	key := undoKey(height)
+	data, err := bd.st.Get(key)
+	if err != nil {
+		return fmt.Errorf("no undo record for block at height %d: %v", height, err)
+	}
+
+	j := newUndoJournal()
+	if err := j.Deserialize(bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	bd.st.NewBatch()
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		e := j.entries[i]
+		if e.Existed {
+			bd.st.BatchPut(e.Key, e.Value)
+		} else {
+			bd.st.BatchDelete(e.Key)
+		}
+	}
+	bd.st.BatchDelete(key)
+
+	// drop the header hash list that still contains this block
+	storedHeaderCount := bd.storedHeaderCount
+	if storedHeaderCount > height {
+		storedHeaderCount -= HeaderHashListCount
+		hhlPrefix := bytes.NewBuffer(nil)
+		hhlPrefix.WriteByte(byte(IX_HeaderHashList))
+		serialization.WriteUint32(hhlPrefix, storedHeaderCount)
+		bd.st.BatchDelete(hhlPrefix.Bytes())
+	}
+
+	if err := bd.st.BatchCommit(); err != nil {
+		return err
+	}
+
+	bd.mu.Lock()
+	bd.storedHeaderCount = storedHeaderCount
+	bd.currentBlockHeight = height - 1
+	bd.mu.Unlock()
+
+	return bd.loadStateRoot()
+}
+
+// can only be invoked by backend write goroutine
+func (bd *ChainStore) handleRollbackTask(height uint32) error {
+	if height >= bd.currentBlockHeight {
+		return errors.New(fmt.Sprintf("[RollbackTo] target height %d is not below current height %d", height, bd.currentBlockHeight))
+	}
+
+	for bd.currentBlockHeight > height {
+		if err := bd.undoBlock(bd.currentBlockHeight); err != nil {
+			return err
+		}
+	}
+
+	// headers above the new tip descend from the removed blocks
+	bd.mu.Lock()
+	for h := range bd.headerIndex {
+		if h > height {
+			delete(bd.headerIndex, h)
+		}
+	}
+	bd.headerCache = map[Uint256]*Header{}
+	bd.blockCache = map[Uint256]*Block{}
+	bd.mu.Unlock()
+
+	return nil
+}
+
+// RollbackTo removes every persisted block above height by replaying the undo
+// records written in persist, newest first.
+func (bd *ChainStore) RollbackTo(height uint32) error {
+	done := make(chan error)
+	bd.taskCh <- &rollbackTask{height: height, done: done}
+	return <-done
+}
@@ -0,0 +1,631 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	"IPT/common/log"
+	"IPT/common/serialization"
+	"IPT/core/account"
+	"IPT/core/contract/program"
+	. "IPT/core/ledger"
+	. "IPT/core/store"
+	tx "IPT/core/transaction"
+	"IPT/core/transaction/payload"
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// storeReader reads the store, or a snapshot of it.
+type storeReader interface {
+	Get(key []byte) ([]byte, error)
+	NewIterator(prefix []byte) IIterator
+}
+
+// viewTask takes a snapshot of the store between two persisted blocks.
+type viewTask struct {
+	snapshot ISnapshot
+	height   uint32
+	done     chan error
+}
+
+type repairTask struct {
+	replay *ledgerReplay
+	done   chan error
+}
+
+// ledgerIndexes holds the indexes persist derives from the blocks.
+type ledgerIndexes struct {
+	unspents   map[Uint256][]uint16
+	utxos      map[Uint160]map[Uint256][]*tx.UTXOUnspent
+	balances   map[Uint160]map[Uint256]Fixed64
+	quantities map[Uint256]Fixed64
+}
+
+func newLedgerIndexes() *ledgerIndexes {
+	return &ledgerIndexes{
+		unspents:   make(map[Uint256][]uint16),
+		utxos:      make(map[Uint160]map[Uint256][]*tx.UTXOUnspent),
+		balances:   make(map[Uint160]map[Uint256]Fixed64),
+		quantities: make(map[Uint256]Fixed64),
+	}
+}
+
+// VerifyLedger replays the stored blocks and compares the unspent index, the
+// per address UTXO lists, the account balances and the issued quantities with
+// the result. Every difference is reported and, with repair, the stored entry
+// is rewritten from the replayed one. The blocks are replayed from a snapshot
+// of the store while new blocks are persisted, only the repair itself runs
+// between two blocks.
+func (bd *ChainStore) VerifyLedger(repair bool) ([]string, error) {
+	replay := newLedgerReplay(nil)
+	stored, err := bd.replayView(replay)
+	if err != nil {
+		return nil, err
+	}
+	mismatches, keys := compareIndexes(replay.idx, stored)
+	if !repair || len(keys) == 0 {
+		return mismatches, nil
+	}
+
+	// replay once more for the values the mismatched entries had before
+	// each block, the undo records get them so that RollbackTo does not
+	// restore the damaged ones
+	replay = newLedgerReplay(keys)
+	if _, err := bd.replayView(replay); err != nil {
+		return mismatches, err
+	}
+	task := &repairTask{replay: replay, done: make(chan error)}
+	bd.taskCh <- task
+	return mismatches, <-task.done
+}
+
+// replayView applies the blocks of a snapshot of the store to replay and
+// returns the indexes stored in the snapshot.
+func (bd *ChainStore) replayView(replay *ledgerReplay) (*ledgerIndexes, error) {
+	view := &viewTask{done: make(chan error)}
+	bd.taskCh <- view
+	if err := <-view.done; err != nil {
+		return nil, err
+	}
+	defer view.snapshot.Release()
+
+	if err := replay.run(bd, view.snapshot, view.height); err != nil {
+		return nil, err
+	}
+	return readIndexes(view.snapshot)
+}
+
+// can only be invoked by backend write goroutine
+func (bd *ChainStore) handleViewTask(task *viewTask) error {
+	snapshot, err := bd.st.NewSnapshot()
+	if err != nil {
+		return err
+	}
+	task.snapshot = snapshot
+	task.height = bd.currentBlockHeight
+	return nil
+}
+
+// can only be invoked by backend write goroutine
+func (bd *ChainStore) handleRepairTask(replay *ledgerReplay) error {
+	// blocks persisted since the snapshot
+	if err := replay.run(bd, bd.st, bd.currentBlockHeight); err != nil {
+		return err
+	}
+
+	// the state tree is rebuilt from the repaired entries, earlier roots
+	// cover the damaged ones
+	if err := bd.dropStateTree(); err != nil {
+		return err
+	}
+	bd.st.NewBatch()
+	for key := range replay.repairKeys {
+		current, err := bd.st.Get([]byte(key))
+		if err != nil {
+			current = nil
+		}
+		if value, ok := replay.expectedValue([]byte(key), current); ok {
+			bd.st.BatchPut([]byte(key), value)
+		} else {
+			bd.st.BatchDelete([]byte(key))
+		}
+	}
+	for height, undo := range replay.undos {
+		bd.st.BatchPut(undoKey(height), undo)
+	}
+	if err := bd.st.BatchCommit(); err != nil {
+		return err
+	}
+	log.Infof("[VerifyLedger] repaired %d entries and %d undo records", len(replay.repairKeys), len(replay.undos))
+
+	return bd.loadStateRoot()
+}
+
+// compareIndexes reports every difference between the replayed and the
+// stored indexes and returns the keys of the differing entries.
+func compareIndexes(expected *ledgerIndexes, stored *ledgerIndexes) ([]string, map[string]bool) {
+	var mismatches []string
+	keys := make(map[string]bool)
+	report := func(key []byte, format string, a ...interface{}) {
+		mismatches = append(mismatches, fmt.Sprintf(format, a...))
+		keys[string(key)] = true
+		log.Warn("[VerifyLedger] ", mismatches[len(mismatches)-1])
+	}
+
+	// IX_Unspent
+	for txid, indexes := range expected.unspents {
+		if !sameIndexes(indexes, stored.unspents[txid]) {
+			key := append([]byte{byte(IX_Unspent)}, txid.ToArray()...)
+			report(key, "unspent outputs of tx %x: stored %v, expected %v", txid.ToArrayReverse(), stored.unspents[txid], indexes)
+		}
+	}
+	for txid, indexes := range stored.unspents {
+		if _, ok := expected.unspents[txid]; !ok {
+			key := append([]byte{byte(IX_Unspent)}, txid.ToArray()...)
+			report(key, "unspent outputs of tx %x: stored %v, expected none", txid.ToArrayReverse(), indexes)
+		}
+	}
+
+	// IX_Unspent_UTXO
+	for programHash, assets := range expected.utxos {
+		for assetId, list := range assets {
+			if !sameUTXOs(list, stored.utxos[programHash][assetId]) {
+				address, _ := programHash.ToAddress()
+				report(utxoKey(programHash, assetId), "utxos of %s asset %x: stored %d, expected %d", address, assetId.ToArrayReverse(), len(stored.utxos[programHash][assetId]), len(list))
+			}
+		}
+	}
+	for programHash, assets := range stored.utxos {
+		for assetId, list := range assets {
+			if _, ok := expected.utxos[programHash][assetId]; !ok && len(list) > 0 {
+				address, _ := programHash.ToAddress()
+				report(utxoKey(programHash, assetId), "utxos of %s asset %x: stored %d, expected 0", address, assetId.ToArrayReverse(), len(list))
+			}
+		}
+	}
+
+	// ST_ACCOUNT
+	for programHash := range mergeAccounts(expected.balances, stored.balances) {
+		if sameBalances(expected.balances[programHash], stored.balances[programHash]) {
+			continue
+		}
+		address, _ := programHash.ToAddress()
+		key := append([]byte{byte(ST_ACCOUNT)}, programHash.ToArray()...)
+		report(key, "balances of %s: stored %v, expected %v", address, stored.balances[programHash], expected.balances[programHash])
+	}
+
+	// ST_QuantityIssued
+	for assetId, quantity := range expected.quantities {
+		if stored.quantities[assetId] != quantity {
+			key := append([]byte{byte(ST_QuantityIssued)}, assetId.ToArray()...)
+			report(key, "issued quantity of asset %x: stored %v, expected %v", assetId.ToArrayReverse(), stored.quantities[assetId], quantity)
+		}
+	}
+	for assetId, quantity := range stored.quantities {
+		if _, ok := expected.quantities[assetId]; !ok {
+			key := append([]byte{byte(ST_QuantityIssued)}, assetId.ToArray()...)
+			report(key, "issued quantity of asset %x: stored %v, expected none", assetId.ToArrayReverse(), quantity)
+		}
+	}
+
+	return mismatches, keys
+}
+
+func utxoKey(programHash Uint160, assetId Uint256) []byte {
+	key := append([]byte{byte(IX_Unspent_UTXO)}, programHash.ToArray()...)
+	return append(key, assetId.ToArray()...)
+}
+
+// storedBlock reads the block at height from the store.
+func (bd *ChainStore) storedBlock(height uint32) (*Block, error) {
+	return bd.readBlock(bd.st, height)
+}
+
+// readBlock reads the block at height from st. A transaction persist skipped,
+// such as a failed contract invocation, is not stored and is left nil so the
+// others keep their index.
+func (bd *ChainStore) readBlock(st storeReader, height uint32) (*Block, error) {
+	hash, err := bd.GetBlockHash(height)
+	if err != nil {
+		return nil, err
+	}
+	data, err := st.Get(append([]byte{byte(DATA_Header)}, hash.ToArray()...))
+	if err != nil {
+		return nil, err
+	}
+	b := new(Block)
+	b.Blockdata = new(Blockdata)
+	b.Blockdata.Program = new(program.Program)
+	r := bytes.NewReader(data)
+	// first 8 bytes is sys_fee
+	if _, err := serialization.ReadUint64(r); err != nil {
+		return nil, err
+	}
+	if err := b.FromTrimmedData(r); err != nil {
+		return nil, err
+	}
+
+	for i, t := range b.Transactions {
+		if err := readTx(st, t, t.Hash()); err == ErrPruned {
+			return nil, err
+		} else if err != nil {
+			b.Transactions[i] = nil
+		}
+	}
+
+	return b, nil
+}
+
+// ledgerReplay rebuilds the indexes from the transactions of the stored
+// blocks, applied one after the other.
+type ledgerReplay struct {
+	idx           *ledgerIndexes
+	outputs       map[Uint256]map[uint16]*tx.TxOutput
+	balanceAssets map[Uint256]bool
+	// height of the next block to apply
+	next uint32
+
+	// entries to repair, and the undo records rewritten with the values
+	// they had before each block
+	repairKeys map[string]bool
+	undos      map[uint32][]byte
+}
+
+func newLedgerReplay(repairKeys map[string]bool) *ledgerReplay {
+	return &ledgerReplay{
+		idx:           newLedgerIndexes(),
+		outputs:       make(map[Uint256]map[uint16]*tx.TxOutput),
+		balanceAssets: make(map[Uint256]bool),
+		repairKeys:    repairKeys,
+		undos:         make(map[uint32][]byte),
+	}
+}
+
+// run applies the blocks of st up to height.
+func (r *ledgerReplay) run(bd *ChainStore, st storeReader, height uint32) error {
+	for ; r.next <= height; r.next++ {
+		b, err := bd.readBlock(st, r.next)
+		if err != nil {
+			return err
+		}
+		if len(r.repairKeys) > 0 {
+			if err := r.rewriteUndo(st, r.next); err != nil {
+				return err
+			}
+		}
+		if err := r.apply(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ledgerReplay) apply(b *Block) error {
+	idx := r.idx
+	h := b.Blockdata.Height
+	issued := make(map[Uint256]Fixed64)
+	for _, t := range b.Transactions {
+		if t == nil {
+			continue
+		}
+		txid := t.Hash()
+		if ar, ok := t.Payload.(*payload.RegisterAsset); ok && IsBalanceModel(ar.Asset.RecordType, h) {
+			r.balanceAssets[txid] = true
+		}
+
+		for _, input := range t.UTXOInputs {
+			index := input.ReferTxOutputIndex
+			output, ok := r.outputs[input.ReferTxID][index]
+			if !ok {
+				return fmt.Errorf("block %d tx %x spends unknown output %x:%d", h, txid.ToArrayReverse(), input.ReferTxID.ToArrayReverse(), index)
+			}
+			delete(r.outputs[input.ReferTxID], index)
+			if len(r.outputs[input.ReferTxID]) == 0 {
+				delete(r.outputs, input.ReferTxID)
+			}
+			r.spend(input.ReferTxID, index, output)
+			idx.balances[output.ProgramHash][output.AssetID] -= output.Value
+		}
+		for _, input := range t.BalanceInputs {
+			idx.balances[input.ProgramHash][input.AssetID] -= input.Value
+		}
+		if burn, ok := t.Payload.(*payload.BurnAsset); ok {
+			idx.quantities[burn.AssetID] -= burn.Amount
+		}
+		if t.TxType == tx.IssueAsset {
+			for assetId, value := range t.GetMergedAssetIDValueFromOutputs() {
+				issueQuantity(h, idx.quantities, issued, assetId, value)
+			}
+		}
+
+		for i, output := range t.Outputs {
+			if _, ok := idx.balances[output.ProgramHash]; !ok {
+				idx.balances[output.ProgramHash] = make(map[Uint256]Fixed64)
+			}
+			idx.balances[output.ProgramHash][output.AssetID] += output.Value
+			if r.balanceAssets[output.AssetID] {
+				continue
+			}
+			if _, ok := r.outputs[txid]; !ok {
+				r.outputs[txid] = make(map[uint16]*tx.TxOutput)
+			}
+			r.outputs[txid][uint16(i)] = output
+			r.receive(txid, uint16(i), output)
+		}
+	}
+	return nil
+}
+
+func (r *ledgerReplay) receive(txid Uint256, index uint16, output *tx.TxOutput) {
+	idx := r.idx
+	idx.unspents[txid] = append(idx.unspents[txid], index)
+	if _, ok := idx.utxos[output.ProgramHash]; !ok {
+		idx.utxos[output.ProgramHash] = make(map[Uint256][]*tx.UTXOUnspent)
+	}
+	idx.utxos[output.ProgramHash][output.AssetID] = append(idx.utxos[output.ProgramHash][output.AssetID], &tx.UTXOUnspent{
+		Txid:  txid,
+		Index: uint32(index),
+		Value: output.Value,
+	})
+}
+
+func (r *ledgerReplay) spend(txid Uint256, index uint16, output *tx.TxOutput) {
+	idx := r.idx
+	indexes := idx.unspents[txid]
+	for i, n := range indexes {
+		if n == index {
+			indexes = append(indexes[:i], indexes[i+1:]...)
+			break
+		}
+	}
+	if len(indexes) == 0 {
+		delete(idx.unspents, txid)
+	} else {
+		idx.unspents[txid] = indexes
+	}
+
+	list := idx.utxos[output.ProgramHash][output.AssetID]
+	for i, u := range list {
+		if u.Txid == txid && u.Index == uint32(index) {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(idx.utxos[output.ProgramHash], output.AssetID)
+		if len(idx.utxos[output.ProgramHash]) == 0 {
+			delete(idx.utxos, output.ProgramHash)
+		}
+	} else {
+		idx.utxos[output.ProgramHash][output.AssetID] = list
+	}
+}
+
+// rewriteUndo sets the entries to repair in the undo record of the block at
+// height to the values the replay has before that block.
+func (r *ledgerReplay) rewriteUndo(st storeReader, height uint32) error {
+	data, err := st.Get(undoKey(height))
+	if err != nil {
+		// no undo record, as for the genesis block
+		return nil
+	}
+	j := newUndoJournal()
+	if err := j.Deserialize(bytes.NewReader(data)); err != nil {
+		return err
+	}
+	changed := false
+	for _, e := range j.entries {
+		if !r.repairKeys[string(e.Key)] {
+			continue
+		}
+		current := e.Value
+		if !e.Existed {
+			current = nil
+		}
+		e.Value, e.Existed = r.expectedValue(e.Key, current)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	w := bytes.NewBuffer(nil)
+	if err := j.Serialize(w); err != nil {
+		return err
+	}
+	r.undos[height] = w.Bytes()
+	return nil
+}
+
+// expectedValue returns the value of the index entry key after the applied
+// blocks, false when it should not exist. An account keeps the fields of
+// current other than its balances.
+func (r *ledgerReplay) expectedValue(key []byte, current []byte) ([]byte, bool) {
+	idx := r.idx
+	w := bytes.NewBuffer(nil)
+	rk := bytes.NewReader(key[1:])
+	switch DataEntryPrefix(key[0]) {
+	case IX_Unspent:
+		var txid Uint256
+		txid.Deserialize(rk)
+		indexes, ok := idx.unspents[txid]
+		if !ok {
+			return nil, false
+		}
+		return ToByteArray(indexes), true
+	case IX_Unspent_UTXO:
+		var programHash Uint160
+		var assetId Uint256
+		programHash.Deserialize(rk)
+		assetId.Deserialize(rk)
+		list, ok := idx.utxos[programHash][assetId]
+		if !ok {
+			return nil, false
+		}
+		serialization.WriteVarUint(w, uint64(len(list)))
+		for _, u := range list {
+			u.Serialize(w)
+		}
+	case ST_ACCOUNT:
+		var programHash Uint160
+		programHash.Deserialize(rk)
+		balances, ok := idx.balances[programHash]
+		if !ok {
+			return nil, false
+		}
+		state := new(account.AccountState)
+		if current == nil || state.Deserialize(bytes.NewReader(current)) != nil {
+			state = account.NewAccountState(programHash, nil)
+		}
+		state.Balances = make(map[Uint256]Fixed64, len(balances))
+		for assetId, value := range balances {
+			state.Balances[assetId] = value
+		}
+		state.Serialize(w)
+	case ST_QuantityIssued:
+		var assetId Uint256
+		assetId.Deserialize(rk)
+		quantity, ok := idx.quantities[assetId]
+		if !ok {
+			return nil, false
+		}
+		quantity.Serialize(w)
+	default:
+		return current, current != nil
+	}
+	return w.Bytes(), true
+}
+
+func readIndexes(st storeReader) (*ledgerIndexes, error) {
+	idx := newLedgerIndexes()
+
+	iter := st.NewIterator([]byte{byte(IX_Unspent)})
+	for iter.Next() {
+		txid, err := Uint256ParseFromBytes(iter.Key()[1:])
+		if err != nil {
+			iter.Release()
+			return nil, err
+		}
+		if idx.unspents[txid], err = GetUint16Array(iter.Value()); err != nil {
+			iter.Release()
+			return nil, err
+		}
+	}
+	iter.Release()
+
+	iter = st.NewIterator([]byte{byte(IX_Unspent_UTXO)})
+	for iter.Next() {
+		rk := bytes.NewReader(iter.Key()[1:])
+		var programHash Uint160
+		var assetId Uint256
+		programHash.Deserialize(rk)
+		assetId.Deserialize(rk)
+
+		r := bytes.NewReader(iter.Value())
+		listNum, err := serialization.ReadVarUint(r, 0)
+		if err != nil {
+			iter.Release()
+			return nil, err
+		}
+		list := make([]*tx.UTXOUnspent, listNum)
+		for i := range list {
+			list[i] = new(tx.UTXOUnspent)
+			if err := list[i].Deserialize(r); err != nil {
+				iter.Release()
+				return nil, err
+			}
+		}
+		if _, ok := idx.utxos[programHash]; !ok {
+			idx.utxos[programHash] = make(map[Uint256][]*tx.UTXOUnspent)
+		}
+		idx.utxos[programHash][assetId] = list
+	}
+	iter.Release()
+
+	iter = st.NewIterator([]byte{byte(ST_ACCOUNT)})
+	for iter.Next() {
+		state := new(account.AccountState)
+		if err := state.Deserialize(bytes.NewReader(iter.Value())); err != nil {
+			iter.Release()
+			return nil, err
+		}
+		idx.balances[state.ProgramHash] = state.Balances
+	}
+	iter.Release()
+
+	iter = st.NewIterator([]byte{byte(ST_QuantityIssued)})
+	for iter.Next() {
+		assetId, err := Uint256ParseFromBytes(iter.Key()[1:])
+		if err != nil {
+			iter.Release()
+			return nil, err
+		}
+		var quantity Fixed64
+		if err := quantity.Deserialize(bytes.NewReader(iter.Value())); err != nil {
+			iter.Release()
+			return nil, err
+		}
+		idx.quantities[assetId] = quantity
+	}
+	iter.Release()
+
+	return idx, nil
+}
+
+func sameIndexes(a []uint16, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	x := append([]uint16{}, a...)
+	y := append([]uint16{}, b...)
+	sort.Slice(x, func(i, j int) bool { return x[i] < x[j] })
+	sort.Slice(y, func(i, j int) bool { return y[i] < y[j] })
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameUTXOs(a []*tx.UTXOUnspent, b []*tx.UTXOUnspent) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[tx.UTXOUnspent]int)
+	for _, u := range a {
+		set[*u]++
+	}
+	for _, u := range b {
+		if set[*u] == 0 {
+			return false
+		}
+		set[*u]--
+	}
+	return true
+}
+
+// sameBalances ignores assets with a zero balance
+func sameBalances(a map[Uint256]Fixed64, b map[Uint256]Fixed64) bool {
+	for assetId, value := range a {
+		if b[assetId] != value {
+			return false
+		}
+	}
+	for assetId, value := range b {
+		if a[assetId] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func mergeAccounts(a map[Uint160]map[Uint256]Fixed64, b map[Uint160]map[Uint256]Fixed64) map[Uint160]bool {
+	all := make(map[Uint160]bool)
+	for programHash := range a {
+		all[programHash] = true
+	}
+	for programHash := range b {
+		all[programHash] = true
+	}
+	return all
+}
@@ -0,0 +1,135 @@
+package ChainStore
+
+import (
+	. "IPT/common"
+	"IPT/common/config"
+	"IPT/core/account"
+	"IPT/core/asset"
+	. "IPT/core/store"
+	tx "IPT/core/transaction"
+	"IPT/core/transaction/payload"
+	"testing"
+)
+
+func TestVerifyLedger(t *testing.T) {
+	bd, _ := newTestChainStore(t)
+	defer bd.Close()
+
+	mismatches, err := bd.VerifyLedger(false)
+	if err != nil || len(mismatches) != 0 {
+		t.Fatalf("new ledger: %v %v", mismatches, err)
+	}
+	root := bd.GetStateRoot()
+
+	// a quantity no block issued
+	bogus := append([]byte{byte(ST_QuantityIssued)}, make([]byte, 32)...)
+	bd.st.Put(bogus, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+	if mismatches, err = bd.VerifyLedger(false); err != nil || len(mismatches) != 1 {
+		t.Fatalf("corrupted ledger: %v %v", mismatches, err)
+	}
+	if _, err := bd.st.Get(bogus); err != nil {
+		t.Fatal("verify without repair changed the store")
+	}
+
+	if mismatches, err = bd.VerifyLedger(true); err != nil || len(mismatches) != 1 {
+		t.Fatalf("repair: %v %v", mismatches, err)
+	}
+	if mismatches, err = bd.VerifyLedger(false); err != nil || len(mismatches) != 0 {
+		t.Fatalf("repaired ledger: %v %v", mismatches, err)
+	}
+	if bd.GetStateRoot() != root {
+		t.Fatal("state root differs after repair")
+	}
+}
+
+func TestRepairBeforeRollback(t *testing.T) {
+	bd, bookKeeper := newTestChainStore(t)
+	defer bd.Close()
+	assetID := registerAsset(t, bd, bookKeeper, "coin", asset.UTXO)
+	holder := Uint160{1}
+
+	issue := &tx.Transaction{
+		TxType:  tx.IssueAsset,
+		Payload: &payload.IssueAsset{},
+		Outputs: []*tx.TxOutput{{AssetID: assetID, Value: 100, ProgramHash: holder}},
+	}
+	mustPersist(t, bd, issue)
+	issued := bd.GetHeight()
+
+	// a damaged balance, the next block journals and updates it
+	damaged := account.NewAccountState(holder, map[Uint256]Fixed64{assetID: 999})
+	bd.st.Put(append([]byte{byte(ST_ACCOUNT)}, holder.ToArray()...), damaged.ToArray())
+	mustPersist(t, bd, &tx.Transaction{
+		TxType:     tx.TransferAsset,
+		Payload:    &payload.TransferAsset{},
+		UTXOInputs: []*tx.UTXOTxInput{{ReferTxID: issue.Hash(), ReferTxOutputIndex: 0}},
+		Outputs:    []*tx.TxOutput{{AssetID: assetID, Value: 100, ProgramHash: Uint160{2}}},
+	})
+
+	if mismatches, err := bd.VerifyLedger(true); err != nil || len(mismatches) != 1 {
+		t.Fatalf("repair: %v %v", mismatches, err)
+	}
+	if err := bd.RollbackTo(issued); err != nil {
+		t.Fatal(err)
+	}
+	if mismatches, err := bd.VerifyLedger(false); err != nil || len(mismatches) != 0 {
+		t.Fatalf("rollback restored damaged entries: %v %v", mismatches, err)
+	}
+	if total, _, err := bd.GetAvailableAsset(holder, assetID); err != nil || total != 100 {
+		t.Fatalf("holder balance %v after rollback, %v", total, err)
+	}
+}
+
+func TestVerifyIssuesInOneBlock(t *testing.T) {
+	bd, bookKeeper := newTestChainStore(t)
+	defer bd.Close()
+	assetID := registerAsset(t, bd, bookKeeper, "coin", asset.UTXO)
+
+	issue := func(value Fixed64) *tx.Transaction {
+		return &tx.Transaction{
+			TxType:  tx.IssueAsset,
+			Payload: &payload.IssueAsset{},
+			Outputs: []*tx.TxOutput{{AssetID: assetID, Value: value, ProgramHash: Uint160{1}}},
+		}
+	}
+	mustPersist(t, bd, issue(30), issue(20))
+
+	// the replay counts the issues of a block the way persist does
+	stored, err := bd.GetQuantityIssued(assetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mismatches, err := bd.VerifyLedger(false); err != nil || len(mismatches) != 0 {
+		t.Fatalf("issued quantity %v: %v %v", stored, mismatches, err)
+	}
+}
+
+func TestIssueQuantityHeight(t *testing.T) {
+	defer func(height uint32) {
+		config.Parameters.IssueQuantityHeight = height
+	}(config.Parameters.IssueQuantityHeight)
+	bd, bookKeeper := newTestChainStore(t)
+	defer bd.Close()
+	assetID := registerAsset(t, bd, bookKeeper, "coin", asset.UTXO)
+	config.Parameters.IssueQuantityHeight = bd.GetHeight() + 2
+
+	issue := func(value Fixed64) *tx.Transaction {
+		return &tx.Transaction{
+			TxType:  tx.IssueAsset,
+			Payload: &payload.IssueAsset{},
+			Outputs: []*tx.TxOutput{{AssetID: assetID, Value: value, ProgramHash: Uint160{1}}},
+		}
+	}
+	// the last issue of the block counts before the height, all of them from it on
+	mustPersist(t, bd, issue(30), issue(20))
+	if issued, err := bd.GetQuantityIssued(assetID); err != nil || issued != 20 {
+		t.Fatalf("issued quantity %v before the height, %v", issued, err)
+	}
+	mustPersist(t, bd, issue(25), issue(15))
+	if issued, err := bd.GetQuantityIssued(assetID); err != nil || issued != 60 {
+		t.Fatalf("issued quantity %v from the height on, %v", issued, err)
+	}
+	if mismatches, err := bd.VerifyLedger(false); err != nil || len(mismatches) != 0 {
+		t.Fatalf("replay of the issues: %v %v", mismatches, err)
+	}
+}
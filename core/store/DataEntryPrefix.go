@@ -9,6 +9,8 @@ const (
 	DATA_Header      DataEntryPrefix = 0x01
 	DATA_Transaction DataEntryPrefix = 0x02
 	DATA_Contract    DataEntryPrefix = 0x03
+	DATA_Undo        DataEntryPrefix = 0x04
+	DATA_StateRoot   DataEntryPrefix = 0x05
 
 	// INDEX
 	IX_HeaderHashList DataEntryPrefix = 0x80
@@ -16,6 +18,8 @@ const (
 	IX_Unspent        DataEntryPrefix = 0x90
 	IX_Unspent_UTXO   DataEntryPrefix = 0x91
 	IX_Vote           DataEntryPrefix = 0x94
+	IX_TxHistory      DataEntryPrefix = 0x98
+	IX_StateNode      DataEntryPrefix = 0x9c
 
 	// ASSET
 	ST_Info           DataEntryPrefix = 0xc0
@@ -27,6 +31,8 @@ const (
 	ST_AssetState     DataEntryPrefix = 0xc6
 	ST_Validator      DataEntryPrefix = 0xc7
 	ST_Record         DataEntryPrefix = 0xc8
+	ST_Nonce          DataEntryPrefix = 0xc9
+	ST_Frozen         DataEntryPrefix = 0xca
 	//SYSTEM
 	SYS_CurrentBlock DataEntryPrefix = 0x40
 	// SYS_CurrentHeader     DataEntryPrefix = 0x41
@@ -92,3 +92,31 @@ func (self *LevelDBStore) NewIterator(prefix []byte) IIterator {
 		iter: iter,
 	}
 }
+
+type Snapshot struct {
+	snap *leveldb.Snapshot
+}
+
+func (self *LevelDBStore) NewSnapshot() (ISnapshot, error) {
+	snap, err := self.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{snap: snap}, nil
+}
+
+func (self *Snapshot) Get(key []byte) ([]byte, error) {
+	return self.snap.Get(key, nil)
+}
+
+func (self *Snapshot) NewIterator(prefix []byte) IIterator {
+	iter := self.snap.NewIterator(util.BytesPrefix(prefix), nil)
+
+	return &Iterator{
+		iter: iter,
+	}
+}
+
+func (self *Snapshot) Release() {
+	self.snap.Release()
+}
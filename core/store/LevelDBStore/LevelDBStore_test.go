@@ -0,0 +1,31 @@
+package LevelDBStore
+
+import (
+	. "IPT/core/store"
+	"IPT/core/store/StoreTest"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLevelDBStore(t *testing.T) {
+	var dirs []string
+	defer func() {
+		for _, dir := range dirs {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	StoreTest.RunStoreTests(t, func(t *testing.T) IStore {
+		dir, err := ioutil.TempDir("", "leveldbstore")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, dir)
+		st, err := NewLevelDBStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st
+	})
+}
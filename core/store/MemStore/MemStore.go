@@ -0,0 +1,168 @@
+package MemStore
+
+import (
+	. "IPT/core/store"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// MemStore keeps the entries in memory. Errors, batches and iterators behave
+// as with LevelDBStore, so it can stand in for it in tests.
+type MemStore struct {
+	mu     sync.RWMutex
+	db     map[string][]byte
+	batch  []batchOp
+	closed bool
+}
+
+type batchOp struct {
+	key    string
+	value  []byte
+	delete bool
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		db: make(map[string][]byte),
+	}
+}
+
+func (self *MemStore) Put(key []byte, value []byte) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.closed {
+		return leveldb.ErrClosed
+	}
+	self.db[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+func (self *MemStore) Get(key []byte) ([]byte, error) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+
+	if self.closed {
+		return nil, leveldb.ErrClosed
+	}
+	value, ok := self.db[string(key)]
+	if !ok {
+		return nil, leveldb.ErrNotFound
+	}
+	return append([]byte{}, value...), nil
+}
+
+func (self *MemStore) Delete(key []byte) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.closed {
+		return leveldb.ErrClosed
+	}
+	delete(self.db, string(key))
+	return nil
+}
+
+func (self *MemStore) NewBatch() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.batch = make([]batchOp, 0)
+	return nil
+}
+
+func (self *MemStore) BatchPut(key []byte, value []byte) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.batch = append(self.batch, batchOp{key: string(key), value: append([]byte{}, value...)})
+	return nil
+}
+
+func (self *MemStore) BatchDelete(key []byte) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.batch = append(self.batch, batchOp{key: string(key), delete: true})
+	return nil
+}
+
+// BatchCommit applies the batch at once. Like a leveldb.Batch it is kept, a
+// second commit writes it again.
+func (self *MemStore) BatchCommit() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.closed {
+		return leveldb.ErrClosed
+	}
+	for _, op := range self.batch {
+		if op.delete {
+			delete(self.db, op.key)
+		} else {
+			self.db[op.key] = append([]byte{}, op.value...)
+		}
+	}
+	return nil
+}
+
+func (self *MemStore) Close() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if self.closed {
+		return leveldb.ErrClosed
+	}
+	self.closed = true
+	self.db = nil
+	self.batch = nil
+	return nil
+}
+
+// NewIterator iterates a snapshot of the entries starting with prefix, later
+// writes are not seen.
+func (self *MemStore) NewIterator(prefix []byte) IIterator {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+
+	it := &Iterator{pos: -1}
+	p := string(prefix)
+	for k := range self.db {
+		if strings.HasPrefix(k, p) {
+			it.keys = append(it.keys, k)
+		}
+	}
+	sort.Strings(it.keys)
+	it.values = make([][]byte, len(it.keys))
+	for i, k := range it.keys {
+		it.values[i] = self.db[k]
+	}
+
+	return it
+}
+
+// Snapshot is a copy of the entries of a MemStore.
+type Snapshot struct {
+	*MemStore
+}
+
+func (self *MemStore) NewSnapshot() (ISnapshot, error) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+
+	if self.closed {
+		return nil, leveldb.ErrClosed
+	}
+	snap := NewMemStore()
+	for k, v := range self.db {
+		snap.db[k] = v
+	}
+	return &Snapshot{MemStore: snap}, nil
+}
+
+func (self *Snapshot) Release() {
+	self.MemStore.Close()
+}
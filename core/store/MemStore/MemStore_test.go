@@ -0,0 +1,13 @@
+package MemStore
+
+import (
+	. "IPT/core/store"
+	"IPT/core/store/StoreTest"
+	"testing"
+)
+
+func TestMemStore(t *testing.T) {
+	StoreTest.RunStoreTests(t, func(t *testing.T) IStore {
+		return NewMemStore()
+	})
+}
@@ -0,0 +1,86 @@
+package MemStore
+
+import (
+	"sort"
+)
+
+// Iterator walks sorted keys. Like the LevelDB iterator it starts before the
+// first entry, pos is -1 before the first and len(keys) after the last entry.
+type Iterator struct {
+	keys     []string
+	values   [][]byte
+	pos      int
+	released bool
+}
+
+func (it *Iterator) Next() bool {
+	if it.released || it.pos >= len(it.keys) {
+		return false
+	}
+	it.pos++
+	return it.pos < len(it.keys)
+}
+
+func (it *Iterator) Prev() bool {
+	if it.released || it.pos < 0 {
+		return false
+	}
+	if it.pos >= len(it.keys) {
+		return it.Last()
+	}
+	it.pos--
+	return it.pos >= 0
+}
+
+func (it *Iterator) First() bool {
+	if it.released {
+		return false
+	}
+	if len(it.keys) == 0 {
+		it.pos = len(it.keys)
+		return false
+	}
+	it.pos = 0
+	return true
+}
+
+func (it *Iterator) Last() bool {
+	if it.released {
+		return false
+	}
+	it.pos = len(it.keys) - 1
+	return it.pos >= 0
+}
+
+func (it *Iterator) Seek(key []byte) bool {
+	if it.released {
+		return false
+	}
+	k := string(key)
+	it.pos = sort.Search(len(it.keys), func(i int) bool { return it.keys[i] >= k })
+	return it.pos < len(it.keys)
+}
+
+func (it *Iterator) valid() bool {
+	return !it.released && it.pos >= 0 && it.pos < len(it.keys)
+}
+
+func (it *Iterator) Key() []byte {
+	if !it.valid() {
+		return nil
+	}
+	return []byte(it.keys[it.pos])
+}
+
+func (it *Iterator) Value() []byte {
+	if !it.valid() {
+		return nil
+	}
+	return it.values[it.pos]
+}
+
+func (it *Iterator) Release() {
+	it.released = true
+	it.keys = nil
+	it.values = nil
+}
@@ -0,0 +1,243 @@
+// Package StoreTest holds the conformance tests every IStore backend must pass.
+package StoreTest
+
+import (
+	. "IPT/core/store"
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// ErrNotFound is the error text ChainStore expects for a missing key.
+const ErrNotFound = "leveldb: not found"
+
+// RunStoreTests runs the conformance tests against the empty stores returned
+// by open. The stores are closed by the tests.
+func RunStoreTests(t *testing.T, open func(t *testing.T) IStore) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, st IStore)
+	}{
+		{"PutGetDelete", testPutGetDelete},
+		{"Batch", testBatch},
+		{"IteratePrefix", testIteratePrefix},
+		{"IteratorSeek", testIteratorSeek},
+		{"IteratorPrev", testIteratorPrev},
+		{"IteratorSnapshot", testIteratorSnapshot},
+		{"Snapshot", testSnapshot},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			st := open(t)
+			defer st.Close()
+			tc.test(t, st)
+		})
+	}
+}
+
+func put(t *testing.T, st IStore, keys ...string) {
+	for _, k := range keys {
+		if err := st.Put([]byte(k), []byte("v"+k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func keys(it IIterator, next func() bool) []string {
+	var got []string
+	for ok := next(); ok; ok = next() {
+		got = append(got, string(it.Key()))
+	}
+	return got
+}
+
+func expect(t *testing.T, what string, got []string, want ...string) {
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%s: got %v, want %v", what, got, want)
+	}
+}
+
+func testPutGetDelete(t *testing.T, st IStore) {
+	if _, err := st.Get([]byte("a")); err == nil || err.Error() != ErrNotFound {
+		t.Fatalf("missing key: got error %v", err)
+	}
+	put(t, st, "a")
+	value, err := st.Get([]byte("a"))
+	if err != nil || !bytes.Equal(value, []byte("va")) {
+		t.Fatalf("got %q %v", value, err)
+	}
+	// the store keeps its own copy
+	value[0] = 'x'
+	if value, _ = st.Get([]byte("a")); !bytes.Equal(value, []byte("va")) {
+		t.Fatalf("stored value changed to %q", value)
+	}
+	if err := st.Put([]byte("a"), []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ = st.Get([]byte("a")); !bytes.Equal(value, []byte("new")) {
+		t.Fatalf("overwritten value is %q", value)
+	}
+	if err := st.Delete([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Get([]byte("a")); err == nil || err.Error() != ErrNotFound {
+		t.Fatalf("deleted key: got error %v", err)
+	}
+	if err := st.Delete([]byte("a")); err != nil {
+		t.Fatalf("deleting a missing key: %v", err)
+	}
+}
+
+func testBatch(t *testing.T, st IStore) {
+	put(t, st, "a", "b")
+	st.NewBatch()
+	st.BatchPut([]byte("c"), []byte("vc"))
+	st.BatchDelete([]byte("a"))
+	st.BatchPut([]byte("d"), []byte("vd"))
+	st.BatchDelete([]byte("d"))
+	st.BatchPut([]byte("b"), []byte("first"))
+	st.BatchPut([]byte("b"), []byte("second"))
+
+	// nothing is written before the commit
+	if _, err := st.Get([]byte("c")); err == nil {
+		t.Fatal("batch written before commit")
+	}
+	if _, err := st.Get([]byte("a")); err != nil {
+		t.Fatal("batch delete applied before commit")
+	}
+
+	if err := st.BatchCommit(); err != nil {
+		t.Fatal(err)
+	}
+	it := st.NewIterator(nil)
+	expect(t, "after commit", keys(it, it.Next), "b", "c")
+	it.Release()
+	if value, _ := st.Get([]byte("b")); !bytes.Equal(value, []byte("second")) {
+		t.Errorf("last batch put of b gave %q", value)
+	}
+
+	// a new batch starts empty
+	st.NewBatch()
+	st.BatchPut([]byte("e"), []byte("ve"))
+	if err := st.BatchCommit(); err != nil {
+		t.Fatal(err)
+	}
+	it = st.NewIterator(nil)
+	expect(t, "after second commit", keys(it, it.Next), "b", "c", "e")
+	it.Release()
+}
+
+func testIteratePrefix(t *testing.T, st IStore) {
+	put(t, st, "b2", "a", "b1", "b", "c", "b10", "ba")
+
+	it := st.NewIterator([]byte("b"))
+	expect(t, "prefix b", keys(it, it.Next), "b", "b1", "b10", "b2", "ba")
+	if it.Next() {
+		t.Error("Next after the end")
+	}
+	if it.Key() != nil || it.Value() != nil {
+		t.Error("Key and Value after the end are not nil")
+	}
+	it.Release()
+
+	it = st.NewIterator([]byte("b1"))
+	if !it.First() || string(it.Key()) != "b1" || string(it.Value()) != "vb1" {
+		t.Errorf("First gave %q %q", it.Key(), it.Value())
+	}
+	if !it.Last() || string(it.Key()) != "b10" {
+		t.Errorf("Last gave %q", it.Key())
+	}
+	it.Release()
+
+	it = st.NewIterator(nil)
+	expect(t, "all", keys(it, it.Next), "a", "b", "b1", "b10", "b2", "ba", "c")
+	it.Release()
+
+	it = st.NewIterator([]byte("x"))
+	if it.First() || it.Last() || it.Next() || it.Prev() || it.Seek([]byte("x")) {
+		t.Error("empty iterator moved")
+	}
+	it.Release()
+}
+
+func testIteratorSeek(t *testing.T, st IStore) {
+	put(t, st, "a", "b1", "b3", "b5", "c")
+
+	it := st.NewIterator([]byte("b"))
+	defer it.Release()
+	if !it.Seek([]byte("b3")) || string(it.Key()) != "b3" {
+		t.Errorf("Seek b3 gave %q", it.Key())
+	}
+	if !it.Seek([]byte("b4")) || string(it.Key()) != "b5" {
+		t.Errorf("Seek b4 gave %q", it.Key())
+	}
+	// a key below the prefix range seeks to its first entry
+	if !it.Seek([]byte("a")) || string(it.Key()) != "b1" {
+		t.Errorf("Seek a gave %q", it.Key())
+	}
+	expect(t, "after Seek a", keys(it, it.Next), "b3", "b5")
+	if it.Seek([]byte("b6")) {
+		t.Errorf("Seek after the last entry gave %q", it.Key())
+	}
+	// Prev after the end moves to the last entry
+	if !it.Prev() || string(it.Key()) != "b5" {
+		t.Errorf("Prev after the end gave %q", it.Key())
+	}
+}
+
+func testIteratorPrev(t *testing.T, st IStore) {
+	put(t, st, "b1", "b2", "b3")
+
+	it := st.NewIterator([]byte("b"))
+	defer it.Release()
+	if it.Prev() {
+		t.Errorf("Prev of a new iterator gave %q", it.Key())
+	}
+	if !it.Last() {
+		t.Fatal("Last failed")
+	}
+	last := string(it.Key())
+	expect(t, "backwards", append([]string{last}, keys(it, it.Prev)...), "b3", "b2", "b1")
+	// Next before the start moves to the first entry
+	if !it.Next() || string(it.Key()) != "b1" {
+		t.Errorf("Next before the start gave %q", it.Key())
+	}
+	if !it.Seek([]byte("b2")) || !it.Prev() || string(it.Key()) != "b1" {
+		t.Errorf("Prev after Seek b2 gave %q", it.Key())
+	}
+	if !it.Next() || string(it.Key()) != "b2" {
+		t.Errorf("changing direction gave %q", it.Key())
+	}
+}
+
+func testIteratorSnapshot(t *testing.T, st IStore) {
+	put(t, st, "a", "b")
+
+	it := st.NewIterator(nil)
+	defer it.Release()
+	put(t, st, "c")
+	st.Delete([]byte("a"))
+	expect(t, "snapshot", keys(it, it.Next), "a", "b")
+}
+
+func testSnapshot(t *testing.T, st IStore) {
+	put(t, st, "a", "b")
+
+	snap, err := st.NewSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Release()
+	put(t, st, "c")
+	st.Delete([]byte("a"))
+
+	if value, err := snap.Get([]byte("a")); err != nil || string(value) != "va" {
+		t.Errorf("snapshot get a: %q %v", value, err)
+	}
+	if _, err := snap.Get([]byte("c")); err == nil || err.Error() != ErrNotFound {
+		t.Errorf("snapshot sees a later put: %v", err)
+	}
+	it := snap.NewIterator(nil)
+	defer it.Release()
+	expect(t, "snapshot iterator", keys(it, it.Next), "a", "b")
+}
@@ -21,4 +21,13 @@ type IStore interface {
 	BatchCommit() error
 	Close() error
 	NewIterator(prefix []byte) IIterator
+	NewSnapshot() (ISnapshot, error)
+}
+
+// ISnapshot reads the entries of a store as they were when it was taken,
+// later writes are not seen. It is released when no longer needed.
+type ISnapshot interface {
+	Get(key []byte) ([]byte, error)
+	NewIterator(prefix []byte) IIterator
+	Release()
 }
@@ -34,6 +34,42 @@ func NewRegisterAssetTransaction(asset *asset.Asset, amount common.Fixed64, issu
 	}, nil
 }
 
+//initial a new transaction burning amount of the asset spent by inputs
+func NewBurnAssetTransaction(inputs []*UTXOTxInput, outputs []*TxOutput, assetID common.Uint256, amount common.Fixed64) (*Transaction, error) {
+	burnPayload := &payload.BurnAsset{
+		AssetID: assetID,
+		Amount:  amount,
+	}
+
+	return &Transaction{
+		TxType:        BurnAsset,
+		Payload:       burnPayload,
+		Attributes:    []*TxAttribute{},
+		UTXOInputs:    inputs,
+		BalanceInputs: []*BalanceTxInput{},
+		Outputs:       outputs,
+		Programs:      []*program.Program{},
+	}, nil
+}
+
+//initial a new transaction freezing or unfreezing programHash in the asset
+func NewFreezeAssetTransaction(assetID common.Uint256, programHash common.Uint160, freeze bool) (*Transaction, error) {
+	freezePayload := &payload.FreezeAsset{
+		AssetID:     assetID,
+		ProgramHash: programHash,
+		Freeze:      freeze,
+	}
+
+	return &Transaction{
+		TxType:        FreezeAsset,
+		Payload:       freezePayload,
+		Attributes:    []*TxAttribute{},
+		UTXOInputs:    []*UTXOTxInput{},
+		BalanceInputs: []*BalanceTxInput{},
+		Programs:      []*program.Program{},
+	}, nil
+}
+
 //initial a new transaction with asset registration payload
 func NewBookKeeperTransaction(pubKey *crypto.PubKey, isAdd bool, cert []byte, issuer *crypto.PubKey) (*Transaction, error) {
 
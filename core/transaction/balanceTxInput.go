@@ -2,19 +2,25 @@ package transaction
 
 import (
 	"IPT/common"
+	"IPT/common/serialization"
 	"io"
 )
 
+// BalanceTxInput spends Value of a balance-model asset from the account
+// ProgramHash. Nonce protects against replay, it must not be below the next
+// nonce of the account, which persist moves past it.
 type BalanceTxInput struct {
 	AssetID     common.Uint256
 	Value       common.Fixed64
 	ProgramHash common.Uint160
+	Nonce       uint64
 }
 
 func (bi *BalanceTxInput) Serialize(w io.Writer) {
 	bi.AssetID.Serialize(w)
 	bi.Value.Serialize(w)
 	bi.ProgramHash.Serialize(w)
+	serialization.WriteUint64(w, bi.Nonce)
 }
 
 func (bi *BalanceTxInput) Deserialize(r io.Reader) error {
@@ -33,5 +39,10 @@ func (bi *BalanceTxInput) Deserialize(r io.Reader) error {
 		return err
 	}
 
+	bi.Nonce, err = serialization.ReadUint64(r)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,58 @@
+package transaction
+
+import (
+	"IPT/common/serialization"
+	"bytes"
+	"errors"
+	"math"
+)
+
+// A transaction carrying a ValidUntilHeight attribute may only be included in
+// blocks up to that height. The attribute holds the height as uint32, the
+// pool evicts the transaction once the ledger reaches it.
+
+// ValidUntil returns the last block height tx may be included in,
+// math.MaxUint32 when tx does not expire.
+func (tx *Transaction) ValidUntil() (uint32, error) {
+	height := uint32(math.MaxUint32)
+	declared := false
+	for _, attr := range tx.Attributes {
+		if attr.Usage != ValidUntilHeight {
+			continue
+		}
+		if declared {
+			return 0, errors.New("more than one valid until height attribute")
+		}
+		if len(attr.Data) != 4 {
+			return 0, errors.New("invalid valid until height attribute")
+		}
+		var err error
+		if height, err = serialization.ReadUint32(bytes.NewReader(attr.Data)); err != nil {
+			return 0, err
+		}
+		declared = true
+	}
+	return height, nil
+}
+
+// SetValidUntil limits tx to blocks up to height, replacing a
+// ValidUntilHeight attribute already present.
+func (tx *Transaction) SetValidUntil(height uint32) {
+	data := bytes.NewBuffer(nil)
+	serialization.WriteUint32(data, height)
+	for _, attr := range tx.Attributes {
+		if attr.Usage == ValidUntilHeight {
+			attr.Data = data.Bytes()
+			return
+		}
+	}
+	attr := NewTxAttribute(ValidUntilHeight, data.Bytes())
+	tx.Attributes = append(tx.Attributes, &attr)
+}
+
+// IsExpired reports whether tx can no longer be included in a block at
+// height, a malformed attribute counts as expired.
+func (tx *Transaction) IsExpired(height uint32) bool {
+	validUntil, err := tx.ValidUntil()
+	return err != nil || height > validUntil
+}
@@ -0,0 +1,143 @@
+package transaction
+
+import (
+	. "IPT/common"
+	"IPT/common/config"
+	"IPT/core/transaction/payload"
+	"bytes"
+	"errors"
+	"math"
+)
+
+// A transaction declares the fee it pays in a Fee attribute holding the
+// amount as Fixed64. From DeclaredFeeHeight on the inputs must exceed the
+// outputs by exactly that amount in a single asset, earlier blocks follow the
+// implicit fee of the TransactionFee configured for Transfer. The bookkeeper
+// of the block collects what the inputs exceed the outputs by.
+//
+// The minimum fee is a node policy: the TransactionFee configured for the
+// transaction type plus FeePerByte for each byte of the signed transaction.
+// Transactions without UTXO or balance inputs cannot pay a fee and need none.
+
+var txTypeNames = map[TransactionType]string{
+	BookKeeping:    "BookKeeping",
+	IssueAsset:     "IssueAsset",
+	BookKeeper:     "BookKeeper",
+	LockAsset:      "LockAsset",
+	BurnAsset:      "BurnAsset",
+	FreezeAsset:    "FreezeAsset",
+	PrivacyPayload: "PrivacyPayload",
+	RegisterAsset:  "RegisterAsset",
+	TransferAsset:  "Transfer",
+	Record:         "Record",
+	DeployCode:     "DeployCode",
+	InvokeCode:     "InvokeCode",
+	DataFile:       "DataFile",
+}
+
+// TxTypeName returns the name the TransactionFee configuration uses for t.
+func TxTypeName(t TransactionType) string {
+	return txTypeNames[t]
+}
+
+// TxTypeByName is the reverse of TxTypeName.
+func TxTypeByName(name string) (TransactionType, bool) {
+	for t, n := range txTypeNames {
+		if n == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+func configuredFee(v float64) Fixed64 {
+	return Fixed64(math.Round(v * 100000000))
+}
+
+// FeePerByte returns the configured fee for each transaction byte.
+func FeePerByte() Fixed64 {
+	return configuredFee(config.Parameters.FeePerByte)
+}
+
+// MinimumFee returns the fee a transaction of type txType, spending UTXO
+// inputs and size bytes long, has to declare to enter the pool.
+func MinimumFee(txType TransactionType, size int) Fixed64 {
+	fee := configuredFee(config.Parameters.TransactionFee[TxTypeName(txType)])
+	return fee + FeePerByte()*Fixed64(size)
+}
+
+// FeeDeclared reports whether transactions in the block at height pay
+// exactly the fee they declare.
+func FeeDeclared(height uint32) bool {
+	return config.Parameters.DeclaredFeeHeight > 0 && height >= config.Parameters.DeclaredFeeHeight
+}
+
+// PaidFees returns what the inputs of tx exceed its outputs and burns by,
+// per asset. Negative values are assets tx pays out more of than it spends.
+func (tx *Transaction) PaidFees() (TransactionResult, error) {
+	results, err := tx.GetTransactionResults()
+	if err != nil {
+		return nil, err
+	}
+	if burn, ok := tx.Payload.(*payload.BurnAsset); ok {
+		results[burn.AssetID] -= burn.Amount
+	}
+	return results, nil
+}
+
+// Fee returns the fee declared in the Fee attribute, zero without one.
+func (tx *Transaction) Fee() (Fixed64, error) {
+	var fee Fixed64
+	declared := false
+	for _, attr := range tx.Attributes {
+		if attr.Usage != Fee {
+			continue
+		}
+		if declared {
+			return 0, errors.New("more than one fee attribute")
+		}
+		if len(attr.Data) != 8 {
+			return 0, errors.New("invalid fee attribute")
+		}
+		if err := fee.Deserialize(bytes.NewReader(attr.Data)); err != nil {
+			return 0, err
+		}
+		if fee < 0 {
+			return 0, errors.New("negative fee")
+		}
+		declared = true
+	}
+	return fee, nil
+}
+
+// SetFee declares fee, replacing a Fee attribute already present.
+func (tx *Transaction) SetFee(fee Fixed64) {
+	data := bytes.NewBuffer(nil)
+	fee.Serialize(data)
+	for _, attr := range tx.Attributes {
+		if attr.Usage == Fee {
+			attr.Data = data.Bytes()
+			return
+		}
+	}
+	attr := NewTxAttribute(Fee, data.Bytes())
+	tx.Attributes = append(tx.Attributes, &attr)
+}
+
+// MinimumFee returns the fee tx has to declare to enter the pool.
+func (tx *Transaction) MinimumFee() Fixed64 {
+	if len(tx.UTXOInputs) == 0 && len(tx.BalanceInputs) == 0 {
+		return 0
+	}
+	return MinimumFee(tx.TxType, len(tx.ToArray()))
+}
+
+// FeeRate returns the declared fee per byte, transactions with a higher
+// rate are packed into blocks first.
+func (tx *Transaction) FeeRate() Fixed64 {
+	fee, err := tx.Fee()
+	if err != nil {
+		return 0
+	}
+	return fee / Fixed64(len(tx.ToArray()))
+}
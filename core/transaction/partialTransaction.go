@@ -0,0 +1,398 @@
+package transaction
+
+import (
+	. "IPT/common"
+	"IPT/common/serialization"
+	"IPT/core/contract"
+	"IPT/core/contract/program"
+	sig "IPT/core/signature"
+	"IPT/crypto"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// PartialTransactionMagic starts every serialized PartialTransaction.
+var PartialTransactionMagic = []byte("IPTPTX")
+
+// Version 0x01 adds the signing height, version 0x00 is read as signed for
+// the SigningHeight of the reader.
+const PartialTransactionVersion byte = 0x01
+
+// PartialSignature is a signature of the transaction by one public key.
+type PartialSignature struct {
+	PubKey    []byte
+	Signature []byte
+}
+
+// PartialProgram collects the signatures for one program hash of the
+// transaction. Code is the redeem script, empty until a signer provides it.
+type PartialProgram struct {
+	ProgramHash Uint160
+	Code        []byte
+	Signatures  []*PartialSignature
+}
+
+// PartialTransaction is an unsigned or partially signed transaction passed
+// between signers. It carries the outputs spent by the UTXO inputs, in input
+// order, so that offline signers can check the amounts without a ledger, and
+// the height of the block it is signed for, so that all signers sign the same
+// data whatever their SigningHeight.
+type PartialTransaction struct {
+	Transaction   *Transaction
+	SigningHeight uint32
+	References    []*TxOutput
+	Programs      []*PartialProgram
+}
+
+// NewPartialTransaction looks up the references of txn and takes over the
+// signatures its programs already carry, it is signed for SigningHeight.
+func NewPartialTransaction(txn *Transaction) (*PartialTransaction, error) {
+	ptx := &PartialTransaction{Transaction: txn, SigningHeight: sig.SigningHeight()}
+	for _, input := range txn.UTXOInputs {
+		referTxn, err := GetReferTransaction(input.ReferTxID)
+		if err != nil {
+			return nil, err
+		}
+		if int(input.ReferTxOutputIndex) >= len(referTxn.Outputs) {
+			return nil, errors.New("invalid input index")
+		}
+		ptx.References = append(ptx.References, referTxn.Outputs[input.ReferTxOutputIndex])
+	}
+	hashes, err := txn.GetProgramHashes()
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range hashes {
+		ptx.Programs = append(ptx.Programs, &PartialProgram{ProgramHash: hash})
+	}
+	for _, p := range txn.Programs {
+		if len(p.Code) == 0 {
+			continue
+		}
+		if !ptx.SetCode(p.Code) {
+			continue
+		}
+		// a signature program pushes its signatures one by one
+		for param := p.Parameter; len(param) >= SignatureScriptLen && param[0] == SignatureScriptLen-1; param = param[SignatureScriptLen:] {
+			ptx.addSignature(p.Code, nil, param[1:SignatureScriptLen])
+		}
+	}
+	txn.Programs = []*program.Program{}
+	return ptx, nil
+}
+
+func (ptx *PartialTransaction) program(programHash Uint160) *PartialProgram {
+	for _, p := range ptx.Programs {
+		if p.ProgramHash == programHash {
+			return p
+		}
+	}
+	return nil
+}
+
+// SetCode provides the redeem script of one of the programs, it reports
+// whether the transaction needs it.
+func (ptx *PartialTransaction) SetCode(code []byte) bool {
+	hash, err := ToCodeHash(code)
+	if err != nil {
+		return false
+	}
+	p := ptx.program(hash)
+	if p == nil {
+		return false
+	}
+	if p.Code == nil {
+		p.Code = code
+	}
+	return true
+}
+
+// message returns the data the signers of ptx sign.
+func (ptx *PartialTransaction) message() []byte {
+	return sig.AtHeight(ptx.Transaction, ptx.SigningHeight).GetMessage()
+}
+
+// addSignature adds signature to the program of code if it verifies with one
+// of its keys, with pubKey only when it is known.
+func (ptx *PartialTransaction) addSignature(code []byte, pubKey []byte, signature []byte) bool {
+	hash, _ := ToCodeHash(code)
+	p := ptx.program(hash)
+	if p == nil {
+		return false
+	}
+	_, keys, err := (&contract.Contract{Code: code}).SignerKeys()
+	if err != nil {
+		return false
+	}
+	message := ptx.message()
+	for _, key := range keys {
+		if pubKey != nil && !bytes.Equal(key, pubKey) {
+			continue
+		}
+		if p.signature(key) != nil {
+			continue
+		}
+		pubKey, err := crypto.DecodePoint(key)
+		if err != nil {
+			continue
+		}
+		if crypto.Verify(*pubKey, message, signature) == nil {
+			p.Signatures = append(p.Signatures, &PartialSignature{PubKey: key, Signature: signature})
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PartialProgram) signature(pubKey []byte) []byte {
+	for _, s := range p.Signatures {
+		if bytes.Equal(s.PubKey, pubKey) {
+			return s.Signature
+		}
+	}
+	return nil
+}
+
+// Sign adds the signature of signer to every program it is a key of and
+// returns how many it signed. A missing single signature script of signer
+// is filled in.
+func (ptx *PartialTransaction) Sign(signer sig.Signer) (int, error) {
+	key, err := signer.PubKey().EncodePoint(true)
+	if err != nil {
+		return 0, err
+	}
+	if code, err := contract.CreateSignatureRedeemScript(signer.PubKey()); err == nil {
+		ptx.SetCode(code)
+	}
+	var signature []byte
+	signed := 0
+	for _, p := range ptx.Programs {
+		if p.Code == nil || p.signature(key) != nil {
+			continue
+		}
+		_, keys, err := (&contract.Contract{Code: p.Code}).SignerKeys()
+		if err != nil {
+			continue
+		}
+		for _, k := range keys {
+			if !bytes.Equal(k, key) {
+				continue
+			}
+			if signature == nil {
+				if signature, err = sig.SignBySigner(sig.AtHeight(ptx.Transaction, ptx.SigningHeight), signer); err != nil {
+					return signed, err
+				}
+			}
+			p.Signatures = append(p.Signatures, &PartialSignature{PubKey: key, Signature: signature})
+			signed++
+			break
+		}
+	}
+	return signed, nil
+}
+
+// Merge adds the codes, references and signatures of other, which has to be
+// the same transaction signed for the same height.
+func (ptx *PartialTransaction) Merge(other *PartialTransaction) error {
+	if ptx.Transaction.Hash() != other.Transaction.Hash() {
+		return errors.New("partial transactions of different transactions")
+	}
+	if ptx.SigningHeight != other.SigningHeight {
+		return errors.New("partial transactions signed for different heights")
+	}
+	if len(ptx.References) == 0 {
+		ptx.References = other.References
+	}
+	for _, p := range other.Programs {
+		if p.Code == nil || !ptx.SetCode(p.Code) {
+			continue
+		}
+		for _, s := range p.Signatures {
+			ptx.addSignature(p.Code, s.PubKey, s.Signature)
+		}
+	}
+	return nil
+}
+
+// Signatures returns the signatures collected and the signatures still needed
+// over all programs. Programs without code count as one missing signature.
+func (ptx *PartialTransaction) Signatures() (have, need int) {
+	for _, p := range ptx.Programs {
+		have += len(p.Signatures)
+		if p.Code == nil {
+			need++
+			continue
+		}
+		m, _, err := (&contract.Contract{Code: p.Code}).SignerKeys()
+		if err == nil && m > len(p.Signatures) {
+			need += m - len(p.Signatures)
+		}
+	}
+	return have, need
+}
+
+func (ptx *PartialTransaction) IsCompleted() bool {
+	_, need := ptx.Signatures()
+	return need == 0
+}
+
+// Finalize sets the programs of the transaction from the collected
+// signatures, ordered as the keys in the code.
+func (ptx *PartialTransaction) Finalize() (*Transaction, error) {
+	if !ptx.IsCompleted() {
+		return nil, errors.New("transaction is not completely signed")
+	}
+	programs := make([]*program.Program, 0, len(ptx.Programs))
+	for _, p := range ptx.Programs {
+		m, keys, err := (&contract.Contract{Code: p.Code}).SignerKeys()
+		if err != nil {
+			return nil, err
+		}
+		sb := program.NewProgramBuilder()
+		for _, key := range keys {
+			if m == 0 {
+				break
+			}
+			if s := p.signature(key); s != nil {
+				sb.PushData(s)
+				m--
+			}
+		}
+		programs = append(programs, &program.Program{Code: p.Code, Parameter: sb.ToArray()})
+	}
+	ptx.Transaction.SetPrograms(programs)
+	return ptx.Transaction, nil
+}
+
+func (ptx *PartialTransaction) Serialize(w io.Writer) error {
+	if _, err := w.Write(PartialTransactionMagic); err != nil {
+		return err
+	}
+	if err := serialization.WriteByte(w, PartialTransactionVersion); err != nil {
+		return err
+	}
+	if err := serialization.WriteUint32(w, ptx.SigningHeight); err != nil {
+		return err
+	}
+	if err := ptx.Transaction.SerializeUnsigned(w); err != nil {
+		return err
+	}
+	if err := serialization.WriteVarUint(w, uint64(len(ptx.References))); err != nil {
+		return err
+	}
+	for _, o := range ptx.References {
+		o.Serialize(w)
+	}
+	if err := serialization.WriteVarUint(w, uint64(len(ptx.Programs))); err != nil {
+		return err
+	}
+	for _, p := range ptx.Programs {
+		if _, err := p.ProgramHash.Serialize(w); err != nil {
+			return err
+		}
+		if err := serialization.WriteVarBytes(w, p.Code); err != nil {
+			return err
+		}
+		if err := serialization.WriteVarUint(w, uint64(len(p.Signatures))); err != nil {
+			return err
+		}
+		for _, s := range p.Signatures {
+			if err := serialization.WriteVarBytes(w, s.PubKey); err != nil {
+				return err
+			}
+			if err := serialization.WriteVarBytes(w, s.Signature); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (ptx *PartialTransaction) Deserialize(r io.Reader) error {
+	magic, err := serialization.ReadBytes(r, uint64(len(PartialTransactionMagic)))
+	if err != nil || !bytes.Equal(magic, PartialTransactionMagic) {
+		return errors.New("not a partial transaction")
+	}
+	version, err := serialization.ReadByte(r)
+	if err != nil {
+		return err
+	}
+	switch version {
+	case 0x00:
+		ptx.SigningHeight = sig.SigningHeight()
+	case PartialTransactionVersion:
+		if ptx.SigningHeight, err = serialization.ReadUint32(r); err != nil {
+			return err
+		}
+	default:
+		return errors.New("unknown partial transaction version")
+	}
+	ptx.Transaction = new(Transaction)
+	if err := ptx.Transaction.DeserializeUnsigned(r); err != nil {
+		return err
+	}
+	ptx.Transaction.Programs = []*program.Program{}
+
+	count, err := serialization.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	if count != uint64(len(ptx.Transaction.UTXOInputs)) && count != 0 {
+		return errors.New("references do not match the inputs")
+	}
+	ptx.References = nil
+	for i := uint64(0); i < count; i++ {
+		o := new(TxOutput)
+		o.Deserialize(r)
+		ptx.References = append(ptx.References, o)
+	}
+
+	count, err = serialization.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	ptx.Programs = nil
+	for i := uint64(0); i < count; i++ {
+		p := new(PartialProgram)
+		if err := p.ProgramHash.Deserialize(r); err != nil {
+			return err
+		}
+		if p.Code, err = serialization.ReadVarBytes(r); err != nil {
+			return err
+		}
+		if len(p.Code) == 0 {
+			p.Code = nil
+		} else if hash, err := ToCodeHash(p.Code); err != nil || hash != p.ProgramHash {
+			return errors.New("code does not match the program hash")
+		}
+		sigs, err := serialization.ReadVarUint(r, 0)
+		if err != nil {
+			return err
+		}
+		for j := uint64(0); j < sigs; j++ {
+			s := new(PartialSignature)
+			if s.PubKey, err = serialization.ReadVarBytes(r); err != nil {
+				return err
+			}
+			if s.Signature, err = serialization.ReadVarBytes(r); err != nil {
+				return err
+			}
+			p.Signatures = append(p.Signatures, s)
+		}
+		ptx.Programs = append(ptx.Programs, p)
+	}
+	return nil
+}
+
+func (ptx *PartialTransaction) ToArray() []byte {
+	b := new(bytes.Buffer)
+	ptx.Serialize(b)
+	return b.Bytes()
+}
+
+// IsPartialTransaction reports whether raw is a serialized PartialTransaction
+// rather than a plain transaction.
+func IsPartialTransaction(raw []byte) bool {
+	return bytes.HasPrefix(raw, PartialTransactionMagic)
+}
@@ -0,0 +1,148 @@
+package transaction
+
+import (
+	"bytes"
+	"testing"
+
+	. "IPT/common"
+	"IPT/common/config"
+	"IPT/core/contract"
+	sig "IPT/core/signature"
+	"IPT/crypto"
+)
+
+type testSigner struct {
+	privKey []byte
+	pubKey  crypto.PubKey
+}
+
+func (s *testSigner) PrivKey() []byte        { return s.privKey }
+func (s *testSigner) PubKey() *crypto.PubKey { return &s.pubKey }
+
+func TestPartialTransaction(t *testing.T) {
+	crypto.SetAlg("P256R1")
+	signers := make([]*testSigner, 3)
+	pubKeys := make([]*crypto.PubKey, 3)
+	for i := range signers {
+		priv, pub, _ := crypto.GenKeyPair()
+		signers[i] = &testSigner{priv, pub}
+		pubKeys[i] = &signers[i].pubKey
+	}
+	code, err := contract.CreateMultiSigRedeemScript(2, pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, _ := ToCodeHash(code)
+	txn, _ := NewTransferAssetTransaction(nil, []*TxOutput{{Value: 1, ProgramHash: hash}})
+	newPartial := func() *PartialTransaction {
+		return &PartialTransaction{
+			Transaction: txn,
+			Programs:    []*PartialProgram{{ProgramHash: hash}},
+		}
+	}
+
+	// two signers sign their own copy offline, the second one without code
+	first := newPartial()
+	first.SetCode(code)
+	if n, _ := first.Sign(signers[2]); n != 1 {
+		t.Fatalf("signed %d programs, want 1", n)
+	}
+	second := newPartial()
+	if n, _ := second.Sign(signers[0]); n != 0 {
+		t.Fatal("signed without the code")
+	}
+	second.SetCode(code)
+	second.Sign(signers[0])
+
+	var merged PartialTransaction
+	if err := merged.Deserialize(bytes.NewReader(first.ToArray())); err != nil {
+		t.Fatal(err)
+	}
+	if merged.IsCompleted() {
+		t.Fatal("completed with one of two signatures")
+	}
+	if err := merged.Merge(second); err != nil {
+		t.Fatal(err)
+	}
+	if have, need := merged.Signatures(); have != 2 || need != 0 {
+		t.Fatalf("have %d need %d signatures", have, need)
+	}
+
+	final, err := merged.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the signatures follow the order of the keys in the code
+	_, keys, _ := (&contract.Contract{Code: code}).SignerKeys()
+	param := final.Programs[0].Parameter
+	if len(param) != 2*SignatureScriptLen {
+		t.Fatalf("parameter of %d bytes", len(param))
+	}
+	next := 0
+	for _, key := range keys {
+		pubKey, _ := crypto.DecodePoint(key)
+		if crypto.Verify(*pubKey, final.GetMessage(), param[next*SignatureScriptLen+1:(next+1)*SignatureScriptLen]) == nil {
+			next++
+		}
+		if next == 2 {
+			break
+		}
+	}
+	if next != 2 {
+		t.Fatal("signatures are not in key order")
+	}
+}
+
+func TestPartialTransactionSigningHeight(t *testing.T) {
+	defer func(height uint32) {
+		config.Parameters.SigningMagicHeight = height
+		sig.SigningHeight = func() uint32 { return ^uint32(0) }
+	}(config.Parameters.SigningMagicHeight)
+	config.Parameters.SigningMagicHeight = 100
+
+	crypto.SetAlg("P256R1")
+	priv, pub, _ := crypto.GenKeyPair()
+	signer := &testSigner{priv, pub}
+	code, err := contract.CreateSignatureRedeemScript(&signer.pubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, _ := ToCodeHash(code)
+	txn, _ := NewTransferAssetTransaction(nil, []*TxOutput{{Value: 1, ProgramHash: hash}})
+
+	// created by a node before the activation height
+	sig.SigningHeight = func() uint32 { return 99 }
+	ptx, err := NewPartialTransaction(txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the transaction spends nothing, the program is the one of the output
+	ptx.Programs = []*PartialProgram{{ProgramHash: hash}}
+	ptx.SetCode(code)
+
+	// signed and merged offline, where the activation height has passed
+	sig.SigningHeight = func() uint32 { return ^uint32(0) }
+	var signed PartialTransaction
+	if err := signed.Deserialize(bytes.NewReader(ptx.ToArray())); err != nil {
+		t.Fatal(err)
+	}
+	if signed.SigningHeight != 99 {
+		t.Fatalf("signing height %d after deserialization", signed.SigningHeight)
+	}
+	if n, _ := signed.Sign(signer); n != 1 {
+		t.Fatalf("signed %d programs, want 1", n)
+	}
+	if err := ptx.Merge(&signed); err != nil {
+		t.Fatal(err)
+	}
+	if !ptx.IsCompleted() {
+		t.Fatal("merge dropped the signature")
+	}
+	final, err := ptx.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crypto.Verify(signer.pubKey, sig.GetHashDataAt(final, 99), final.Programs[0].Parameter[1:]) != nil {
+		t.Fatal("not signed for the block at the signing height")
+	}
+}
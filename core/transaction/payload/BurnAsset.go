@@ -0,0 +1,34 @@
+package payload
+
+import (
+	"io"
+
+	. "IPT/common"
+)
+
+const BurnAssetPayloadVersion byte = 0x00
+
+// BurnAsset destroys Amount of the asset spent by the transaction inputs and
+// lowers its issued quantity.
+type BurnAsset struct {
+	AssetID Uint256
+	Amount  Fixed64
+}
+
+func (p *BurnAsset) Data(version byte) []byte {
+	return []byte{0}
+}
+
+func (p *BurnAsset) Serialize(w io.Writer, version byte) error {
+	if _, err := p.AssetID.Serialize(w); err != nil {
+		return err
+	}
+	return p.Amount.Serialize(w)
+}
+
+func (p *BurnAsset) Deserialize(r io.Reader, version byte) error {
+	if err := p.AssetID.Deserialize(r); err != nil {
+		return err
+	}
+	return p.Amount.Deserialize(r)
+}
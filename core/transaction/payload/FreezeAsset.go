@@ -0,0 +1,47 @@
+package payload
+
+import (
+	"io"
+
+	. "IPT/common"
+	"IPT/common/serialization"
+)
+
+const FreezeAssetPayloadVersion byte = 0x00
+
+// FreezeAsset freezes or unfreezes the holdings of ProgramHash in the asset.
+// Only the controller recorded in RegisterAsset may sign it.
+type FreezeAsset struct {
+	AssetID     Uint256
+	ProgramHash Uint160
+	Freeze      bool
+}
+
+func (p *FreezeAsset) Data(version byte) []byte {
+	return []byte{0}
+}
+
+func (p *FreezeAsset) Serialize(w io.Writer, version byte) error {
+	if _, err := p.AssetID.Serialize(w); err != nil {
+		return err
+	}
+	if _, err := p.ProgramHash.Serialize(w); err != nil {
+		return err
+	}
+	return serialization.WriteBool(w, p.Freeze)
+}
+
+func (p *FreezeAsset) Deserialize(r io.Reader, version byte) error {
+	if err := p.AssetID.Deserialize(r); err != nil {
+		return err
+	}
+	if err := p.ProgramHash.Deserialize(r); err != nil {
+		return err
+	}
+	freeze, err := serialization.ReadBool(r)
+	if err != nil {
+		return err
+	}
+	p.Freeze = freeze
+	return nil
+}
@@ -5,13 +5,25 @@ import (
 
 	. "IPT/common"
 	"IPT/common/serialization"
+	"IPT/core/asset"
 )
 
+const LockAssetPayloadVersion byte = 0x00
+
+// locks from this version on carry a vesting schedule
+const LockAssetVestingPayloadVersion byte = 0x01
+
+// LockAsset locks Amount until UnlockHeight. With a vesting schedule nothing
+// vests before CliffHeight and the amount is released from StartHeight on.
 type LockAsset struct {
 	ProgramHash  Uint160
 	AssetID      Uint256
 	Amount       Fixed64
 	UnlockHeight uint32
+	StartHeight  uint32
+	CliffHeight  uint32
+	Release      asset.ReleaseType
+	Steps        uint32
 }
 
 func (p *LockAsset) Data(version byte) []byte {
@@ -33,6 +45,21 @@ func (p *LockAsset) Serialize(w io.Writer, version byte) error {
 	if err := serialization.WriteUint32(w, p.UnlockHeight); err != nil {
 		return err
 	}
+	if version < LockAssetVestingPayloadVersion {
+		return nil
+	}
+	if err := serialization.WriteUint32(w, p.StartHeight); err != nil {
+		return err
+	}
+	if err := serialization.WriteUint32(w, p.CliffHeight); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte{byte(p.Release)}); err != nil {
+		return err
+	}
+	if err := serialization.WriteUint32(w, p.Steps); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -52,6 +79,23 @@ func (p *LockAsset) Deserialize(r io.Reader, version byte) error {
 		return err
 	}
 	p.UnlockHeight = height
+	if version < LockAssetVestingPayloadVersion {
+		return nil
+	}
+	if p.StartHeight, err = serialization.ReadUint32(r); err != nil {
+		return err
+	}
+	if p.CliffHeight, err = serialization.ReadUint32(r); err != nil {
+		return err
+	}
+	release, err := serialization.ReadBytes(r, 1)
+	if err != nil {
+		return err
+	}
+	p.Release = asset.ReleaseType(release[0])
+	if p.Steps, err = serialization.ReadUint32(r); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -4,6 +4,9 @@ import "io"
 
 const TransferAssetayloadVersion byte = 0x00
 
+// transfers from this version on carry balance inputs
+const TransferAssetBalancePayloadVersion byte = 0x01
+
 type TransferAsset struct {
 }
 
@@ -26,6 +26,8 @@ const (
 	IssueAsset     TransactionType = 0x01
 	BookKeeper     TransactionType = 0x02
 	LockAsset      TransactionType = 0x03
+	BurnAsset      TransactionType = 0x04
+	FreezeAsset    TransactionType = 0x05
 	PrivacyPayload TransactionType = 0x20
 	RegisterAsset  TransactionType = 0x40
 	TransferAsset  TransactionType = 0x80
@@ -65,6 +67,10 @@ type Payload interface {
 
 var TxStore ILedgerStore
 
+// UnconfirmedTx looks up a transaction waiting in the transaction pool, so
+// that other transactions can spend its outputs before it is in a block.
+var UnconfirmedTx func(hash Uint256) *Transaction
+
 type Transaction struct {
 	TxType         TransactionType
 	PayloadVersion byte
@@ -80,7 +86,8 @@ type Transaction struct {
 	AssetInputAmount  map[Uint256]Fixed64
 	AssetOutputAmount map[Uint256]Fixed64
 
-	hash *Uint256
+	hash    *Uint256
+	inBlock map[Uint256]*Transaction
 }
 
 //Serialize the Transaction
@@ -138,7 +145,16 @@ func (tx *Transaction) SerializeUnsigned(w io.Writer) error {
 			utxo.Serialize(w)
 		}
 	}
-	// TODO BalanceInputs
+	//[]*BalanceInputs
+	if tx.HasBalanceInputs() {
+		err = serialization.WriteVarUint(w, uint64(len(tx.BalanceInputs)))
+		if err != nil {
+			return NewDetailErr(err, ErrNoCode, "Transaction item BalanceInputs length serialization failed.")
+		}
+		for _, input := range tx.BalanceInputs {
+			input.Serialize(w)
+		}
+	}
 	//[]*Outputs
 	err = serialization.WriteVarUint(w, uint64(len(tx.Outputs)))
 	if err != nil {
@@ -209,6 +225,10 @@ func (tx *Transaction) DeserializeUnsignedWithoutType(r io.Reader) error {
 		tx.Payload = new(payload.LockAsset)
 	case IssueAsset:
 		tx.Payload = new(payload.IssueAsset)
+	case BurnAsset:
+		tx.Payload = new(payload.BurnAsset)
+	case FreezeAsset:
+		tx.Payload = new(payload.FreezeAsset)
 	case TransferAsset:
 		tx.Payload = new(payload.TransferAsset)
 	case BookKeeping:
@@ -266,7 +286,20 @@ func (tx *Transaction) DeserializeUnsignedWithoutType(r io.Reader) error {
 			tx.UTXOInputs = append(tx.UTXOInputs, utxo)
 		}
 	}
-	//TODO balanceInputs
+	//BalanceInputs
+	if tx.HasBalanceInputs() {
+		Len, err = serialization.ReadVarUint(r, 0)
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < Len; i++ {
+			input := new(BalanceTxInput)
+			if err := input.Deserialize(r); err != nil {
+				return err
+			}
+			tx.BalanceInputs = append(tx.BalanceInputs, input)
+		}
+	}
 	//Outputs
 	Len, err = serialization.ReadVarUint(r, 0)
 	if err != nil {
@@ -298,6 +331,10 @@ func (tx *Transaction) GetProgramHashes() ([]Uint160, error) {
 		programHash := output.ProgramHash
 		hashs = append(hashs, programHash)
 	}
+	// the accounts balance inputs spend from
+	for _, input := range tx.BalanceInputs {
+		hashs = append(hashs, input.ProgramHash)
+	}
 	for _, attribute := range tx.Attributes {
 		if attribute.Usage == Script {
 			dataHash, err := Uint160ParseFromBytes(attribute.Data)
@@ -343,6 +380,17 @@ func (tx *Transaction) GetProgramHashes() ([]Uint160, error) {
 				return nil, NewDetailErr(errors.New("[Transaction] error"), ErrNoCode, fmt.Sprintf("[Transaction], payload is illegal", k))
 			}
 		}
+	case FreezeAsset:
+		assetID := tx.Payload.(*payload.FreezeAsset).AssetID
+		regTx, err := TxStore.GetTransaction(assetID)
+		if err != nil {
+			return nil, NewDetailErr(err, ErrNoCode, fmt.Sprintf("[Transaction], GetTransaction failed With AssetID:=%x", assetID))
+		}
+		reg, ok := regTx.Payload.(*payload.RegisterAsset)
+		if !ok {
+			return nil, NewDetailErr(errors.New("[Transaction] error"), ErrNoCode, fmt.Sprintf("[Transaction], Transaction Type ileage With AssetID:=%x", assetID))
+		}
+		hashs = append(hashs, reg.Controller)
 	case DataFile:
 		issuer := tx.Payload.(*payload.DataFile).Issuer
 		signatureRedeemScript, err := contract.CreateSignatureRedeemScript(issuer)
@@ -356,6 +404,7 @@ func (tx *Transaction) GetProgramHashes() ([]Uint160, error) {
 		}
 		hashs = append(hashs, astHash)
 	case TransferAsset:
+	case BurnAsset:
 	case Record:
 	case DeployCode:
 	case InvokeCode:
@@ -417,8 +466,10 @@ func (tx *Transaction) GenerateAssetMaps() {
 	//TODO: implement Transaction.GenerateAssetMaps()
 }
 
+// GetMessage returns the data the programs of tx sign, bound to the network
+// for the block tx is expected in.
 func (tx *Transaction) GetMessage() []byte {
-	return sig.GetHashData(tx)
+	return sig.GetHashDataAt(tx, sig.SigningHeight())
 }
 
 func (tx *Transaction) ToArray() []byte {
@@ -450,6 +501,11 @@ func (tx *Transaction) Verify() error {
 	return nil
 }
 
+// HasBalanceInputs reports whether the serialized tx carries balance inputs.
+func (tx *Transaction) HasBalanceInputs() bool {
+	return tx.TxType == TransferAsset && tx.PayloadVersion >= payload.TransferAssetBalancePayloadVersion
+}
+
 func (tx *Transaction) GetReference() (map[*UTXOTxInput]*TxOutput, error) {
 	if tx.TxType == RegisterAsset {
 		return nil, nil
@@ -458,15 +514,48 @@ func (tx *Transaction) GetReference() (map[*UTXOTxInput]*TxOutput, error) {
 	reference := make(map[*UTXOTxInput]*TxOutput)
 	// Key index，v UTXOInput
 	for _, utxo := range tx.UTXOInputs {
-		transaction, err := TxStore.GetTransaction(utxo.ReferTxID)
+		transaction, err := tx.referTransaction(utxo.ReferTxID)
 		if err != nil {
 			return nil, NewDetailErr(err, ErrNoCode, "[Transaction], GetReference failed.")
 		}
 		index := utxo.ReferTxOutputIndex
+		if int(index) >= len(transaction.Outputs) {
+			return nil, NewDetailErr(errors.New("invalid input index"), ErrNoCode, "[Transaction], GetReference failed.")
+		}
 		reference[utxo] = transaction.Outputs[index]
 	}
 	return reference, nil
 }
+
+// SetBlockTransactions lets GetReference find the transactions tx spends from
+// among txns, the transactions of the block holding tx which are not in the
+// ledger yet while the block is verified.
+func (tx *Transaction) SetBlockTransactions(txns map[Uint256]*Transaction) {
+	tx.inBlock = txns
+}
+
+// referTransaction returns the transaction of hash tx spends from, looking in
+// the block holding tx first.
+func (tx *Transaction) referTransaction(hash Uint256) (*Transaction, error) {
+	if txn, ok := tx.inBlock[hash]; ok {
+		return txn, nil
+	}
+	return GetReferTransaction(hash)
+}
+
+// GetReferTransaction returns the transaction an input refers to from the
+// ledger, or from the unconfirmed transactions when it is not in a block yet.
+func GetReferTransaction(hash Uint256) (*Transaction, error) {
+	txn, err := TxStore.GetTransaction(hash)
+	if err == nil || UnconfirmedTx == nil {
+		return txn, err
+	}
+	if pending := UnconfirmedTx(hash); pending != nil {
+		return pending, nil
+	}
+	return nil, err
+}
+
 func (tx *Transaction) GetTransactionResults() (TransactionResult, error) {
 	result := make(map[Uint256]Fixed64)
 	outputResult := tx.GetMergedAssetIDValueFromOutputs()
@@ -474,6 +563,9 @@ func (tx *Transaction) GetTransactionResults() (TransactionResult, error) {
 	if err != nil {
 		return nil, err
 	}
+	for _, v := range tx.BalanceInputs {
+		InputResult[v.AssetID] += v.Value
+	}
 	//calc the balance of input vs output
 	for outputAssetid, outputValue := range outputResult {
 		if inputValue, ok := InputResult[outputAssetid]; ok {
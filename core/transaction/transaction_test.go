@@ -0,0 +1,100 @@
+package transaction
+
+import (
+	"bytes"
+	"testing"
+
+	"IPT/common/config"
+	sig "IPT/core/signature"
+)
+
+func TestNetworkBoundMessage(t *testing.T) {
+	magic, height := config.Parameters.Magic, config.Parameters.SigningMagicHeight
+	defer func() {
+		config.Parameters.Magic, config.Parameters.SigningMagicHeight = magic, height
+		sig.SigningHeight = func() uint32 { return ^uint32(0) }
+	}()
+	txn, _ := NewTransferAssetTransaction(nil, []*TxOutput{{Value: 1}})
+
+	config.Parameters.SigningMagicHeight = 0
+	if !bytes.Equal(txn.GetMessage(), sig.GetHashData(txn)) {
+		t.Fatal("message bound to the network without an activation height")
+	}
+
+	config.Parameters.SigningMagicHeight = 100
+	sig.SigningHeight = func() uint32 { return 99 }
+	if !bytes.Equal(txn.GetMessage(), sig.GetHashData(txn)) {
+		t.Fatal("message bound to the network before the activation height")
+	}
+	sig.SigningHeight = func() uint32 { return 100 }
+	testnet := txn.GetMessage()
+	if bytes.Equal(testnet, sig.GetHashData(txn)) {
+		t.Fatal("message not bound to the network at the activation height")
+	}
+	config.Parameters.Magic++
+	if bytes.Equal(testnet, txn.GetMessage()) {
+		t.Fatal("message is the same on another network")
+	}
+}
+
+func TestSigningHeights(t *testing.T) {
+	height, grace := config.Parameters.SigningMagicHeight, config.Parameters.SigningMagicGrace
+	defer func() {
+		config.Parameters.SigningMagicHeight, config.Parameters.SigningMagicGrace = height, grace
+	}()
+	config.Parameters.SigningMagicHeight = 100
+	config.Parameters.SigningMagicGrace = 5
+
+	for _, c := range []struct {
+		height uint32
+		want   []uint32
+	}{
+		{94, []uint32{94}},
+		{95, []uint32{95}},
+		{96, []uint32{96, 100}},
+		{99, []uint32{99, 100}},
+		{100, []uint32{100, 99}},
+		{104, []uint32{104, 99}},
+		{105, []uint32{105}},
+	} {
+		got := sig.SigningHeights(c.height)
+		if len(got) != len(c.want) || got[0] != c.want[0] || got[len(got)-1] != c.want[len(c.want)-1] {
+			t.Fatalf("signing heights %v at %d, want %v", got, c.height, c.want)
+		}
+	}
+}
+
+func TestValidUntil(t *testing.T) {
+	txn, _ := NewTransferAssetTransaction(nil, []*TxOutput{{Value: 1}})
+	if height, err := txn.ValidUntil(); err != nil || height != ^uint32(0) || txn.IsExpired(^uint32(0)) {
+		t.Fatalf("transaction without the attribute valid until %d: %v", height, err)
+	}
+
+	txn.SetValidUntil(10)
+	txn.SetValidUntil(20)
+	if height, err := txn.ValidUntil(); err != nil || height != 20 || len(txn.Attributes) != 1 {
+		t.Fatalf("valid until %d with %d attributes: %v", height, len(txn.Attributes), err)
+	}
+	if txn.IsExpired(20) || !txn.IsExpired(21) {
+		t.Fatal("transaction not expiring right after its valid until height")
+	}
+
+	var decoded Transaction
+	if err := decoded.Deserialize(bytes.NewReader(txn.ToArray())); err != nil {
+		t.Fatal(err)
+	}
+	if height, err := decoded.ValidUntil(); err != nil || height != 20 {
+		t.Fatalf("deserialized transaction valid until %d: %v", height, err)
+	}
+
+	txn.Attributes[0].Data = txn.Attributes[0].Data[:3]
+	if _, err := txn.ValidUntil(); err == nil || !txn.IsExpired(0) {
+		t.Fatal("malformed attribute accepted")
+	}
+	txn.SetValidUntil(20)
+	attr := NewTxAttribute(ValidUntilHeight, txn.Attributes[0].Data)
+	txn.Attributes = append(txn.Attributes, &attr)
+	if _, err := txn.ValidUntil(); err == nil {
+		t.Fatal("two valid until height attributes accepted")
+	}
+}
@@ -11,15 +11,17 @@ import (
 type TransactionAttributeUsage byte
 
 const (
-	Nonce          TransactionAttributeUsage = 0x00
-	Script         TransactionAttributeUsage = 0x20
-	DescriptionUrl TransactionAttributeUsage = 0x81
-	Description    TransactionAttributeUsage = 0x90
-	FileHash       TransactionAttributeUsage = 0x91
+	Nonce            TransactionAttributeUsage = 0x00
+	Fee              TransactionAttributeUsage = 0x10
+	ValidUntilHeight TransactionAttributeUsage = 0x11
+	Script           TransactionAttributeUsage = 0x20
+	DescriptionUrl   TransactionAttributeUsage = 0x81
+	Description      TransactionAttributeUsage = 0x90
+	FileHash         TransactionAttributeUsage = 0x91
 )
 
 func IsValidAttributeType(usage TransactionAttributeUsage) bool {
-	return usage == Nonce || usage == Script ||
+	return usage == Nonce || usage == Fee || usage == ValidUntilHeight || usage == Script ||
 		usage == DescriptionUrl || usage == Description || usage == FileHash
 }
 
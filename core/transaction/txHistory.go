@@ -0,0 +1,66 @@
+package transaction
+
+import (
+	"IPT/common"
+	"IPT/common/serialization"
+	"io"
+)
+
+type TxDirection byte
+
+const (
+	TxIncoming TxDirection = 0x01
+	TxOutgoing TxDirection = 0x02
+)
+
+func (d TxDirection) String() string {
+	switch d {
+	case TxIncoming:
+		return "in"
+	case TxOutgoing:
+		return "out"
+	}
+	return "unknown"
+}
+
+// UnknownTxType is the type of the history entries whose transaction was
+// pruned before the history kept transaction types.
+const UnknownTxType TransactionType = 0xff
+
+// TxHistory is one entry of an address statement: the amount of an asset the
+// address received or spent in a transaction.
+type TxHistory struct {
+	Height    uint32
+	Txid      common.Uint256
+	TxType    TransactionType
+	Direction TxDirection
+	AssetID   common.Uint256
+	Value     common.Fixed64
+}
+
+// Serialize writes the part of the entry stored as index value, the rest is
+// kept in the index key.
+func (th *TxHistory) Serialize(w io.Writer) error {
+	if _, err := th.Txid.Serialize(w); err != nil {
+		return err
+	}
+	if err := th.Value.Serialize(w); err != nil {
+		return err
+	}
+	return serialization.WriteUint8(w, uint8(th.TxType))
+}
+
+func (th *TxHistory) Deserialize(r io.Reader) error {
+	if err := th.Txid.Deserialize(r); err != nil {
+		return err
+	}
+	if err := th.Value.Deserialize(r); err != nil {
+		return err
+	}
+	txType, err := serialization.ReadUint8(r)
+	if err != nil {
+		return err
+	}
+	th.TxType = TransactionType(txType)
+	return nil
+}
@@ -0,0 +1,95 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+
+	. "IPT/common"
+	"IPT/core/ledger"
+	tx "IPT/core/transaction"
+)
+
+// CheckBalanceInputs checks the balance inputs of txn without the ledger.
+// Every account spends each asset at most once and with one nonce.
+func CheckBalanceInputs(txn *tx.Transaction) error {
+	if len(txn.BalanceInputs) == 0 {
+		return nil
+	}
+	if !txn.HasBalanceInputs() {
+		return errors.New("balance inputs are not serialized with this transaction")
+	}
+	nonces := make(map[Uint160]uint64)
+	spent := make(map[Uint160]map[Uint256]bool)
+	for _, input := range txn.BalanceInputs {
+		if input.Value <= 0 {
+			return errors.New("invalid balance input value")
+		}
+		if nonce, ok := nonces[input.ProgramHash]; ok && nonce != input.Nonce {
+			return errors.New(fmt.Sprintf("balance inputs of account %x use more than one nonce", input.ProgramHash))
+		}
+		nonces[input.ProgramHash] = input.Nonce
+		if _, ok := spent[input.ProgramHash]; !ok {
+			spent[input.ProgramHash] = make(map[Uint256]bool)
+		}
+		if spent[input.ProgramHash][input.AssetID] {
+			return errors.New(fmt.Sprintf("duplicated balance input of account %x", input.ProgramHash))
+		}
+		spent[input.ProgramHash][input.AssetID] = true
+	}
+	return nil
+}
+
+// CheckBalanceInputsWithLedger checks the balance inputs of txns, a block or
+// the transaction pool, against the ledger. The inputs must spend balance-model
+// assets with nonces not used yet and, summed over txns, not more than the
+// unlocked balance of each account.
+func CheckBalanceInputsWithLedger(txns []*tx.Transaction, ledger *ledger.Ledger) error {
+	balanceAssets := make(map[Uint256]bool)
+	nonces := make(map[Uint160]map[uint64]Uint256)
+	spent := make(map[Uint160]map[Uint256]Fixed64)
+	for _, txn := range txns {
+		txHash := txn.Hash()
+		for _, input := range txn.BalanceInputs {
+			balance, ok := balanceAssets[input.AssetID]
+			if !ok {
+				balance = ledger.Store.IsBalanceAsset(input.AssetID)
+				balanceAssets[input.AssetID] = balance
+			}
+			if !balance {
+				return errors.New(fmt.Sprintf("asset %x is not a balance-model asset", input.AssetID))
+			}
+
+			if _, ok := nonces[input.ProgramHash]; !ok {
+				nonces[input.ProgramHash] = make(map[uint64]Uint256)
+				spent[input.ProgramHash] = make(map[Uint256]Fixed64)
+			}
+			if other, ok := nonces[input.ProgramHash][input.Nonce]; ok && other != txHash {
+				return errors.New(fmt.Sprintf("nonce %d of account %x used twice", input.Nonce, input.ProgramHash))
+			}
+			nonces[input.ProgramHash][input.Nonce] = txHash
+			spent[input.ProgramHash][input.AssetID] += input.Value
+		}
+	}
+
+	for programHash, used := range nonces {
+		next, err := ledger.Store.GetAccountNonce(programHash)
+		if err != nil {
+			return err
+		}
+		for nonce := range used {
+			if nonce < next {
+				return errors.New(fmt.Sprintf("nonce %d of account %x already used", nonce, programHash))
+			}
+		}
+		for assetID, value := range spent[programHash] {
+			total, locked, err := ledger.Store.GetAvailableAsset(programHash, assetID)
+			if err != nil {
+				return err
+			}
+			if total < value+locked {
+				return errors.New(fmt.Sprintf("balance of account %x is not enough", programHash))
+			}
+		}
+	}
+	return nil
+}
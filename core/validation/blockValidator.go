@@ -1,6 +1,7 @@
 package validation
 
 import (
+	. "IPT/common"
 	"IPT/core/ledger"
 	tx "IPT/core/transaction"
 	. "IPT/common/errors"
@@ -34,6 +35,13 @@ func VerifyBlock(block *ledger.Block, ld *ledger.Ledger, completely bool) error
 		}
 	}
 
+	// the state root can only be checked against the block before it
+	if block.Blockdata.Version >= ledger.BlockVersionStateRoot &&
+		ld.Store.GetHeight()+1 == block.Blockdata.Height &&
+		ld.Store.GetStateRoot() != block.Blockdata.StateRoot {
+		return errors.New(fmt.Sprintf("Block state root does not match the ledger state."))
+	}
+
 	//verfiy block's transactions
 	if completely {
 		/*
@@ -46,8 +54,18 @@ func VerifyBlock(block *ledger.Block, ld *ledger.Ledger, completely bool) error
 				return errors.New(fmt.Sprintf("BookKeeper is not validate."))
 			}
 		*/
+		// a transaction may spend outputs of those before it in the block,
+		// which are neither in the ledger nor in the transaction pool
+		if err := CheckTransactionOrder(block.Transactions, ld); err != nil {
+			return err
+		}
+		inBlock := make(map[Uint256]*tx.Transaction, len(block.Transactions))
+		for _, txn := range block.Transactions {
+			inBlock[txn.Hash()] = txn
+		}
 		for _, txVerify := range block.Transactions {
-			if errCode := VerifyTransaction(txVerify); errCode != ErrNoError {
+			txVerify.SetBlockTransactions(inBlock)
+			if errCode := VerifyTransaction(txVerify, block.Blockdata.Height); errCode != ErrNoError {
 				return errors.New(fmt.Sprintf("VerifyTransaction failed when verifiy block"))
 			}
 			if errCode := VerifyTransactionWithLedger(txVerify, ledger.DefaultLedger); errCode != ErrNoError {
@@ -62,6 +80,11 @@ func VerifyBlock(block *ledger.Block, ld *ledger.Ledger, completely bool) error
 	return nil
 }
 
+// blockVersion is ledger.BlockVersionAt for funcs where ledger is shadowed.
+func blockVersion(height uint32) uint32 {
+	return ledger.BlockVersionAt(height)
+}
+
 func VerifyHeader(bd *ledger.Header, ledger *ledger.Ledger) error {
 	return VerifyBlockData(bd.Blockdata, ledger)
 }
@@ -87,5 +110,9 @@ func VerifyBlockData(bd *ledger.Blockdata, ledger *ledger.Ledger) error {
 		return NewDetailErr(errors.New("[BlockValidator] error"), ErrNoCode, "[BlockValidator], block timestamp is incorrect.")
 	}
 
+	if bd.Version != blockVersion(bd.Height) {
+		return NewDetailErr(errors.New("[BlockValidator] error"), ErrNoCode, "[BlockValidator], block version is incorrect.")
+	}
+
 	return nil
 }
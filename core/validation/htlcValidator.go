@@ -0,0 +1,43 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+
+	"IPT/core/contract"
+	"IPT/core/ledger"
+	tx "IPT/core/transaction"
+)
+
+// CheckHTLCPrograms checks the programs of txn spending hash time-locked
+// contracts. A redeem must reveal the preimage and expire by the timeout of
+// the contract, a refund may only enter a block above the timeout.
+func CheckHTLCPrograms(txn *tx.Transaction, ledger *ledger.Ledger) error {
+	for _, p := range txn.Programs {
+		htlc, err := contract.ParseHTLC(p.Code)
+		if err != nil {
+			continue
+		}
+		_, preimage, err := contract.ParseHTLCParameter(p.Parameter)
+		if err != nil {
+			return err
+		}
+		if preimage == nil {
+			if height := ledger.Store.GetHeight() + 1; height <= htlc.Timeout {
+				return errors.New(fmt.Sprintf("hash time-locked contract refundable after height %d", htlc.Timeout))
+			}
+			continue
+		}
+		if !htlc.Unlocks(preimage) {
+			return errors.New("preimage does not match the hash lock")
+		}
+		validUntil, err := txn.ValidUntil()
+		if err != nil {
+			return err
+		}
+		if validUntil > htlc.Timeout {
+			return errors.New(fmt.Sprintf("hash time-locked contract redeem valid beyond height %d", htlc.Timeout))
+		}
+	}
+	return nil
+}
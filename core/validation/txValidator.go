@@ -10,13 +10,15 @@ import (
 	"IPT/common/log"
 	"IPT/core/asset"
 	"IPT/core/ledger"
+	sig "IPT/core/signature"
 	tx "IPT/core/transaction"
 	"IPT/core/transaction/payload"
 	"IPT/crypto"
 	. "IPT/common/errors"
 )
 
-func VerifyTransaction(txn *tx.Transaction) ErrCode {
+// VerifyTransaction checks txn on its own for the block at height.
+func VerifyTransaction(txn *tx.Transaction, height uint32) ErrCode {
 
 	if err := CheckDuplicateInput(txn); err != nil {
 		log.Warn("[VerifyTransaction],", err)
@@ -28,7 +30,7 @@ func VerifyTransaction(txn *tx.Transaction) ErrCode {
 		return ErrAssetPrecision
 	}
 
-	if err := CheckTransactionBalance(txn); err != nil {
+	if err := CheckTransactionBalance(txn, height); err != nil {
 		log.Warn("[VerifyTransaction],", err)
 		return ErrTransactionBalance
 	}
@@ -38,7 +40,7 @@ func VerifyTransaction(txn *tx.Transaction) ErrCode {
 		return ErrAttributeProgram
 	}
 
-	if err := CheckTransactionContracts(txn); err != nil {
+	if err := CheckTransactionContracts(txn, height); err != nil {
 		log.Warn("[VerifyTransaction],", err)
 		return ErrTransactionContracts
 	}
@@ -55,11 +57,11 @@ func VerifyTransaction(txn *tx.Transaction) ErrCode {
 func VerifyTransactionWithBlock(TxPool []*tx.Transaction) error {
 	//initial
 	txnlist := make(map[Uint256]*tx.Transaction, 0)
-	var txPoolInputs []string
-	//sum all inputs in TxPool
+	txPoolInputs := make(map[string]int)
+	//count all inputs in TxPool
 	for _, Tx := range TxPool {
 		for _, UTXOinput := range Tx.UTXOInputs {
-			txPoolInputs = append(txPoolInputs, UTXOinput.ToString())
+			txPoolInputs[UTXOinput.ToString()]++
 		}
 	}
 	//start check
@@ -122,23 +124,40 @@ func VerifyTransactionWithBlock(TxPool []*tx.Transaction) error {
 		}
 
 	}
+	//4.check balance inputs
+	if err := CheckBalanceInputsWithLedger(TxPool, ledger.DefaultLedger); err != nil {
+		return err
+	}
+	//5.check transactions come after those they spend from
+	if err := CheckTransactionOrder(TxPool, ledger.DefaultLedger); err != nil {
+		return err
+	}
+	//6.check the bookkeeper collects the fees
+	if err := CheckBookKeepingFees(TxPool); err != nil {
+		return err
+	}
 
 	return nil
 }
 
 func CheckLockedAsset(txn *tx.Transaction, ledger *ledger.Ledger) error {
-	// onlu check locked asset for transfer transaction
-	if txn.TxType != tx.TransferAsset {
+	// onlu check locked asset for transfer and burn transaction
+	if txn.TxType != tx.TransferAsset && txn.TxType != tx.BurnAsset {
 		return nil
 	}
 
 	// get spend asset amount for each program hash and asset ID pair
 	result := make(map[Uint160]map[Uint256]Fixed64)
+	// outputs of unconfirmed transactions are spendable but not in the ledger
+	unconfirmed := make(map[holding]Fixed64)
 	inputAsset, err := txn.GetReference()
 	if err != nil {
 		return err
 	}
-	for _, referOutput := range inputAsset {
+	for input, referOutput := range inputAsset {
+		if !ledger.Store.IsTxHashDuplicate(input.ReferTxID) {
+			unconfirmed[holding{referOutput.ProgramHash, referOutput.AssetID}] += referOutput.Value
+		}
 		if _, ok := result[referOutput.ProgramHash]; !ok {
 			result[referOutput.ProgramHash] = make(map[Uint256]Fixed64)
 		}
@@ -159,11 +178,17 @@ func CheckLockedAsset(txn *tx.Transaction, ledger *ledger.Ledger) error {
 	// check if this transaction spends the locked asset
 	for programHash, assets := range result {
 		for assetID, spend := range assets {
+			pending := unconfirmed[holding{programHash, assetID}]
 			total, locked, err := ledger.Store.GetAvailableAsset(programHash, assetID)
 			if err != nil {
-				return err
+				// an account holding none of the asset in the ledger has
+				// nothing locked, it spends unconfirmed outputs only
+				if pending == 0 {
+					return err
+				}
+				total, locked = 0, 0
 			}
-			if total < spend+locked {
+			if total+pending < spend+locked {
 				return errors.New("token is not enough, locked token can't be used.")
 			}
 		}
@@ -179,6 +204,21 @@ func VerifyTransactionWithLedger(txn *tx.Transaction, ledger *ledger.Ledger) Err
 		return ErrTxHashDuplicate
 	}
 
+	if err := CheckTransactionExpiry(txn, ledger); err != nil {
+		log.Info("[VerifyTransactionWithLedger] ", err)
+		return ErrTransactionExpired
+	}
+
+	if err := CheckBalanceInputsWithLedger([]*tx.Transaction{txn}, ledger); err != nil {
+		log.Info("[VerifyTransactionWithLedger] ", err)
+		return ErrBalanceInput
+	}
+
+	if err := CheckHTLCPrograms(txn, ledger); err != nil {
+		log.Info("[VerifyTransactionWithLedger] ", err)
+		return ErrHTLC
+	}
+
 	if IsDoubleSpend(txn, ledger) {
 		log.Info("[VerifyTransactionWithLedger] double spend checking failed.")
 		return ErrDoubleSpend
@@ -189,9 +229,93 @@ func VerifyTransactionWithLedger(txn *tx.Transaction, ledger *ledger.Ledger) Err
 		return ErrLockedAsset
 	}
 
+	if err := CheckFrozenAsset(txn, ledger); err != nil {
+		log.Info("[VerifyTransactionWithLedger] ", err)
+		return ErrFrozenAsset
+	}
+
 	return ErrNoError
 }
 
+type holding struct {
+	programHash Uint160
+	assetID     Uint256
+}
+
+// holdings returns the account and asset pairs txn spends from or pays to.
+func holdings(txn *tx.Transaction) ([]holding, error) {
+	reference, err := txn.GetReference()
+	if err != nil {
+		return nil, err
+	}
+	held := []holding{}
+	for _, output := range reference {
+		held = append(held, holding{output.ProgramHash, output.AssetID})
+	}
+	for _, input := range txn.BalanceInputs {
+		held = append(held, holding{input.ProgramHash, input.AssetID})
+	}
+	for _, output := range txn.Outputs {
+		held = append(held, holding{output.ProgramHash, output.AssetID})
+	}
+	return held, nil
+}
+
+// CheckFrozenAsset checks that txn neither spends nor receives an asset the
+// controller froze for the program hash.
+func CheckFrozenAsset(txn *tx.Transaction, ledger *ledger.Ledger) error {
+	held, err := holdings(txn)
+	if err != nil {
+		return err
+	}
+	for _, h := range held {
+		frozen, err := ledger.Store.IsFrozen(h.assetID, h.programHash)
+		if err != nil {
+			return err
+		}
+		if frozen {
+			return errors.New(fmt.Sprintf("asset %x of account %x is frozen", h.assetID, h.programHash))
+		}
+	}
+	return nil
+}
+
+// CheckFrozenAssetWithTxns checks txn against the freezes of txns, which are
+// not in the ledger yet.
+func CheckFrozenAssetWithTxns(txn *tx.Transaction, txns []*tx.Transaction) error {
+	frozen := make(map[holding]bool)
+	for _, t := range txns {
+		if pld, ok := t.Payload.(*payload.FreezeAsset); ok && pld.Freeze {
+			frozen[holding{pld.ProgramHash, pld.AssetID}] = true
+		}
+	}
+	if len(frozen) == 0 {
+		return nil
+	}
+	held, err := holdings(txn)
+	if err != nil {
+		return err
+	}
+	for _, h := range held {
+		if frozen[h] {
+			return errors.New(fmt.Sprintf("asset %x of account %x is being frozen", h.assetID, h.programHash))
+		}
+	}
+	return nil
+}
+
+// CheckTransactionExpiry checks that txn may still be included in the next block.
+func CheckTransactionExpiry(txn *tx.Transaction, ledger *ledger.Ledger) error {
+	validUntil, err := txn.ValidUntil()
+	if err != nil {
+		return err
+	}
+	if height := ledger.Store.GetHeight() + 1; height > validUntil {
+		return errors.New(fmt.Sprintf("transaction %x expired at height %d", txn.Hash(), validUntil))
+	}
+	return nil
+}
+
 //validate the transaction of duplicate UTXO input
 func CheckDuplicateInput(tx *tx.Transaction) error {
 	if len(tx.UTXOInputs) == 0 {
@@ -207,23 +331,53 @@ func CheckDuplicateInput(tx *tx.Transaction) error {
 	return nil
 }
 
-func CheckDuplicateUtxoInBlock(tx *tx.Transaction, txPoolInputs []string) error {
-	var txInputs []string
+// CheckDuplicateUtxoInBlock checks that no UTXO input of tx is spent again
+// by the transactions of its block, whose inputs txPoolInputs counts.
+func CheckDuplicateUtxoInBlock(tx *tx.Transaction, txPoolInputs map[string]int) error {
 	for _, t := range tx.UTXOInputs {
-		txInputs = append(txInputs, t.ToString())
-	}
-	for _, i := range txInputs {
-		for _, j := range txPoolInputs {
-			if i == j {
-				return errors.New("Duplicated UTXO inputs found in tx pool")
-			}
+		if txPoolInputs[t.ToString()] > 1 {
+			return errors.New("Duplicated UTXO inputs found in tx pool")
 		}
 	}
 	return nil
 }
 
-func IsDoubleSpend(tx *tx.Transaction, ledger *ledger.Ledger) bool {
-	return ledger.IsDoubleSpend(tx)
+func IsDoubleSpend(txn *tx.Transaction, ledger *ledger.Ledger) bool {
+	// outputs of unconfirmed transactions are checked by the transaction pool
+	confirmed := &tx.Transaction{}
+	for _, input := range txn.UTXOInputs {
+		if ledger.Store.IsTxHashDuplicate(input.ReferTxID) {
+			confirmed.UTXOInputs = append(confirmed.UTXOInputs, input)
+		}
+	}
+	return ledger.IsDoubleSpend(confirmed)
+}
+
+// CheckTransactionOrder checks that the transactions of a block only spend
+// outputs of the ledger or of transactions before them in the block, and
+// spend the balance of each account in nonce order.
+func CheckTransactionOrder(txns []*tx.Transaction, ledger *ledger.Ledger) error {
+	included := make(map[Uint256]bool, len(txns))
+	nonces := make(map[Uint160]uint64)
+	for _, txn := range txns {
+		for _, input := range txn.UTXOInputs {
+			if !included[input.ReferTxID] && !ledger.Store.IsTxHashDuplicate(input.ReferTxID) {
+				return errors.New(fmt.Sprintf("transaction %x spends %x before it", txn.Hash(), input.ReferTxID))
+			}
+		}
+		spent := make(map[Uint160]uint64)
+		for _, input := range txn.BalanceInputs {
+			if next, ok := nonces[input.ProgramHash]; ok && input.Nonce < next {
+				return errors.New(fmt.Sprintf("transaction %x spends nonce %d of account %x after a higher one", txn.Hash(), input.Nonce, input.ProgramHash))
+			}
+			spent[input.ProgramHash] = input.Nonce + 1
+		}
+		for programHash, next := range spent {
+			nonces[programHash] = next
+		}
+		included[txn.Hash()] = true
+	}
+	return nil
 }
 
 func CheckAssetPrecision(Tx *tx.Transaction) error {
@@ -250,22 +404,61 @@ func CheckAssetPrecision(Tx *tx.Transaction) error {
 	return nil
 }
 
-func CheckTransactionBalance(Tx *tx.Transaction) error {
+func CheckTransactionBalance(Tx *tx.Transaction, height uint32) error {
 	for _, v := range Tx.Outputs {
 		if v.Value <= Fixed64(0) {
 			return errors.New("Invalid transaction UTXO output.")
 		}
 	}
-	if Tx.TxType == tx.IssueAsset {
+	if err := CheckBalanceInputs(Tx); err != nil {
+		return err
+	}
+	switch Tx.TxType {
+	case tx.IssueAsset:
 		if len(Tx.UTXOInputs) > 0 {
 			return errors.New("Invalide Issue transaction.")
 		}
 		return nil
+	case tx.BookKeeping:
+		// the outputs are the fees of the block, see CheckBookKeepingFees
+		if len(Tx.UTXOInputs) > 0 || len(Tx.BalanceInputs) > 0 {
+			return errors.New("Invalid BookKeeping transaction.")
+		}
+		return nil
 	}
-	results, err := Tx.GetTransactionResults()
+	results, err := Tx.PaidFees()
 	if err != nil {
 		return err
 	}
+	if !tx.FeeDeclared(height) {
+		return checkImplicitFee(Tx, results)
+	}
+	fee, err := Tx.Fee()
+	if err != nil {
+		return err
+	}
+	// the inputs may only exceed the outputs by the declared fee
+	var paid Fixed64
+	for k, v := range results {
+		if v < 0 {
+			return errors.New(fmt.Sprintf("AssetID %x in Transfer transactions %x, output > input.", k, Tx.Hash()))
+		}
+		if v == 0 {
+			continue
+		}
+		if paid != 0 {
+			return errors.New(fmt.Sprintf("Transaction %x pays fees in more than one asset.", Tx.Hash()))
+		}
+		paid = v
+	}
+	if paid != fee {
+		return errors.New(fmt.Sprintf("Transaction %x declares fee %v but pays %v.", Tx.Hash(), fee, paid))
+	}
+	return nil
+}
+
+// checkImplicitFee is the balance rule before DeclaredFeeHeight.
+func checkImplicitFee(Tx *tx.Transaction, results tx.TransactionResult) error {
 	for k, v := range results {
 		// if transaction fee is not configured, input amount must equal to output
 		if fee, ok := config.Parameters.TransactionFee["Transfer"]; !ok {
@@ -289,18 +482,78 @@ func CheckTransactionBalance(Tx *tx.Transaction) error {
 	return nil
 }
 
-func CheckAttributeProgram(Tx *tx.Transaction) error {
-	//TODO: implement CheckAttributeProgram
+// CheckBookKeepingFees checks that the BookKeeping transaction of a block
+// pays out exactly the fees the other transactions pay, per asset.
+func CheckBookKeepingFees(txns []*tx.Transaction) error {
+	fees := make(map[Uint256]Fixed64)
+	var collected tx.TransactionResult
+	for _, txn := range txns {
+		if txn.TxType == tx.BookKeeping {
+			if collected != nil {
+				return errors.New("More than one BookKeeping transaction.")
+			}
+			collected = txn.GetMergedAssetIDValueFromOutputs()
+			continue
+		}
+		if txn.TxType == tx.IssueAsset {
+			continue
+		}
+		results, err := txn.PaidFees()
+		if err != nil {
+			return err
+		}
+		for k, v := range results {
+			if v > 0 {
+				fees[k] += v
+			}
+		}
+	}
+	for k, v := range collected {
+		if fees[k] != v {
+			return errors.New(fmt.Sprintf("BookKeeping transaction collects %v of asset %x, the fees are %v.", v, k, fees[k]))
+		}
+	}
+	for k, v := range fees {
+		if collected[k] != v {
+			return errors.New(fmt.Sprintf("BookKeeping transaction collects %v of asset %x, the fees are %v.", collected[k], k, v))
+		}
+	}
 	return nil
 }
 
-func CheckTransactionContracts(Tx *tx.Transaction) error {
-	flag, err := VerifySignableData(Tx)
-	if flag && err == nil {
+// CheckTransactionFee checks the declared fee against the minimum fee policy
+// of this node. Blocks are not checked, bookkeepers may set other policies.
+// Before DeclaredFeeHeight transactions pay the implicit fee instead.
+func CheckTransactionFee(Tx *tx.Transaction, height uint32) error {
+	if !tx.FeeDeclared(height) {
 		return nil
-	} else {
+	}
+	fee, err := Tx.Fee()
+	if err != nil {
 		return err
 	}
+	if minimum := Tx.MinimumFee(); fee < minimum {
+		return errors.New(fmt.Sprintf("Transaction %x declares fee %v, the minimum is %v.", Tx.Hash(), fee, minimum))
+	}
+	return nil
+}
+
+func CheckAttributeProgram(Tx *tx.Transaction) error {
+	//TODO: implement CheckAttributeProgram
+	return nil
+}
+
+// CheckTransactionContracts runs the programs of Tx, which may sign for any
+// of the SigningHeights of the block at height.
+func CheckTransactionContracts(Tx *tx.Transaction, height uint32) error {
+	var err error
+	for _, h := range sig.SigningHeights(height) {
+		var flag bool
+		if flag, err = VerifySignableData(sig.AtHeight(Tx, h)); flag && err == nil {
+			return nil
+		}
+	}
+	return err
 }
 
 func checkAmountPrecise(amount Fixed64, precision byte) bool {
@@ -319,6 +572,26 @@ func checkIssuerInBookkeeperList(issuer *crypto.PubKey, bookKeepers []*crypto.Pu
 	return false
 }
 
+func checkVestingSchedule(pld *payload.LockAsset) error {
+	if pld.StartHeight > pld.CliffHeight || pld.CliffHeight > pld.UnlockHeight {
+		return errors.New("Vesting heights must satisfy start <= cliff <= unlock.")
+	}
+	switch pld.Release {
+	case asset.ReleaseCliff:
+	case asset.ReleaseLinear:
+		if pld.StartHeight == pld.UnlockHeight {
+			return errors.New("Linear vesting needs a start below the unlock height.")
+		}
+	case asset.ReleaseStepped:
+		if pld.StartHeight == pld.UnlockHeight || pld.Steps == 0 {
+			return errors.New("Stepped vesting needs steps and a start below the unlock height.")
+		}
+	default:
+		return errors.New("Unknown vesting release type.")
+	}
+	return nil
+}
+
 func CheckTransactionPayload(Tx *tx.Transaction) error {
 
 	switch pld := Tx.Payload.(type) {
@@ -351,6 +624,33 @@ func CheckTransactionPayload(Tx *tx.Transaction) error {
 		if pld.UnlockHeight <= ledger.DefaultLedger.Store.GetHeight() {
 			return errors.New("expired LockAsset transaction detected")
 		}
+		if Tx.PayloadVersion >= payload.LockAssetVestingPayloadVersion {
+			if err := checkVestingSchedule(pld); err != nil {
+				return err
+			}
+		}
+	case *payload.BurnAsset:
+		if pld.Amount <= 0 {
+			return errors.New("Invalid burn amount.")
+		}
+		a, err := ledger.DefaultLedger.Store.GetAsset(pld.AssetID)
+		if err != nil {
+			return errors.New("The asset not exist in local blockchain.")
+		}
+		if checkAmountPrecise(pld.Amount, a.Precision) {
+			return errors.New("The precision of asset is incorrect.")
+		}
+		issued, err := ledger.DefaultLedger.Store.GetQuantityIssued(pld.AssetID)
+		if err != nil {
+			return errors.New("GetQuantityIssued failed.")
+		}
+		if pld.Amount > issued {
+			return errors.New("Burn amount exceeds the issued quantity.")
+		}
+	case *payload.FreezeAsset:
+		if _, err := ledger.DefaultLedger.Store.GetAsset(pld.AssetID); err != nil {
+			return errors.New("The asset not exist in local blockchain.")
+		}
 	case *payload.TransferAsset:
 	case *payload.BookKeeping:
 	case *payload.PrivacyPayload:
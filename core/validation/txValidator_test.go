@@ -0,0 +1,71 @@
+package validation
+
+import (
+	"testing"
+
+	. "IPT/common"
+	"IPT/common/config"
+	tx "IPT/core/transaction"
+	"IPT/core/transaction/payload"
+)
+
+// balanceTransfer spends in of an account's balance and pays out out.
+func balanceTransfer(assetID Uint256, in, out Fixed64) *tx.Transaction {
+	return &tx.Transaction{
+		TxType:         tx.TransferAsset,
+		PayloadVersion: payload.TransferAssetBalancePayloadVersion,
+		Payload:        &payload.TransferAsset{},
+		Attributes:     []*tx.TxAttribute{},
+		BalanceInputs:  []*tx.BalanceTxInput{{AssetID: assetID, Value: in, Nonce: 1}},
+		Outputs:        []*tx.TxOutput{{AssetID: assetID, Value: out}},
+	}
+}
+
+func TestCheckTransactionBalance(t *testing.T) {
+	defer func(height uint32, fees map[string]float64) {
+		config.Parameters.DeclaredFeeHeight = height
+		config.Parameters.TransactionFee = fees
+	}(config.Parameters.DeclaredFeeHeight, config.Parameters.TransactionFee)
+	config.Parameters.DeclaredFeeHeight = 10
+	config.Parameters.TransactionFee = map[string]float64{"Transfer": 1}
+
+	var assetID Uint256
+	implicit := balanceTransfer(assetID, 100, 90)
+	if err := CheckTransactionBalance(implicit, 9); err != nil {
+		t.Fatalf("implicit fee rejected before DeclaredFeeHeight: %v", err)
+	}
+	if err := CheckTransactionBalance(implicit, 10); err == nil {
+		t.Fatal("undeclared fee accepted from DeclaredFeeHeight")
+	}
+	declared := balanceTransfer(assetID, 100, 90)
+	declared.SetFee(10)
+	if err := CheckTransactionBalance(declared, 10); err != nil {
+		t.Fatalf("declared fee rejected: %v", err)
+	}
+	declared.SetFee(5)
+	if err := CheckTransactionBalance(declared, 10); err == nil {
+		t.Fatal("fee other than the declared one accepted")
+	}
+}
+
+func TestCheckBookKeepingFees(t *testing.T) {
+	var assetID Uint256
+	transfer := balanceTransfer(assetID, 100, 90)
+	transfer.SetFee(10)
+	bookKeeping := &tx.Transaction{
+		TxType:  tx.BookKeeping,
+		Payload: &payload.BookKeeping{},
+		Outputs: []*tx.TxOutput{{AssetID: assetID, Value: 10}},
+	}
+	if err := CheckBookKeepingFees([]*tx.Transaction{bookKeeping, transfer}); err != nil {
+		t.Fatalf("fees of the block rejected: %v", err)
+	}
+	bookKeeping.Outputs[0].Value = 11
+	if err := CheckBookKeepingFees([]*tx.Transaction{bookKeeping, transfer}); err == nil {
+		t.Fatal("BookKeeping transaction collecting more than the fees accepted")
+	}
+	bookKeeping.Outputs = nil
+	if err := CheckBookKeepingFees([]*tx.Transaction{bookKeeping, transfer}); err == nil {
+		t.Fatal("BookKeeping transaction dropping the fees accepted")
+	}
+}
@@ -109,3 +109,68 @@ func ComputeRoot(hashes []Uint256) (Uint256, error) {
 	tree, _ := NewMerkleTree(hashes)
 	return tree.Root.Hash, nil
 }
+
+// MerkleProof is the branch linking one leaf to the root of a MerkleTree.
+// Branch holds the sibling hashes from the leaf level up, Index is the
+// position of the leaf and tells on which side each sibling goes.
+type MerkleProof struct {
+	Index  uint32
+	Branch []Uint256
+}
+
+//input a []uint256 and the position of a leaf, build the branch of the leaf
+func NewMerkleProof(hashes []Uint256, index int) (*MerkleProof, error) {
+	if len(hashes) == 0 {
+		return nil, NewDetailErr(errors.New("NewMerkleProof input no item error."), ErrNoCode, "")
+	}
+	if index < 0 || index >= len(hashes) {
+		return nil, NewDetailErr(errors.New("NewMerkleProof index out of range error."), ErrNoCode, "")
+	}
+
+	proof := &MerkleProof{Index: uint32(index)}
+	level := hashes
+	for len(level) > 1 {
+		sibling := index ^ 1
+		// the last node of an odd level is paired with itself, see levelUp
+		if sibling == len(level) {
+			sibling = index
+		}
+		proof.Branch = append(proof.Branch, level[sibling])
+
+		var next []Uint256
+		for i := 0; i < len(level); i += 2 {
+			if i+1 < len(level) {
+				next = append(next, DOUBLE_SHA256([]Uint256{level[i], level[i+1]}))
+			} else {
+				next = append(next, DOUBLE_SHA256([]Uint256{level[i], level[i]}))
+			}
+		}
+		level = next
+		index /= 2
+	}
+
+	return proof, nil
+}
+
+//recompute the root from leaf and the branch in proof
+func (p *MerkleProof) ComputeRoot(leaf Uint256) Uint256 {
+	hash := leaf
+	index := p.Index
+	for _, sibling := range p.Branch {
+		if index%2 == 0 {
+			hash = DOUBLE_SHA256([]Uint256{hash, sibling})
+		} else {
+			hash = DOUBLE_SHA256([]Uint256{sibling, hash})
+		}
+		index /= 2
+	}
+	return hash
+}
+
+//check that leaf is included in the tree with the given root
+func VerifyMerkleProof(leaf Uint256, proof *MerkleProof, root Uint256) bool {
+	if proof == nil || len(proof.Branch) < 32 && proof.Index>>uint(len(proof.Branch)) != 0 {
+		return false
+	}
+	return proof.ComputeRoot(leaf) == root
+}
@@ -24,3 +24,29 @@ func TestHash(t *testing.T) {
 	fmt.Printf("[Root Hash]:%x\n", x)
 
 }
+
+func TestMerkleProof(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		var data []Uint256
+		for i := 0; i < n; i++ {
+			data = append(data, Uint256(sha256.Sum256([]byte{byte(i)})))
+		}
+		root, _ := ComputeRoot(data)
+		for i := 0; i < n; i++ {
+			proof, err := NewMerkleProof(data, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !VerifyMerkleProof(data[i], proof, root) {
+				t.Errorf("proof of leaf %d in %d leaves not verified", i, n)
+			}
+			if n > 1 && VerifyMerkleProof(data[(i+1)%n], proof, root) {
+				t.Errorf("proof of leaf %d in %d leaves verified another leaf", i, n)
+			}
+		}
+	}
+
+	if _, err := NewMerkleProof([]Uint256{{}}, 1); err == nil {
+		t.Error("index out of range accepted")
+	}
+}
@@ -0,0 +1,91 @@
+package crypto
+
+import (
+	. "IPT/common"
+	"crypto/sha256"
+)
+
+// The ledger state is committed in a sparse Merkle tree. A key sits on the
+// path given by the bits of StateKeyHash(key), a subtree holding one key is
+// replaced by its leaf and an empty subtree hashes to the zero Uint256.
+
+const (
+	stateLeafTag byte = 0x00
+	stateNodeTag byte = 0x01
+)
+
+func StateKeyHash(key []byte) Uint256 {
+	return Uint256(sha256.Sum256(key))
+}
+
+func StateValueHash(value []byte) Uint256 {
+	return Uint256(sha256.Sum256(value))
+}
+
+func StateLeafHash(keyHash Uint256, valueHash Uint256) Uint256 {
+	data := make([]byte, 0, 1+2*UINT256SIZE)
+	data = append(data, stateLeafTag)
+	data = append(data, keyHash.ToArray()...)
+	data = append(data, valueHash.ToArray()...)
+	return Uint256(sha256.Sum256(data))
+}
+
+func StateNodeHash(left Uint256, right Uint256) Uint256 {
+	data := make([]byte, 0, 1+2*UINT256SIZE)
+	data = append(data, stateNodeTag)
+	data = append(data, left.ToArray()...)
+	data = append(data, right.ToArray()...)
+	return Uint256(sha256.Sum256(data))
+}
+
+// the bit of hash at depth, 0 means the left child
+func StatePathBit(hash Uint256, depth int) byte {
+	return (hash[depth/8] >> (7 - uint(depth%8))) & 1
+}
+
+// StateProof proves the value of one key against a state root. Siblings are
+// the hashes next to the path from the root down. The path ends at a leaf,
+// given by LeafKey and LeafValue, or at an empty subtree when both are zero.
+type StateProof struct {
+	Siblings  []Uint256
+	LeafKey   Uint256
+	LeafValue Uint256
+}
+
+// check the proof for key, a nil value checks that key is absent
+func (p *StateProof) Verify(key []byte, value []byte, root Uint256) bool {
+	if len(p.Siblings) > 8*UINT256SIZE {
+		return false
+	}
+	keyHash := StateKeyHash(key)
+	empty := p.LeafKey == Uint256{} && p.LeafValue == Uint256{}
+
+	var hash Uint256
+	if value != nil {
+		if empty || p.LeafKey != keyHash || p.LeafValue != StateValueHash(value) {
+			return false
+		}
+		hash = StateLeafHash(p.LeafKey, p.LeafValue)
+	} else if !empty {
+		// another key holds the place where key would be
+		if p.LeafKey == keyHash {
+			return false
+		}
+		for i := range p.Siblings {
+			if StatePathBit(p.LeafKey, i) != StatePathBit(keyHash, i) {
+				return false
+			}
+		}
+		hash = StateLeafHash(p.LeafKey, p.LeafValue)
+	}
+
+	for i := len(p.Siblings) - 1; i >= 0; i-- {
+		if StatePathBit(keyHash, i) == 0 {
+			hash = StateNodeHash(hash, p.Siblings[i])
+		} else {
+			hash = StateNodeHash(p.Siblings[i], hash)
+		}
+	}
+
+	return hash == root
+}
@@ -7,15 +7,20 @@ import (
 	_ "IPT/cmd"
 	"IPT/cmd/asset"
 	"IPT/cmd/bookkeeper"
+	"IPT/cmd/chain"
 	. "IPT/cmd/common"
 	"IPT/cmd/consensus"
 	"IPT/cmd/contract"
 	"IPT/cmd/data"
+	"IPT/cmd/db"
 	"IPT/cmd/debug"
 	"IPT/cmd/info"
 	"IPT/cmd/multisig"
 	"IPT/cmd/priv"
 	"IPT/cmd/recover"
+	"IPT/cmd/snapshot"
+	"IPT/cmd/swap"
+	"IPT/cmd/trade"
 	"IPT/cmd/wallet"
 
 	"github.com/urfave/cli"
@@ -36,7 +41,9 @@ func main() {
 	app.Flags = []cli.Flag{
 		NewIpFlag(),
 		NewPortFlag(),
+		NewSigningHeightFlag(),
 	}
+	app.Before = SetSigningHeight
 	//commands
 	app.Commands = []cli.Command{
 		*consensus.NewCommand(),
@@ -51,6 +58,11 @@ func main() {
 		*recover.NewCommand(),
 		*multisig.NewCommand(),
 		*contract.NewCommand(),
+		*chain.NewCommand(),
+		*snapshot.NewCommand(),
+		*swap.NewCommand(),
+		*trade.NewCommand(),
+		*db.NewCommand(),
 	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 	sort.Sort(cli.FlagsByName(app.Flags))
@@ -2,18 +2,23 @@ package main
 
 import (
 	"os"
+	"os/signal"
 	"runtime"
+	"syscall"
 	"time"
 
 	"IPT/account"
 	"IPT/common/config"
 	"IPT/common/log"
+	"IPT/consensus"
 	"IPT/consensus/ebft"
 	"IPT/core/ledger"
+	"IPT/core/signature"
 	"IPT/core/store/ChainStore"
 	"IPT/core/transaction"
 	"IPT/crypto"
 	net "IPT/msg"
+	"IPT/msg/node"
 	"IPT/msg/nodeinfo"
 	"IPT/msg/protocol"
 	"IPT/msg/restful"
@@ -44,6 +49,8 @@ func main() {
 	var blockChain *ledger.Blockchain
 	var err error
 	var noder protocol.Noder
+	var shutdown chan os.Signal
+	var saveTxnPool *time.Ticker
 	log.Trace("Node version: ", config.Version)
 
 	if len(config.Parameters.BookKeepers) < account.DefaultBookKeeperCount {
@@ -61,6 +68,9 @@ func main() {
 	}
 	ledger.DefaultLedger.Store.InitLedgerStore(ledger.DefaultLedger)
 	transaction.TxStore = ledger.DefaultLedger.Store
+	signature.SigningHeight = func() uint32 {
+		return ledger.DefaultLedger.Store.GetHeight() + 1
+	}
 	crypto.SetAlg(config.Parameters.EncryptAlg)
 
 	log.Info("1. BlockChain init")
@@ -98,13 +108,24 @@ func main() {
 
 	log.Info("3. Start the P2P networks")
 	noder = net.StartProtocol(acct.PublicKey)
+	transaction.UnconfirmedTx = noder.GetTransaction
 	rpc.RegistRpcNode(noder)
 	time.Sleep(10 * time.Second)
 	noder.SyncNodeHeight()
 	noder.WaitForFourPeersStart()
 	noder.WaitForSyncBlkFinish()
+	// the transactions pending at the last shutdown
+	if count, err := noder.LoadTxnPool(node.TxnPoolFile()); err != nil {
+		log.Warn("Load the transaction pool failed: ", err)
+	} else {
+		log.Info("Loaded ", count, " transactions into the transaction pool")
+	}
 	if protocol.VERIFYNODENAME == config.Parameters.NodeType {
 		log.Info("4. Start ebft Services")
+		if err := consensus.InitPolicy(); err != nil {
+			log.Fatal("Load the consensus policy failed: ", err)
+			goto ERROR
+		}
 		ebftServices := ebft.NewebftService(client, "logebft", noder)
 		rpc.RegistebftService(ebftServices)
 		go ebftServices.Start()
@@ -119,13 +140,29 @@ func main() {
 		go nodeinfo.StartServer(noder)
 	}
 
+	shutdown = make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+	saveTxnPool = time.NewTicker(time.Duration(node.TxnPoolSaveInterval()) * time.Second)
 	for {
-		time.Sleep(ebft.GenBlockTime)
-		log.Trace("BlockHeight = ", ledger.DefaultLedger.Blockchain.BlockHeight)
-		isNeedNewFile := log.CheckIfNeedNewFile()
-		if isNeedNewFile == true {
-			log.ClosePrintLog()
-			log.Init(log.Path, os.Stdout)
+		select {
+		case <-time.After(ebft.GenBlockTime):
+			log.Trace("BlockHeight = ", ledger.DefaultLedger.Blockchain.BlockHeight)
+			isNeedNewFile := log.CheckIfNeedNewFile()
+			if isNeedNewFile == true {
+				log.ClosePrintLog()
+				log.Init(log.Path, os.Stdout)
+			}
+		case <-saveTxnPool.C:
+			if err := noder.SaveTxnPool(node.TxnPoolFile()); err != nil {
+				log.Warn("Save the transaction pool failed: ", err)
+			}
+		case <-shutdown:
+			log.Info("Shutting down, save the transaction pool")
+			if err := noder.SaveTxnPool(node.TxnPoolFile()); err != nil {
+				log.Warn("Save the transaction pool failed: ", err)
+			}
+			ledger.DefaultLedger.Store.Close()
+			os.Exit(0)
 		}
 	}
 
@@ -13,6 +13,9 @@ import (
 
 type Neter interface {
 	GetTxnPool(byCount bool) map[Uint256]*transaction.Transaction
+	GetTxnsByFeeRate(count int) []*transaction.Transaction
+	GetTxnsForBlock(count int) []*transaction.Transaction
+	GetBalanceNonce(programHash Uint160) (uint64, error)
 	Xmit(interface{}) error
 	GetEvent(eventName string) *events.Event
 	GetBookKeepersAddrs() ([]*crypto.PubKey, uint64)
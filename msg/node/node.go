@@ -3,6 +3,7 @@ package node
 import (
 	. "IPT/common"
 	. "IPT/common/config"
+	. "IPT/common/errors"
 	"IPT/common/log"
 	"IPT/core/ledger"
 	"IPT/core/transaction"
@@ -563,6 +564,30 @@ func (node *node) Relay(frmnode Noder, message interface{}) error {
 	return nil
 }
 
+// AppendTxnPool appends txn to the transaction pool and relays the orphan
+// transactions it completes.
+func (node *node) AppendTxnPool(txn *transaction.Transaction, poolVerify bool) ErrCode {
+	errCode := node.TXNPool.AppendTxnPool(txn, poolVerify)
+	if errCode == ErrNoError {
+		node.relayOrphans()
+	}
+	return errCode
+}
+
+// CleanSubmittedTransactions cleans the transaction pool with a persisted
+// block and relays the orphan transactions the block completes.
+func (node *node) CleanSubmittedTransactions(block *ledger.Block) error {
+	err := node.TXNPool.CleanSubmittedTransactions(block)
+	node.relayOrphans()
+	return err
+}
+
+func (node *node) relayOrphans() {
+	for _, txn := range node.TXNPool.adoptOrphans() {
+		node.Relay(node, txn)
+	}
+}
+
 func (node *node) CacheHash(hash Uint256) {
 	node.cachelock.Lock()
 	defer node.cachelock.Unlock()
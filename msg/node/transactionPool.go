@@ -11,7 +11,15 @@ import (
 	. "IPT/common/errors"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
+)
+
+const (
+	// defaults for the orphan transactions when not configured
+	DefaultMaxOrphanTxns   = 100
+	DefaultOrphanTxTimeout = 600 // Seconds
 )
 
 type TXNPool struct {
@@ -21,6 +29,16 @@ type TXNPool struct {
 	issueSummary  map[common.Uint256]common.Fixed64           // transaction which pass the verify will summary the amout to this map
 	inputUTXOList map[string]*transaction.Transaction         // transaction which pass the verify will add the UTXO to this map
 	lockAssetList map[string]struct{}                         // keep only one copy for each program hash and asset ID pair
+	txnBytes      int                                         // total size of the transactions in txnList
+	txnSenders    map[common.Uint256][]common.Uint160         // program hashes signing each transaction in txnList
+	senderCount   map[common.Uint160]int                      // number of transactions in txnList signed by each program hash
+	orphans       map[common.Uint256]*orphanTxn               // transactions waiting for the transactions they spend from
+	admission     sync.Mutex                                  // serializes the transactions entering txnList, from the limit checks to the insert
+}
+
+type orphanTxn struct {
+	txn     *transaction.Transaction
+	expires time.Time
 }
 
 func (this *TXNPool) init() {
@@ -31,65 +49,423 @@ func (this *TXNPool) init() {
 	this.issueSummary = make(map[common.Uint256]common.Fixed64)
 	this.txnList = make(map[common.Uint256]*transaction.Transaction)
 	this.lockAssetList = make(map[string]struct{})
+	this.txnBytes = 0
+	this.txnSenders = make(map[common.Uint256][]common.Uint160)
+	this.senderCount = make(map[common.Uint160]int)
+	this.orphans = make(map[common.Uint256]*orphanTxn)
 }
 
 //append transaction to txnpool when check ok.
 //1.check transaction. 2.check with ledger(db) 3.check with pool
 func (this *TXNPool) AppendTxnPool(txn *transaction.Transaction, poolVerify bool) ErrCode {
+	if poolVerify {
+		// hold the transaction until the transactions it spends arrive
+		if missing := this.missingParents(txn); len(missing) > 0 {
+			log.Info(fmt.Sprintf("Transaction %x waits for %d transactions, held as orphan", txn.Hash(), len(missing)))
+			this.addOrphan(txn)
+			return ErrOrphanTransaction
+		}
+	}
 	//verify transaction with Concurrency
-	if errCode := va.VerifyTransaction(txn); errCode != ErrNoError {
+	height := ledger.DefaultLedger.Store.GetHeight() + 1
+	if errCode := va.VerifyTransaction(txn, height); errCode != ErrNoError {
 		log.Info("Transaction verification failed", txn.Hash())
 		return errCode
 	}
+	if poolVerify {
+		// the fee policy does not apply to transactions proposed for a block
+		if err := va.CheckTransactionFee(txn, height); err != nil {
+			log.Info("Transaction fee check failed", txn.Hash(), err)
+			return ErrInsufficientFee
+		}
+	}
 	if errCode := va.VerifyTransactionWithLedger(txn, ledger.DefaultLedger); errCode != ErrNoError {
 		log.Info("Transaction verification with ledger failed", txn.Hash())
 		return errCode
 	}
+	// the limits and the pool checks hold until the transaction is added
+	this.admission.Lock()
+	defer this.admission.Unlock()
+	var evicted []*transaction.Transaction
 	if poolVerify {
+		if err := this.checkSenderQuota(txn); err != nil {
+			log.Info("Transaction sender quota check failed", txn.Hash(), err)
+			return ErrSenderQuota
+		}
+		var err error
+		if evicted, err = this.evictionsFor(txn); err != nil {
+			log.Info("Transaction rejected by the full pool", txn.Hash(), err)
+			return ErrTxPoolFull
+		}
 		//verify transaction by pool with lock
 		if errCode := this.verifyTransactionWithTxnPool(txn); errCode != ErrNoError {
 			log.Info("Transaction verification with transaction pool failed", txn.Hash())
 			return errCode
 		}
 	}
+	for _, t := range evicted {
+		log.Info(fmt.Sprintf("Transaction %x evicted by %x, removed from TxPool", t.Hash(), txn.Hash()))
+		this.removeTransaction(t)
+		this.cleanLockedAssetList([]*transaction.Transaction{t})
+	}
 
 	//add the transaction to process scope
 	this.addtxnList(txn)
 	return ErrNoError
 }
 
-//get the transaction in txnpool
-func (this *TXNPool) GetTxnPool(byCount bool) map[common.Uint256]*transaction.Transaction {
+// check that no program hash signing txn has its quota of transactions in
+// the pool already
+func (this *TXNPool) checkSenderQuota(txn *transaction.Transaction) error {
+	quota := config.Parameters.MaxPoolTxPerSender
+	if quota <= 0 {
+		return nil
+	}
+	senders, err := txn.GetProgramHashes()
+	if err != nil {
+		return err
+	}
 	this.RLock()
-	count := config.Parameters.MaxTxInBlock
-	if count <= 0 {
-		byCount = false
-	}
-	if len(this.txnList) < count || !byCount {
-		count = len(this.txnList)
-	}
-	var num int
-	txnMap := make(map[common.Uint256]*transaction.Transaction, count)
-	for txnId, tx := range this.txnList {
-		txnMap[txnId] = tx
-		num++
-		if num >= count {
-			break
+	defer this.RUnlock()
+	for _, sender := range senders {
+		if this.senderCount[sender] >= quota {
+			return errors.New(fmt.Sprintf("%x has %d transactions in the pool", sender, quota))
 		}
 	}
+	return nil
+}
+
+// get the transactions to evict so that txn fits into the pool limits, those
+// with the lowest fee rate first. A transaction is evicted with the ones
+// spending from it, and only if all of them pay a lower fee rate than txn. An
+// error is returned if they do not free enough room.
+func (this *TXNPool) evictionsFor(txn *transaction.Transaction) ([]*transaction.Transaction, error) {
+	maxCount := config.Parameters.MaxPoolTransactions
+	maxBytes := config.Parameters.MaxPoolBytes
+	if maxCount <= 0 && maxBytes <= 0 {
+		return nil, nil
+	}
+	this.RLock()
+	count := len(this.txnList) + 1
+	size := this.txnBytes + len(txn.ToArray())
 	this.RUnlock()
+	fits := func() bool {
+		return (maxCount <= 0 || count <= maxCount) && (maxBytes <= 0 || size <= maxBytes)
+	}
+
+	evicted := []*transaction.Transaction{}
+	removed := make(map[common.Uint256]bool)
+	rate := txn.FeeRate()
+	pool := this.GetTxnsByFeeRate(0)
+	ancestors := this.ancestors(txn)
+	for i := len(pool) - 1; i >= 0 && !fits(); i-- {
+		if pool[i].FeeRate() >= rate {
+			break
+		}
+		if removed[pool[i].Hash()] || ancestors[pool[i].Hash()] {
+			continue
+		}
+		descendants := this.descendants(pool[i])
+		paysMore := false
+		for _, t := range descendants {
+			if t.FeeRate() >= rate {
+				paysMore = true
+				break
+			}
+		}
+		if paysMore {
+			continue
+		}
+		evicted = append(evicted, pool[i])
+		for _, t := range append(descendants, pool[i]) {
+			if !removed[t.Hash()] {
+				removed[t.Hash()] = true
+				count--
+				size -= len(t.ToArray())
+			}
+		}
+	}
+	if !fits() {
+		return nil, errors.New(fmt.Sprintf("%d transactions of %d bytes in the pool", count, size))
+	}
+	return evicted, nil
+}
+
+//get the transaction in txnpool, with byCount the MaxTxInBlock transactions
+//with the highest fee rate
+func (this *TXNPool) GetTxnPool(byCount bool) map[common.Uint256]*transaction.Transaction {
+	if !byCount || config.Parameters.MaxTxInBlock <= 0 {
+		this.RLock()
+		defer this.RUnlock()
+		txnMap := make(map[common.Uint256]*transaction.Transaction, len(this.txnList))
+		for txnId, tx := range this.txnList {
+			txnMap[txnId] = tx
+		}
+		return txnMap
+	}
+	txns := this.GetTxnsByFeeRate(config.Parameters.MaxTxInBlock)
+	txnMap := make(map[common.Uint256]*transaction.Transaction, len(txns))
+	for _, tx := range txns {
+		txnMap[tx.Hash()] = tx
+	}
 	return txnMap
 }
 
+//get at most count transactions in txnpool ordered by fee rate, highest first,
+//all of them when count is not positive
+func (this *TXNPool) GetTxnsByFeeRate(count int) []*transaction.Transaction {
+	this.RLock()
+	txns := make([]*transaction.Transaction, 0, len(this.txnList))
+	for _, tx := range this.txnList {
+		txns = append(txns, tx)
+	}
+	this.RUnlock()
+
+	sorted := make(txnsByFeeRate, len(txns))
+	for i, tx := range txns {
+		sorted[i] = feeRateItem{tx, tx.Hash(), tx.FeeRate()}
+	}
+	sort.Sort(sorted)
+	for i, item := range sorted {
+		txns[i] = item.txn
+	}
+	if count > 0 && len(txns) > count {
+		txns = txns[:count]
+	}
+	return txns
+}
+
+// get at most count transactions in txnpool for a block, by fee rate as
+// GetTxnsByFeeRate but each after the transactions in txnpool it spends from
+// and after those spending the same account balances with lower nonces
+func (this *TXNPool) GetTxnsForBlock(count int) []*transaction.Transaction {
+	pending := this.GetTxnsByFeeRate(0)
+	queues := newNonceQueues(pending)
+	included := make(map[common.Uint256]bool, len(pending))
+	txns := make([]*transaction.Transaction, 0, len(pending))
+	for progress := true; progress && len(pending) > 0; {
+		progress = false
+		waiting := pending[:0]
+		for _, txn := range pending {
+			if count > 0 && len(txns) >= count {
+				return txns
+			}
+			if !this.parentsIncluded(txn, included) || !queues.ready(txn) {
+				waiting = append(waiting, txn)
+				continue
+			}
+			txns = append(txns, txn)
+			included[txn.Hash()] = true
+			queues.pop(txn)
+			progress = true
+		}
+		pending = waiting
+	}
+	return txns
+}
+
+// nonceQueues holds the transactions spending the balance of each account,
+// lowest nonce first
+type nonceQueues map[common.Uint160][]*transaction.Transaction
+
+func newNonceQueues(txns []*transaction.Transaction) nonceQueues {
+	queues := make(nonceQueues)
+	for _, txn := range txns {
+		for programHash := range balanceNonces(txn) {
+			queues[programHash] = append(queues[programHash], txn)
+		}
+	}
+	for programHash, queue := range queues {
+		sort.SliceStable(queue, func(i, j int) bool {
+			return balanceNonces(queue[i])[programHash] < balanceNonces(queue[j])[programHash]
+		})
+	}
+	return queues
+}
+
+// ready reports whether txn has the lowest nonce of each account it spends
+func (q nonceQueues) ready(txn *transaction.Transaction) bool {
+	for programHash := range balanceNonces(txn) {
+		if q[programHash][0] != txn {
+			return false
+		}
+	}
+	return true
+}
+
+func (q nonceQueues) pop(txn *transaction.Transaction) {
+	for programHash := range balanceNonces(txn) {
+		q[programHash] = q[programHash][1:]
+	}
+}
+
+// balanceNonces returns the nonce txn spends the balance of each account with
+func balanceNonces(txn *transaction.Transaction) map[common.Uint160]uint64 {
+	nonces := make(map[common.Uint160]uint64, len(txn.BalanceInputs))
+	for _, input := range txn.BalanceInputs {
+		nonces[input.ProgramHash] = input.Nonce
+	}
+	return nonces
+}
+
+func (this *TXNPool) parentsIncluded(txn *transaction.Transaction, included map[common.Uint256]bool) bool {
+	for _, input := range txn.UTXOInputs {
+		if !included[input.ReferTxID] && this.GetTransaction(input.ReferTxID) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// get the transactions in txnpool txn spends from, directly or through others
+func (this *TXNPool) ancestors(txn *transaction.Transaction) map[common.Uint256]bool {
+	found := make(map[common.Uint256]bool)
+	queue := []*transaction.Transaction{txn}
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		for _, input := range t.UTXOInputs {
+			if found[input.ReferTxID] {
+				continue
+			}
+			if parent := this.GetTransaction(input.ReferTxID); parent != nil {
+				found[input.ReferTxID] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return found
+}
+
+// get the transactions in txnpool spending from txn, directly or through
+// others
+func (this *TXNPool) descendants(txn *transaction.Transaction) []*transaction.Transaction {
+	found := make(map[common.Uint256]bool)
+	descendants := []*transaction.Transaction{}
+	queue := []*transaction.Transaction{txn}
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		for _, child := range this.children(t.Hash()) {
+			if !found[child.Hash()] {
+				found[child.Hash()] = true
+				descendants = append(descendants, child)
+				queue = append(queue, child)
+			}
+		}
+	}
+	return descendants
+}
+
+// get the transactions in txnpool spending outputs of the transaction
+func (this *TXNPool) children(hash common.Uint256) []*transaction.Transaction {
+	this.RLock()
+	defer this.RUnlock()
+	txns := []*transaction.Transaction{}
+	for _, txn := range this.txnList {
+		for _, input := range txn.UTXOInputs {
+			if input.ReferTxID == hash {
+				txns = append(txns, txn)
+				break
+			}
+		}
+	}
+	return txns
+}
+
+type feeRateItem struct {
+	txn  *transaction.Transaction
+	hash common.Uint256
+	rate common.Fixed64
+}
+
+// txnsByFeeRate orders by fee rate, highest first, and then by hash
+type txnsByFeeRate []feeRateItem
+
+func (s txnsByFeeRate) Len() int      { return len(s) }
+func (s txnsByFeeRate) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s txnsByFeeRate) Less(i, j int) bool {
+	if s[i].rate != s[j].rate {
+		return s[i].rate > s[j].rate
+	}
+	return s[i].hash.CompareTo(s[j].hash) < 0
+}
+
 //clean the trasaction Pool with committed block.
 func (this *TXNPool) CleanSubmittedTransactions(block *ledger.Block) error {
 	this.cleanTransactionList(block.Transactions)
 	this.cleanUTXOList(block.Transactions)
 	this.cleanLockedAssetList(block.Transactions)
 	this.cleanIssueSummary(block.Transactions)
+	this.cleanExpiredTransactions(block.Blockdata.Height + 1)
+	this.cleanUsedNonces()
+	this.cleanFrozenTransactions(block)
 	return nil
 }
 
+//remove the transactions which can not be included in the block at height
+func (this *TXNPool) cleanExpiredTransactions(height uint32) {
+	for _, txn := range this.GetTxnPool(false) {
+		if !txn.IsExpired(height) || this.GetTransaction(txn.Hash()) == nil {
+			continue
+		}
+		log.Info(fmt.Sprintf("Transaction %x expired, removed from TxPool", txn.Hash()))
+		this.removeTransaction(txn)
+		this.cleanLockedAssetList([]*transaction.Transaction{txn})
+	}
+}
+
+// remove the transactions spending account balances with nonces the ledger
+// has moved past
+func (this *TXNPool) cleanUsedNonces() {
+	next := make(map[common.Uint160]uint64)
+	for _, txn := range this.GetTxnPool(false) {
+		for _, input := range txn.BalanceInputs {
+			nonce, ok := next[input.ProgramHash]
+			if !ok {
+				var err error
+				if nonce, err = ledger.DefaultLedger.Store.GetAccountNonce(input.ProgramHash); err != nil {
+					log.Warn("GetAccountNonce failed", err)
+					continue
+				}
+				next[input.ProgramHash] = nonce
+			}
+			if input.Nonce >= nonce || this.GetTransaction(txn.Hash()) == nil {
+				continue
+			}
+			log.Info(fmt.Sprintf("Transaction %x uses nonce %d of account %x, removed from TxPool", txn.Hash(), input.Nonce, input.ProgramHash))
+			this.removeTransaction(txn)
+			this.cleanLockedAssetList([]*transaction.Transaction{txn})
+			break
+		}
+	}
+}
+
+//get the freeze transactions in txnpool
+func (this *TXNPool) pendingFreezes() []*transaction.Transaction {
+	this.RLock()
+	defer this.RUnlock()
+	txns := []*transaction.Transaction{}
+	for _, txn := range this.txnList {
+		if txn.TxType == transaction.FreezeAsset {
+			txns = append(txns, txn)
+		}
+	}
+	return txns
+}
+
+//remove the transactions spending or receiving assets frozen by the block
+func (this *TXNPool) cleanFrozenTransactions(block *ledger.Block) {
+	for _, txn := range this.GetTxnPool(false) {
+		if err := va.CheckFrozenAssetWithTxns(txn, block.Transactions); err == nil || this.GetTransaction(txn.Hash()) == nil {
+			continue
+		}
+		log.Info(fmt.Sprintf("Transaction %x uses a frozen asset, removed from TxPool", txn.Hash()))
+		this.removeTransaction(txn)
+		this.cleanLockedAssetList([]*transaction.Transaction{txn})
+	}
+}
+
 //get the transaction by hash
 func (this *TXNPool) GetTransaction(hash common.Uint256) *transaction.Transaction {
 	this.RLock()
@@ -99,7 +475,18 @@ func (this *TXNPool) GetTransaction(hash common.Uint256) *transaction.Transactio
 
 //verify transaction with txnpool
 func (this *TXNPool) verifyTransactionWithTxnPool(txn *transaction.Transaction) ErrCode {
-	// check if the transaction includes double spent UTXO inputs
+	// check the balance inputs together with those in the pool
+	if err := this.checkBalanceInputs(txn); err != nil {
+		log.Info(err)
+		return ErrBalanceInput
+	}
+	// check if a freeze in the pool covers the transaction
+	if err := va.CheckFrozenAssetWithTxns(txn, this.pendingFreezes()); err != nil {
+		log.Info(err)
+		return ErrFrozenAsset
+	}
+	// check if the transaction includes double spent UTXO inputs, after the
+	// checks above so that a rejected transaction does not hold its inputs
 	if err := this.apendToUTXOPool(txn); err != nil {
 		log.Info(err)
 		return ErrDoubleSpend
@@ -132,8 +519,45 @@ func (this *TXNPool) checkDuplicateLockAsset(txn *transaction.Transaction) error
 	return nil
 }
 
+func (this *TXNPool) checkBalanceInputs(txn *transaction.Transaction) error {
+	if len(txn.BalanceInputs) == 0 {
+		return nil
+	}
+	txns := []*transaction.Transaction{txn}
+	for _, t := range this.GetTxnPool(false) {
+		if len(t.BalanceInputs) > 0 {
+			txns = append(txns, t)
+		}
+	}
+	return va.CheckBalanceInputsWithLedger(txns, ledger.DefaultLedger)
+}
+
+//get the nonce for the next balance input of the account, past the ledger
+//and the transactions in the pool
+func (this *TXNPool) GetBalanceNonce(programHash common.Uint160) (uint64, error) {
+	nonce, err := ledger.DefaultLedger.Store.GetAccountNonce(programHash)
+	if err != nil {
+		return 0, err
+	}
+	for _, txn := range this.GetTxnPool(false) {
+		for _, input := range txn.BalanceInputs {
+			if input.ProgramHash == programHash && input.Nonce >= nonce {
+				nonce = input.Nonce + 1
+			}
+		}
+	}
+	return nonce, nil
+}
+
 //remove from associated map
 func (this *TXNPool) removeTransaction(txn *transaction.Transaction) {
+	//0.remove the transactions spending its outputs, which need it to
+	//find their references
+	for _, child := range this.children(txn.Hash()) {
+		log.Info(fmt.Sprintf("Transaction %x spends removed %x, removed from TxPool", child.Hash(), txn.Hash()))
+		this.removeTransaction(child)
+		this.cleanLockedAssetList([]*transaction.Transaction{child})
+	}
 	//1.remove from txnList
 	this.deltxnList(txn)
 	//2.remove from UTXO list map
@@ -260,6 +684,7 @@ func (this *TXNPool) cleanTransactionList(txns []*transaction.Transaction) error
 }
 
 func (this *TXNPool) addtxnList(txn *transaction.Transaction) bool {
+	senders, _ := txn.GetProgramHashes()
 	this.Lock()
 	defer this.Unlock()
 	txnHash := txn.Hash()
@@ -267,6 +692,11 @@ func (this *TXNPool) addtxnList(txn *transaction.Transaction) bool {
 		return false
 	}
 	this.txnList[txnHash] = txn
+	this.txnBytes += len(txn.ToArray())
+	this.txnSenders[txnHash] = senders
+	for _, sender := range senders {
+		this.senderCount[sender]++
+	}
 	return true
 }
 
@@ -278,6 +708,13 @@ func (this *TXNPool) deltxnList(tx *transaction.Transaction) bool {
 		return false
 	}
 	delete(this.txnList, tx.Hash())
+	this.txnBytes -= len(tx.ToArray())
+	for _, sender := range this.txnSenders[txHash] {
+		if this.senderCount[sender]--; this.senderCount[sender] <= 0 {
+			delete(this.senderCount, sender)
+		}
+	}
+	delete(this.txnSenders, txHash)
 	return true
 }
 
@@ -364,3 +801,101 @@ func (this *TXNPool) getAssetIssueAmount(assetId common.Uint256) common.Fixed64
 	defer this.RUnlock()
 	return this.issueSummary[assetId]
 }
+
+// get the hashes of the transactions txn spends from which are neither in the
+// ledger nor in txnpool
+func (this *TXNPool) missingParents(txn *transaction.Transaction) []common.Uint256 {
+	missing := []common.Uint256{}
+	for _, input := range txn.UTXOInputs {
+		hash := input.ReferTxID
+		if ledger.DefaultLedger.Store.IsTxHashDuplicate(hash) || this.GetTransaction(hash) != nil {
+			continue
+		}
+		known := false
+		for _, h := range missing {
+			if h == hash {
+				known = true
+				break
+			}
+		}
+		if !known {
+			missing = append(missing, hash)
+		}
+	}
+	return missing
+}
+
+// hold an orphan transaction, replacing the one expiring first when full
+func (this *TXNPool) addOrphan(txn *transaction.Transaction) {
+	maxOrphans := config.Parameters.MaxOrphanTxns
+	if maxOrphans <= 0 {
+		maxOrphans = DefaultMaxOrphanTxns
+	}
+	timeout := config.Parameters.OrphanTxTimeout
+	if timeout == 0 {
+		timeout = DefaultOrphanTxTimeout
+	}
+	this.Lock()
+	defer this.Unlock()
+	hash := txn.Hash()
+	if _, ok := this.orphans[hash]; ok {
+		return
+	}
+	for len(this.orphans) >= maxOrphans {
+		var first common.Uint256
+		var expires time.Time
+		for h, orphan := range this.orphans {
+			if expires.IsZero() || orphan.expires.Before(expires) {
+				first, expires = h, orphan.expires
+			}
+		}
+		log.Info(fmt.Sprintf("Orphan transaction %x replaced by %x", first, hash))
+		delete(this.orphans, first)
+	}
+	this.orphans[hash] = &orphanTxn{txn, time.Now().Add(time.Duration(timeout) * time.Second)}
+}
+
+// get the orphan transactions, dropping the expired ones
+func (this *TXNPool) getOrphans() []*transaction.Transaction {
+	this.Lock()
+	defer this.Unlock()
+	now := time.Now()
+	txns := []*transaction.Transaction{}
+	for hash, orphan := range this.orphans {
+		if now.After(orphan.expires) {
+			log.Info(fmt.Sprintf("Orphan transaction %x expired", hash))
+			delete(this.orphans, hash)
+			continue
+		}
+		txns = append(txns, orphan.txn)
+	}
+	return txns
+}
+
+func (this *TXNPool) delOrphan(hash common.Uint256) {
+	this.Lock()
+	defer this.Unlock()
+	delete(this.orphans, hash)
+}
+
+// append the orphan transactions whose parents all arrived to txnpool and
+// return those accepted
+func (this *TXNPool) adoptOrphans() []*transaction.Transaction {
+	adopted := []*transaction.Transaction{}
+	for found := true; found; {
+		found = false
+		for _, txn := range this.getOrphans() {
+			if len(this.missingParents(txn)) > 0 {
+				continue
+			}
+			found = true
+			this.delOrphan(txn.Hash())
+			if errCode := this.AppendTxnPool(txn, true); errCode != ErrNoError {
+				log.Info(fmt.Sprintf("Orphan transaction %x rejected: %s", txn.Hash(), errCode.Error()))
+				continue
+			}
+			adopted = append(adopted, txn)
+		}
+	}
+	return adopted
+}
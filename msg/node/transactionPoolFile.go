@@ -0,0 +1,106 @@
+package node
+
+import (
+	"IPT/common/config"
+	. "IPT/common/errors"
+	"IPT/common/log"
+	"IPT/common/serialization"
+	"IPT/core/transaction"
+	"bufio"
+	"fmt"
+	"os"
+)
+
+const (
+	// defaults for keeping the transaction pool when not configured
+	DefaultTxnPoolFile         = "./TxnPool.dat"
+	DefaultTxnPoolSaveInterval = 60 // Seconds
+)
+
+// TxnPoolFile returns the file keeping the transaction pool across restarts.
+func TxnPoolFile() string {
+	if file := config.Parameters.TxnPoolFile; file != "" {
+		return file
+	}
+	return DefaultTxnPoolFile
+}
+
+// TxnPoolSaveInterval returns how many seconds pass between saves of the
+// transaction pool.
+func TxnPoolSaveInterval() uint {
+	if interval := config.Parameters.TxnPoolSaveInterval; interval > 0 {
+		return interval
+	}
+	return DefaultTxnPoolSaveInterval
+}
+
+// SaveTxnPool writes the transactions in the pool to file, each after the
+// pool transactions it spends from. The file is replaced at once so that a
+// crash while saving keeps the previous one.
+func (this *TXNPool) SaveTxnPool(file string) error {
+	txns := this.GetTxnsForBlock(0)
+
+	tmp := file + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = serialization.WriteVarUint(w, uint64(len(txns)))
+	for i := 0; err == nil && i < len(txns); i++ {
+		err = txns[i].Serialize(w)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return err
+	}
+	log.Debug(fmt.Sprintf("Saved %d transactions of TxPool to %s", len(txns), file))
+	return nil
+}
+
+// LoadTxnPool appends the transactions saved in file to the pool. They are
+// verified again against the ledger as any new transaction, the ones still
+// valid are relayed. It returns the number of transactions appended, a
+// missing file holds none.
+func (node *node) LoadTxnPool(file string) (int, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	count, err := serialization.ReadVarUint(r, 0)
+	if err != nil {
+		return 0, err
+	}
+	appended := 0
+	for i := uint64(0); i < count; i++ {
+		txn := new(transaction.Transaction)
+		if err := txn.Deserialize(r); err != nil {
+			return appended, err
+		}
+		if errCode := node.AppendTxnPool(txn, true); errCode != ErrNoError {
+			log.Info(fmt.Sprintf("Saved transaction %x dropped: %s", txn.Hash(), errCode.Error()))
+			continue
+		}
+		node.Relay(node, txn)
+		appended++
+	}
+	return appended, nil
+}
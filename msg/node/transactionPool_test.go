@@ -0,0 +1,453 @@
+package node
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"IPT/account"
+	"IPT/common"
+	"IPT/common/config"
+	. "IPT/common/errors"
+	"IPT/core/asset"
+	"IPT/core/contract"
+	"IPT/core/contract/program"
+	"IPT/core/ledger"
+	sig "IPT/core/signature"
+	"IPT/core/transaction"
+	"IPT/core/transaction/payload"
+	"IPT/crypto"
+)
+
+// balanceSpend spends from the balance of account with nonce and declares fee.
+func balanceSpend(account common.Uint160, nonce uint64, fee common.Fixed64) *transaction.Transaction {
+	txn := &transaction.Transaction{
+		TxType:         transaction.TransferAsset,
+		PayloadVersion: payload.TransferAssetBalancePayloadVersion,
+		Payload:        &payload.TransferAsset{},
+		Attributes:     []*transaction.TxAttribute{},
+		BalanceInputs:  []*transaction.BalanceTxInput{{Value: 100, ProgramHash: account, Nonce: nonce}},
+		Outputs:        []*transaction.TxOutput{{Value: 100 - fee}},
+	}
+	txn.SetFee(fee)
+	return txn
+}
+
+func TestGetTxnsForBlockNonceOrder(t *testing.T) {
+	var pool TXNPool
+	pool.init()
+	account := common.Uint160{1}
+	// the higher the nonce the higher the fee rate
+	txns := []*transaction.Transaction{
+		balanceSpend(account, 5, 100),
+		balanceSpend(account, 6, 200),
+		balanceSpend(account, 7, 300),
+	}
+	other := balanceSpend(common.Uint160{2}, 1, 400)
+	for _, txn := range append(txns, other) {
+		pool.addtxnList(txn)
+	}
+
+	block := pool.GetTxnsForBlock(0)
+	if len(block) != 4 || block[0] != other {
+		t.Fatalf("got %d transactions, the highest fee rate first", len(block))
+	}
+	for i, txn := range txns {
+		if block[i+1] != txn {
+			t.Fatalf("transaction %d of the account out of nonce order", i)
+		}
+	}
+	if block := pool.GetTxnsForBlock(2); len(block) != 2 || block[1] != txns[0] {
+		t.Fatal("cut block skips the lowest nonce of the account")
+	}
+}
+
+// testStore is a ledger holding only the transactions registering assets
+// and the balances of balance-model assets.
+type testStore struct {
+	ledger.ILedgerStore
+	txns     map[common.Uint256]*transaction.Transaction
+	balances map[common.Uint256]common.Fixed64
+}
+
+func (s *testStore) GetHeight() uint32 { return 0 }
+
+func (s *testStore) IsTxHashDuplicate(hash common.Uint256) bool {
+	_, ok := s.txns[hash]
+	return ok
+}
+
+func (s *testStore) GetTransaction(hash common.Uint256) (*transaction.Transaction, error) {
+	if txn, ok := s.txns[hash]; ok {
+		return txn, nil
+	}
+	return nil, errors.New("transaction not found")
+}
+
+func (s *testStore) GetAsset(hash common.Uint256) (*asset.Asset, error) {
+	txn, err := s.GetTransaction(hash)
+	if err != nil {
+		return nil, err
+	}
+	return txn.Payload.(*payload.RegisterAsset).Asset, nil
+}
+
+func (s *testStore) GetQuantityIssued(assetId common.Uint256) (common.Fixed64, error) {
+	return 0, nil
+}
+
+func (s *testStore) IsDoubleSpend(txn *transaction.Transaction) bool { return false }
+
+func (s *testStore) IsBalanceAsset(assetId common.Uint256) bool {
+	_, ok := s.balances[assetId]
+	return ok
+}
+
+func (s *testStore) GetAccountNonce(programHash common.Uint160) (uint64, error) {
+	return 0, nil
+}
+
+func (s *testStore) GetAvailableAsset(programHash common.Uint160, assetid common.Uint256) (common.Fixed64, common.Fixed64, error) {
+	return s.balances[assetid], 0, nil
+}
+
+func (s *testStore) IsFrozen(assetid common.Uint256, programHash common.Uint160) (bool, error) {
+	return false, nil
+}
+
+// testPool is a transaction pool over a ledger in which signer controls
+// asset.
+type testPool struct {
+	TXNPool
+	t      *testing.T
+	store  *testStore
+	signer *account.Account
+	code   []byte
+	asset  common.Uint256
+	issued byte
+}
+
+// newTestPool also returns the function restoring the ledger.
+func newTestPool(t *testing.T) (*testPool, func()) {
+	crypto.SetAlg("P256R1")
+	signer, err := account.NewAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := contract.CreateSignatureRedeemScript(signer.PubKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	register, _ := transaction.NewRegisterAssetTransaction(
+		&asset.Asset{Name: "test", Precision: 8, RecordType: asset.UTXO},
+		-1, signer.PubKey(), signer.ProgramHash)
+	store := &testStore{
+		txns:     map[common.Uint256]*transaction.Transaction{register.Hash(): register},
+		balances: make(map[common.Uint256]common.Fixed64),
+	}
+
+	ledgerStore, txStore, unconfirmedTx := ledger.DefaultLedger, transaction.TxStore, transaction.UnconfirmedTx
+	pool := &testPool{t: t, store: store, signer: signer, code: code, asset: register.Hash()}
+	pool.init()
+	ledger.DefaultLedger = &ledger.Ledger{Store: store}
+	transaction.TxStore = store
+	transaction.UnconfirmedTx = pool.GetTransaction
+	return pool, func() {
+		ledger.DefaultLedger, transaction.TxStore, transaction.UnconfirmedTx = ledgerStore, txStore, unconfirmedTx
+	}
+}
+
+// registerBalanceAsset registers a balance-model asset of which the pool's
+// signer holds balance.
+func (p *testPool) registerBalanceAsset(balance common.Fixed64) common.Uint256 {
+	register, _ := transaction.NewRegisterAssetTransaction(
+		&asset.Asset{Name: "balance", Precision: 8, RecordType: asset.Balance},
+		-1, p.signer.PubKey(), p.signer.ProgramHash)
+	p.store.txns[register.Hash()] = register
+	p.store.balances[register.Hash()] = balance
+	return register.Hash()
+}
+
+// sign adds the signature of the pool's signer to txn.
+func (p *testPool) sign(txn *transaction.Transaction) *transaction.Transaction {
+	signature, err := sig.SignBySigner(txn, p.signer)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	builder := program.NewProgramBuilder()
+	builder.PushData(signature)
+	txn.Programs = []*program.Program{{Code: p.code, Parameter: builder.ToArray()}}
+	return txn
+}
+
+// issue issues value of the pool's asset to its signer.
+func (p *testPool) issue(value common.Fixed64) *transaction.Transaction {
+	txn, _ := transaction.NewIssueAssetTransaction([]*transaction.TxOutput{
+		{AssetID: p.asset, Value: value, ProgramHash: p.signer.ProgramHash},
+	})
+	// tell apart the issues of the same value
+	p.issued++
+	txn.Attributes = append(txn.Attributes, &transaction.TxAttribute{
+		Usage: transaction.Nonce, Data: []byte{p.issued},
+	})
+	return p.sign(txn)
+}
+
+// spend sends the first output of parent back to the pool's signer, paying
+// fee.
+func (p *testPool) spend(parent *transaction.Transaction, fee common.Fixed64) *transaction.Transaction {
+	value := parent.Outputs[0].Value
+	txn, _ := transaction.NewTransferAssetTransaction(
+		[]*transaction.UTXOTxInput{{ReferTxID: parent.Hash(), ReferTxOutputIndex: 0}},
+		[]*transaction.TxOutput{{AssetID: p.asset, Value: value - fee, ProgramHash: p.signer.ProgramHash}},
+	)
+	if fee > 0 {
+		txn.SetFee(fee)
+	}
+	return p.sign(txn)
+}
+
+func TestAdoptOrphans(t *testing.T) {
+	pool, restore := newTestPool(t)
+	defer restore()
+	parent := pool.issue(100)
+	child := pool.spend(parent, 0)
+	if errCode := pool.AppendTxnPool(child, true); errCode != ErrOrphanTransaction {
+		t.Fatalf("transaction spending an unknown one: %s", errCode.Error())
+	}
+	if pool.GetTransaction(child.Hash()) != nil || len(pool.adoptOrphans()) != 0 {
+		t.Fatal("orphan transaction appended before its parent")
+	}
+	if errCode := pool.AppendTxnPool(parent, true); errCode != ErrNoError {
+		t.Fatal(errCode.Error())
+	}
+	if adopted := pool.adoptOrphans(); len(adopted) != 1 || adopted[0] != child {
+		t.Fatalf("adopted %d orphan transactions, want the child", len(adopted))
+	}
+	if pool.GetTransaction(child.Hash()) == nil || len(pool.getOrphans()) != 0 {
+		t.Fatal("adopted orphan transaction not moved to the pool")
+	}
+}
+
+func TestEvictParentWithChildren(t *testing.T) {
+	defer func(count int, height uint32) {
+		config.Parameters.MaxPoolTransactions = count
+		config.Parameters.DeclaredFeeHeight = height
+	}(config.Parameters.MaxPoolTransactions, config.Parameters.DeclaredFeeHeight)
+	config.Parameters.MaxPoolTransactions = 3
+	config.Parameters.DeclaredFeeHeight = 1
+
+	pool, restore := newTestPool(t)
+	defer restore()
+	parentA := pool.issue(100000000)
+	childA := pool.spend(parentA, 1000000)
+	issueB := pool.issue(100000000)
+	for _, txn := range []*transaction.Transaction{parentA, childA, issueB} {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatal(errCode.Error())
+		}
+	}
+	// the issues pay no fee, and parentA may not be evicted together with
+	// childA paying a higher fee rate than the incoming transaction
+	cheapB := pool.spend(issueB, 100000)
+	if errCode := pool.AppendTxnPool(cheapB, true); errCode != ErrTxPoolFull {
+		t.Fatalf("transaction evicting a child paying more: %s", errCode.Error())
+	}
+	if pool.GetTransaction(parentA.Hash()) == nil || pool.GetTransaction(childA.Hash()) == nil {
+		t.Fatal("transaction evicted by one paying less than its child")
+	}
+	// spendB may not evict issueB it spends from
+	spendB := pool.spend(issueB, 2000000)
+	if errCode := pool.AppendTxnPool(spendB, true); errCode != ErrNoError {
+		t.Fatal(errCode.Error())
+	}
+	if pool.GetTransactionCount() != 2 || pool.GetTransaction(spendB.Hash()) == nil {
+		t.Fatalf("%d transactions in the pool after eviction", pool.GetTransactionCount())
+	}
+	if pool.GetTransaction(childA.Hash()) != nil {
+		t.Fatal("child kept in the pool after its parent was evicted")
+	}
+	if pool.getInputUTXOList(childA.UTXOInputs[0]) != nil {
+		t.Fatal("input of the evicted child still marked spent")
+	}
+}
+
+func TestCleanExpiredTransactions(t *testing.T) {
+	pool, restore := newTestPool(t)
+	defer restore()
+	expiring := pool.issue(100)
+	expiring.SetValidUntil(5)
+	pool.sign(expiring)
+	child := pool.spend(expiring, 0)
+	other := pool.issue(100)
+	for _, txn := range []*transaction.Transaction{expiring, child, other} {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatal(errCode.Error())
+		}
+	}
+
+	pool.cleanExpiredTransactions(5)
+	if pool.GetTransactionCount() != 3 {
+		t.Fatal("transaction evicted at its valid until height")
+	}
+	pool.cleanExpiredTransactions(6)
+	if pool.GetTransactionCount() != 1 || pool.GetTransaction(other.Hash()) == nil {
+		t.Fatalf("%d transactions in the pool past the valid until height, want the one not expiring",
+			pool.GetTransactionCount())
+	}
+
+	expired := pool.issue(100)
+	expired.SetValidUntil(0)
+	pool.sign(expired)
+	if errCode := pool.AppendTxnPool(expired, true); errCode != ErrTransactionExpired {
+		t.Fatalf("expired transaction appended: %s", errCode.Error())
+	}
+}
+
+func TestEvictionsFor(t *testing.T) {
+	defer func(count int) { config.Parameters.MaxPoolTransactions = count }(config.Parameters.MaxPoolTransactions)
+	var pool TXNPool
+	pool.init()
+	low := balanceSpend(common.Uint160{1}, 1, 10000)
+	high := balanceSpend(common.Uint160{2}, 1, 30000)
+	medium := balanceSpend(common.Uint160{3}, 1, 20000)
+	for _, txn := range []*transaction.Transaction{low, high, medium} {
+		pool.addtxnList(txn)
+	}
+
+	config.Parameters.MaxPoolTransactions = 3
+	if _, err := pool.evictionsFor(balanceSpend(common.Uint160{4}, 1, 5000)); err == nil {
+		t.Fatal("transaction paying the lowest fee rate evicted others")
+	}
+	txn := balanceSpend(common.Uint160{4}, 1, 25000)
+	if evicted, err := pool.evictionsFor(txn); err != nil || len(evicted) != 1 || evicted[0] != low {
+		t.Fatalf("evicted %d transactions, want the one paying the lowest fee rate: %v", len(evicted), err)
+	}
+	config.Parameters.MaxPoolTransactions = 2
+	if evicted, err := pool.evictionsFor(txn); err != nil || len(evicted) != 2 || evicted[0] != low || evicted[1] != medium {
+		t.Fatalf("evicted %d transactions, want those paying a lower fee rate lowest first: %v", len(evicted), err)
+	}
+	config.Parameters.MaxPoolTransactions = 1
+	if _, err := pool.evictionsFor(txn); err == nil {
+		t.Fatal("transaction paying a higher fee rate evicted")
+	}
+}
+
+func TestConcurrentAppendKeepsLimit(t *testing.T) {
+	defer func(count int) {
+		config.Parameters.MaxPoolTransactions = count
+	}(config.Parameters.MaxPoolTransactions)
+	config.Parameters.MaxPoolTransactions = 3
+
+	pool, restore := newTestPool(t)
+	defer restore()
+	txns := []*transaction.Transaction{}
+	for i := 0; i < 10; i++ {
+		txns = append(txns, pool.issue(100))
+	}
+	var wg sync.WaitGroup
+	for _, txn := range txns {
+		wg.Add(1)
+		go func(txn *transaction.Transaction) {
+			defer wg.Done()
+			pool.AppendTxnPool(txn, true)
+		}(txn)
+	}
+	wg.Wait()
+	if pool.GetTransactionCount() != 3 {
+		t.Fatalf("%d transactions in a pool of 3", pool.GetTransactionCount())
+	}
+}
+
+func TestCheckSenderQuota(t *testing.T) {
+	defer func(quota int) { config.Parameters.MaxPoolTxPerSender = quota }(config.Parameters.MaxPoolTxPerSender)
+	config.Parameters.MaxPoolTxPerSender = 2
+	var pool TXNPool
+	pool.init()
+	sender := common.Uint160{1}
+	first := balanceSpend(sender, 1, 100)
+	pool.addtxnList(first)
+	pool.addtxnList(balanceSpend(sender, 2, 100))
+
+	if err := pool.checkSenderQuota(balanceSpend(sender, 3, 100)); err == nil {
+		t.Fatal("transaction of a sender past its quota accepted")
+	}
+	if err := pool.checkSenderQuota(balanceSpend(common.Uint160{2}, 1, 100)); err != nil {
+		t.Fatalf("transaction of another sender rejected: %v", err)
+	}
+	pool.deltxnList(first)
+	if err := pool.checkSenderQuota(balanceSpend(sender, 3, 100)); err != nil {
+		t.Fatalf("quota not freed by a removed transaction: %v", err)
+	}
+}
+
+func TestSaveLoadTxnPool(t *testing.T) {
+	pool, restore := newTestPool(t)
+	defer restore()
+	parent := pool.issue(100)
+	child := pool.spend(parent, 0)
+	for _, txn := range []*transaction.Transaction{parent, child} {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatal(errCode.Error())
+		}
+	}
+	dir, err := ioutil.TempDir("", "txnpool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "TxnPool.dat")
+	if err := pool.SaveTxnPool(file); err != nil {
+		t.Fatal(err)
+	}
+
+	n := &node{}
+	n.TXNPool.init()
+	transaction.UnconfirmedTx = n.GetTransaction
+	if count, err := n.LoadTxnPool(filepath.Join(dir, "missing.dat")); count != 0 || err != nil {
+		t.Fatalf("loaded %d transactions from a missing file: %v", count, err)
+	}
+	// the child is saved after its parent and appended as it is
+	if count, err := n.LoadTxnPool(file); count != 2 || err != nil {
+		t.Fatalf("loaded %d of 2 saved transactions: %v", count, err)
+	}
+	if n.GetTransaction(parent.Hash()) == nil || n.GetTransaction(child.Hash()) == nil || len(n.getOrphans()) != 0 {
+		t.Fatal("saved transactions not restored to the pool")
+	}
+}
+
+func TestRejectedTransactionKeepsInputsUnspent(t *testing.T) {
+	pool, restore := newTestPool(t)
+	defer restore()
+	coin := pool.registerBalanceAsset(100)
+	parent := pool.issue(100)
+	// transfer returns a signed transfer of value of the signer's coin
+	// balance with nonce, spending the inputs too
+	transfer := func(value common.Fixed64, nonce uint64, inputs []*transaction.UTXOTxInput, outputs ...*transaction.TxOutput) *transaction.Transaction {
+		txn, _ := transaction.NewTransferAssetTransaction(inputs, append(outputs,
+			&transaction.TxOutput{AssetID: coin, Value: value, ProgramHash: pool.signer.ProgramHash}))
+		txn.PayloadVersion = payload.TransferAssetBalancePayloadVersion
+		txn.BalanceInputs = []*transaction.BalanceTxInput{
+			{AssetID: coin, Value: value, ProgramHash: pool.signer.ProgramHash, Nonce: nonce},
+		}
+		return pool.sign(txn)
+	}
+	for _, txn := range []*transaction.Transaction{parent, transfer(60, 0, nil)} {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatal(errCode.Error())
+		}
+	}
+
+	// the balance left does not cover the second transfer
+	overspent := transfer(60, 1, []*transaction.UTXOTxInput{{ReferTxID: parent.Hash(), ReferTxOutputIndex: 0}},
+		&transaction.TxOutput{AssetID: pool.asset, Value: 100, ProgramHash: pool.signer.ProgramHash})
+	if errCode := pool.AppendTxnPool(overspent, true); errCode != ErrBalanceInput {
+		t.Fatalf("transaction overspending the balance: %s", errCode.Error())
+	}
+	if errCode := pool.AppendTxnPool(pool.spend(parent, 0), true); errCode != ErrNoError {
+		t.Fatalf("input of the rejected transaction not spendable: %s", errCode.Error())
+	}
+}
@@ -100,7 +100,12 @@ type Noder interface {
 	GetHeight() uint64
 	GetConnectionCnt() uint
 	GetTxnPool(bool) map[common.Uint256]*transaction.Transaction
+	GetTxnsByFeeRate(int) []*transaction.Transaction
+	GetTxnsForBlock(int) []*transaction.Transaction
+	GetBalanceNonce(common.Uint160) (uint64, error)
 	AppendTxnPool(*transaction.Transaction, bool) ErrCode
+	SaveTxnPool(file string) error
+	LoadTxnPool(file string) (int, error)
 	ExistedID(id common.Uint256) bool
 	ReqNeighborList()
 	DumpInfo()
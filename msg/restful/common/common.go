@@ -99,6 +99,7 @@ func GetBlockInfo(block *ledger.Block) BlockInfo {
 		Height:           block.Blockdata.Height,
 		ConsensusData:    block.Blockdata.ConsensusData,
 		NextBookKeeper:   BytesToHexString(block.Blockdata.NextBookKeeper.ToArrayReverse()),
+		StateRoot:        BytesToHexString(block.Blockdata.StateRoot.ToArrayReverse()),
 		Program: ProgramInfo{
 			Code:      BytesToHexString(block.Blockdata.Program.Code),
 			Parameter: BytesToHexString(block.Blockdata.Program.Parameter),
@@ -139,7 +140,9 @@ func GetBlockTransactions(block *ledger.Block) interface{} {
 }
 func getBlock(hash Uint256, getTxBytes bool) (interface{}, int64) {
 	block, err := ledger.DefaultLedger.Store.GetBlock(hash)
-	if err != nil {
+	if err == ledger.ErrPruned {
+		return "", Err.PRUNED_DATA
+	} else if err != nil {
 		return "", Err.UNKNOWN_BLOCK
 	}
 	if getTxBytes {
@@ -195,7 +198,10 @@ func GetBlockTxsByHeight(cmd map[string]interface{}) map[string]interface{} {
 		return resp
 	}
 	block, err := ledger.DefaultLedger.Store.GetBlock(hash)
-	if err != nil {
+	if err == ledger.ErrPruned {
+		resp["Error"] = Err.PRUNED_DATA
+		return resp
+	} else if err != nil {
 		resp["Error"] = Err.UNKNOWN_BLOCK
 		return resp
 	}
@@ -308,17 +314,31 @@ func GetLockedAsset(cmd map[string]interface{}) map[string]interface{} {
 		return resp
 	}
 	type locked struct {
-		Lock   uint32
-		Unlock uint32
-		Amount string
-	}
+		Lock     uint32
+		Unlock   uint32
+		Amount   string
+		Start    uint32
+		Cliff    uint32
+		Release  string
+		Steps    uint32
+		Vested   string
+		Unvested string
+	}
+	height := ledger.DefaultLedger.Store.GetHeight()
 	ret := []*locked{}
 	lockedAsset, _ := ledger.DefaultLedger.Store.GetLockedFromProgramHash(programHash, asset)
 	for _, v := range lockedAsset {
+		unvested := v.Unvested(height)
 		a := &locked{
-			Lock:   v.Lock,
-			Unlock: v.Unlock,
-			Amount: v.Amount.String(),
+			Lock:     v.Lock,
+			Unlock:   v.Unlock,
+			Amount:   v.Amount.String(),
+			Start:    v.Start,
+			Cliff:    v.Cliff,
+			Release:  v.Release.String(),
+			Steps:    v.Steps,
+			Vested:   (v.Amount - unvested).String(),
+			Unvested: unvested.String(),
 		}
 		ret = append(ret, a)
 	}
@@ -327,6 +347,41 @@ func GetLockedAsset(cmd map[string]interface{}) map[string]interface{} {
 	return resp
 }
 
+func GetFrozenAddresses(cmd map[string]interface{}) map[string]interface{} {
+	resp := ResponsePack(Err.SUCCESS)
+	assetid, ok := cmd["Assetid"].(string)
+	if !ok {
+		resp["Error"] = Err.INVALID_PARAMS
+		return resp
+	}
+	tmpID, err := HexStringToBytesReverse(assetid)
+	if err != nil {
+		resp["Error"] = Err.INVALID_PARAMS
+		return resp
+	}
+	asset, err := Uint256ParseFromBytes(tmpID)
+	if err != nil {
+		resp["Error"] = Err.INVALID_PARAMS
+		return resp
+	}
+	frozen, err := ledger.DefaultLedger.Store.GetFrozenAddresses(asset)
+	if err != nil {
+		resp["Error"] = Err.INTERNAL_ERROR
+		return resp
+	}
+	addrs := []string{}
+	for _, programHash := range frozen {
+		addr, err := programHash.ToAddress()
+		if err != nil {
+			resp["Error"] = Err.INTERNAL_ERROR
+			return resp
+		}
+		addrs = append(addrs, addr)
+	}
+	resp["Result"] = addrs
+	return resp
+}
+
 func GetBalanceByAsset(cmd map[string]interface{}) map[string]interface{} {
 	resp := ResponsePack(Err.SUCCESS)
 	addr, ok := cmd["Addr"].(string)
@@ -441,7 +496,61 @@ func GetUnspendOutput(cmd map[string]interface{}) map[string]interface{} {
 	return resp
 }
 
+// GetTxHistoryByAddr pages through the transactions that touched an address,
+// newest first. Type filters on the direction, "in" or "out", and TxType on
+// the transaction type name.
+func GetTxHistoryByAddr(cmd map[string]interface{}) map[string]interface{} {
+	resp := ResponsePack(Err.SUCCESS)
+	addr, ok := cmd["Addr"].(string)
+	if !ok {
+		resp["Error"] = Err.INVALID_PARAMS
+		return resp
+	}
+	cursor, _ := cmd["Cursor"].(string)
+	assetid, _ := cmd["Assetid"].(string)
+	direction, _ := cmd["Type"].(string)
+	txtype, _ := cmd["TxType"].(string)
+	limit := 0
+	if param, ok := cmd["Limit"].(string); ok && len(param) > 0 {
+		l, err := strconv.Atoi(param)
+		if err != nil {
+			resp["Error"] = Err.INVALID_PARAMS
+			return resp
+		}
+		limit = l
+	}
+
+	page, err := GetTxHistoryPage(addr, assetid, direction, txtype, cursor, limit)
+	if err != nil {
+		resp["Error"] = Err.INVALID_PARAMS
+		return resp
+	}
+	resp["Result"] = page
+	return resp
+}
+
 //Transaction
+// GetTxProofByHash returns the block header and the Merkle branch that anchor a
+// transaction, the proof can be checked offline with ledger.VerifyTxProof.
+func GetTxProofByHash(cmd map[string]interface{}) map[string]interface{} {
+	resp := ResponsePack(Err.SUCCESS)
+	str, ok := cmd["Hash"].(string)
+	if !ok {
+		resp["Error"] = Err.INVALID_PARAMS
+		return resp
+	}
+	proof, err := GetTxProof(str)
+	if err == ledger.ErrPruned {
+		resp["Error"] = Err.PRUNED_DATA
+		return resp
+	} else if err != nil {
+		resp["Error"] = Err.UNKNOWN_TRANSACTION
+		return resp
+	}
+	resp["Result"] = proof
+	return resp
+}
+
 func GetTransactionByHash(cmd map[string]interface{}) map[string]interface{} {
 	resp := ResponsePack(Err.SUCCESS)
 
@@ -458,7 +567,10 @@ func GetTransactionByHash(cmd map[string]interface{}) map[string]interface{} {
 		return resp
 	}
 	tx, err := ledger.DefaultLedger.Store.GetTransaction(hash)
-	if err != nil {
+	if err == ledger.ErrPruned {
+		resp["Error"] = Err.PRUNED_DATA
+		return resp
+	} else if err != nil {
 		resp["Error"] = Err.UNKNOWN_TRANSACTION
 		return resp
 	}
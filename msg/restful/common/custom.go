@@ -63,7 +63,10 @@ func GetRecordByHash(cmd map[string]interface{}) map[string]interface{} {
 		return resp
 	}
 	tx, err := ledger.DefaultLedger.Store.GetTransaction(hash)
-	if err != nil {
+	if err == ledger.ErrPruned {
+		resp["Error"] = Err.PRUNED_DATA
+		return resp
+	} else if err != nil {
 		resp["Error"] = Err.UNKNOWN_RECORD
 		return resp
 	}
@@ -89,7 +92,10 @@ func GetRecordByFileHash(cmd map[string]interface{}) map[string]interface{} {
 		return resp
 	}
 	tx, err := ledger.DefaultLedger.Store.GetTransaction(txhash)
-	if err != nil {
+	if err == ledger.ErrPruned {
+		resp["Error"] = Err.PRUNED_DATA
+		return resp
+	} else if err != nil {
 		resp["Error"] = Err.UNKNOWN_TRANSACTION
 		return resp
 	}
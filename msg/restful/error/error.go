@@ -21,6 +21,7 @@ const (
 	UNKNOWN_ASSET       int64 = 44002
 	UNKNOWN_BLOCK       int64 = 44003
 	UNKNOWN_RECORD      int64 = 44004
+	PRUNED_DATA         int64 = 44005
 
 	INVALID_VERSION int64 = 45001
 	INTERNAL_ERROR  int64 = 45002
@@ -49,6 +50,7 @@ var ErrMap = map[int64]string{
 	UNKNOWN_ASSET:       "UNKNOWN ASSET",
 	UNKNOWN_BLOCK:       "UNKNOWN BLOCK",
 	UNKNOWN_RECORD:      "UNKNOWN RECORD",
+	PRUNED_DATA:         "DATA PRUNED ON THIS NODE",
 
 	INVALID_VERSION:                "INVALID VERSION",
 	INTERNAL_ERROR:                 "INTERNAL ERROR",
@@ -66,4 +68,12 @@ var ErrMap = map[int64]string{
 	int64(ErrLockedAsset):          "INTERNAL ERROR, ErrLockedAsset",
 	int64(ErrDuplicateLockAsset):   "INTERNAL ERROR, ErrDuplicateLockAsset",
 	int64(ErrXmitFail):             "INTERNAL ERROR, ErrXmitFail",
+	int64(ErrInsufficientFee):      "INTERNAL ERROR, ErrInsufficientFee",
+	int64(ErrTransactionExpired):   "INTERNAL ERROR, ErrTransactionExpired",
+	int64(ErrBalanceInput):         "INTERNAL ERROR, ErrBalanceInput",
+	int64(ErrFrozenAsset):          "INTERNAL ERROR, ErrFrozenAsset",
+	int64(ErrHTLC):                 "INTERNAL ERROR, ErrHTLC",
+	int64(ErrTxPoolFull):           "INTERNAL ERROR, ErrTxPoolFull",
+	int64(ErrSenderQuota):          "INTERNAL ERROR, ErrSenderQuota",
+	int64(ErrOrphanTransaction):    "INTERNAL ERROR, ErrOrphanTransaction",
 }
@@ -42,12 +42,15 @@ const (
 	Api_Getblockhash        = "/api/v1/block/hash/:height"
 	Api_GetTotalIssued      = "/api/v1/totalissued/:assetid"
 	Api_Gettransaction      = "/api/v1/transaction/:hash"
+	Api_GetTxProof          = "/api/v1/transaction/proof/:hash"
 	Api_Getasset            = "/api/v1/asset/:hash"
 	Api_GetBalanceByAddr    = "/api/v1/asset/balances/:addr"
 	Api_GetBalancebyAsset   = "/api/v1/asset/balance/:addr/:assetid"
 	Api_GetLockedAsset      = "/api/v1/asset/locked/:addr/:assetid"
+	Api_GetFrozenAddresses  = "/api/v1/asset/frozen/:assetid"
 	Api_GetUTXObyAsset      = "/api/v1/asset/utxo/:addr/:assetid"
 	Api_GetUTXObyAddr       = "/api/v1/asset/utxos/:addr"
+	Api_GetTxHistoryByAddr  = "/api/v1/address/history/:addr"
 	Api_SendRawTx           = "/api/v1/transaction"
 	Api_SendRcdTxByTrans    = "/api/v1/custom/transaction/record"
 	Api_GetStateUpdate      = "/api/v1/stateupdate/:namespace/:key"
@@ -150,6 +153,7 @@ func (rt *restServer) registryMethod() {
 		Api_Getblockhash:        {name: "getblockhash", handler: GetBlockHash},
 		Api_GetTotalIssued:      {name: "gettotalissued", handler: GetTotalIssued},
 		Api_Gettransaction:      {name: "gettransaction", handler: GetTransactionByHash},
+		Api_GetTxProof:          {name: "gettxproof", handler: GetTxProofByHash},
 		Api_Getasset:            {name: "getasset", handler: GetAssetByHash},
 		Api_GetContract:         {name: "getcontract", handler: GetContract},
 		Api_GetUTXObyAddr:       {name: "getutxobyaddr", handler: GetUnspends},
@@ -157,6 +161,8 @@ func (rt *restServer) registryMethod() {
 		Api_GetBalanceByAddr:    {name: "getbalancebyaddr", handler: GetBalanceByAddr},
 		Api_GetBalancebyAsset:   {name: "getbalancebyasset", handler: GetBalanceByAsset},
 		Api_GetLockedAsset:      {name: "getlockedasset", handler: GetLockedAsset},
+		Api_GetFrozenAddresses:  {name: "getfrozenaddresses", handler: GetFrozenAddresses},
+		Api_GetTxHistoryByAddr:  {name: "gettxhistorybyaddr", handler: GetTxHistoryByAddr},
 		Api_OauthServerUrl:      {name: "getoauthserverurl", handler: GetOauthServerUrl},
 		Api_NoticeServerUrl:     {name: "getnoticeserverurl", handler: GetNoticeServerUrl},
 		Api_Restart:             {name: "restart", handler: rt.Restart},
@@ -203,6 +209,8 @@ func (rt *restServer) getPath(url string) string {
 		return Api_Getblockbyhash
 	} else if strings.Contains(url, strings.TrimRight(Api_GetTotalIssued, ":assetid")) {
 		return Api_GetTotalIssued
+	} else if strings.Contains(url, strings.TrimRight(Api_GetTxProof, ":hash")) {
+		return Api_GetTxProof
 	} else if strings.Contains(url, strings.TrimRight(Api_Gettransaction, ":hash")) {
 		return Api_Gettransaction
 	} else if strings.Contains(url, strings.TrimRight(Api_GetContract, ":hash")) {
@@ -213,10 +221,14 @@ func (rt *restServer) getPath(url string) string {
 		return Api_GetBalancebyAsset
 	} else if strings.Contains(url, strings.TrimRight(Api_GetLockedAsset, ":addr/:assetid")) {
 		return Api_GetLockedAsset
+	} else if strings.Contains(url, strings.TrimRight(Api_GetFrozenAddresses, ":assetid")) {
+		return Api_GetFrozenAddresses
 	} else if strings.Contains(url, strings.TrimRight(Api_GetUTXObyAddr, ":addr")) {
 		return Api_GetUTXObyAddr
 	} else if strings.Contains(url, strings.TrimRight(Api_GetUTXObyAsset, ":addr/:assetid")) {
 		return Api_GetUTXObyAsset
+	} else if strings.Contains(url, strings.TrimRight(Api_GetTxHistoryByAddr, ":addr")) {
+		return Api_GetTxHistoryByAddr
 	} else if strings.Contains(url, strings.TrimRight(Api_Getasset, ":hash")) {
 		return Api_Getasset
 	} else if strings.Contains(url, strings.TrimRight(Api_GetStateUpdate, ":namespace/:key")) {
@@ -257,6 +269,9 @@ func (rt *restServer) getParams(r *http.Request, url string, req map[string]inte
 		req["Hash"] = getParam(r, "hash")
 		req["Raw"] = r.FormValue("raw")
 		break
+	case Api_GetTxProof:
+		req["Hash"] = getParam(r, "hash")
+		break
 	case Api_GetContract:
 		req["Hash"] = getParam(r, "hash")
 		req["Raw"] = r.FormValue("raw")
@@ -273,6 +288,9 @@ func (rt *restServer) getParams(r *http.Request, url string, req map[string]inte
 		req["Addr"] = getParam(r, "addr")
 		req["Assetid"] = getParam(r, "assetid")
 		break
+	case Api_GetFrozenAddresses:
+		req["Assetid"] = getParam(r, "assetid")
+		break
 	case Api_GetBalanceByAddr:
 		req["Addr"] = getParam(r, "addr")
 		break
@@ -283,6 +301,14 @@ func (rt *restServer) getParams(r *http.Request, url string, req map[string]inte
 		req["Addr"] = getParam(r, "addr")
 		req["Assetid"] = getParam(r, "assetid")
 		break
+	case Api_GetTxHistoryByAddr:
+		req["Addr"] = getParam(r, "addr")
+		req["Cursor"] = r.FormValue("cursor")
+		req["Limit"] = r.FormValue("limit")
+		req["Assetid"] = r.FormValue("assetid")
+		req["Type"] = r.FormValue("type")
+		req["TxType"] = r.FormValue("txtype")
+		break
 	case Api_Restart:
 		break
 	case Api_SendRawTx:
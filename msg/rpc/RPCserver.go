@@ -28,9 +28,28 @@ func StartRPCServer() {
 	HandleFunc("getversion", getVersion)
 	HandleFunc("getneighbor", getNeighbor)
 	HandleFunc("getnodestate", getNodeState)
+	HandleFunc("getaddresshistory", getAddressHistory)
+	HandleFunc("gettxproof", getTxProof)
+	HandleFunc("getstateproof", getStateProof)
+	HandleFunc("estimatefee", estimateFee)
+	HandleFunc("getconsensuspolicy", getConsensusPolicy)
 
 	HandleFunc("setdebuginfo", setDebugInfo)
+	HandleMaintenanceFunc("reloadconsensuspolicy", reloadConsensusPolicy)
+	HandleMaintenanceFunc("rollbackto", rollbackTo)
+	HandleMaintenanceFunc("exportsnapshot", exportSnapshot)
+	HandleMaintenanceFunc("verifyledger", verifyLedger)
+	HandleMaintenanceFunc("exportblocks", exportBlocks)
+	HandleMaintenanceFunc("importblocks", importBlocks)
 	HandleFunc("lockasset", lockAsset)
+	HandleFunc("burnasset", burnAsset)
+	HandleFunc("freezeasset", freezeAsset)
+	HandleFunc("initiateswap", initiateSwap)
+	HandleFunc("redeemswap", redeemSwap)
+	HandleFunc("refundswap", refundSwap)
+	HandleFunc("maketradeoffer", makeTradeOffer)
+	HandleFunc("accepttradeoffer", acceptTradeOffer)
+	HandleFunc("signtrade", signTrade)
 	HandleFunc("createmultisigtransaction", createMultisigTransaction)
 	HandleFunc("signmultisigtransaction", signMultisigTransaction)
 	HandleFunc("addaccount", addAccount)
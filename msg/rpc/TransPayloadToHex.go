@@ -53,6 +53,17 @@ type LockAssetInfo struct {
 	LockHeight uint32
 }
 
+type BurnAssetInfo struct {
+	AssetID string
+	Amount  string
+}
+
+type FreezeAssetInfo struct {
+	AssetID string
+	Address string
+	Freeze  bool
+}
+
 type RecordInfo struct {
 	RecordType string
 	RecordData string
@@ -138,6 +149,18 @@ func TransPayloadToHex(p Payload) PayloadInfo {
 		obj.Amount = object.Amount.String()
 		obj.LockHeight = object.UnlockHeight
 		return obj
+	case *payload.BurnAsset:
+		obj := new(BurnAssetInfo)
+		obj.AssetID = BytesToHexString(object.AssetID.ToArrayReverse())
+		obj.Amount = object.Amount.String()
+		return obj
+	case *payload.FreezeAsset:
+		obj := new(FreezeAssetInfo)
+		obj.AssetID = BytesToHexString(object.AssetID.ToArrayReverse())
+		address, _ := object.ProgramHash.ToAddress()
+		obj.Address = address
+		obj.Freeze = object.Freeze
+		return obj
 	case *payload.Record:
 		obj := new(RecordInfo)
 		obj.RecordType = object.RecordType
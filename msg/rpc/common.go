@@ -2,23 +2,33 @@ package rpc
 
 import (
 	. "IPT/common"
+	"IPT/common/config"
 	. "IPT/common/errors"
 	"IPT/common/log"
 	"IPT/consensus/ebft"
+	"IPT/core/ledger"
 	. "IPT/core/transaction"
 	tx "IPT/core/transaction"
+	"IPT/core/validation"
+	"IPT/crypto"
 	. "IPT/msg/protocol"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"time"
 )
 
 func init() {
 	mainMux.m = make(map[string]func([]interface{}) map[string]interface{})
+	mainMux.maintenance = make(map[string]bool)
 }
 
 //an instance of the multiplexer
@@ -30,6 +40,7 @@ var dBFT *ebft.DbftService
 type ServeMux struct {
 	sync.RWMutex
 	m               map[string]func([]interface{}) map[string]interface{}
+	maintenance     map[string]bool
 	defaultFunction func(http.ResponseWriter, *http.Request)
 }
 
@@ -47,6 +58,7 @@ type BalanceTxInputInfo struct {
 	AssetID     string
 	Value       string
 	ProgramHash string
+	Nonce       uint64
 }
 
 type TxoutputInfo struct {
@@ -95,6 +107,7 @@ type BlockHead struct {
 	Height           uint32
 	ConsensusData    uint64
 	NextBookKeeper   string
+	StateRoot        string
 	Program          ProgramInfo
 
 	Hash string
@@ -137,10 +150,317 @@ type AccountWalletInfo struct {
 	PrivateKey string
 }
 
+// SwapInfo describes an HTLC funded by initiateswap. Secret is only set
+// when the node generated the preimage.
+type SwapInfo struct {
+	Contract string
+	Address  string
+	HashLock string
+	Secret   string
+	Timeout  uint32
+	Txid     string
+	Index    uint16
+	Value    string
+}
+
 type ConsensusInfo struct {
 	// TODO
 }
 
+const (
+	DefaultTxHistoryLimit = 50
+	MaxTxHistoryLimit     = 1000
+)
+
+type TxHistoryInfo struct {
+	Height    uint32
+	Txid      string
+	TxType    string
+	Direction string
+	AssetId   string
+	Value     string
+}
+
+type TxHistoryPage struct {
+	History []TxHistoryInfo
+	Cursor  string
+}
+
+// GetTxHistoryPage looks up one page of the history of addr, newest first.
+// Empty assetid, direction and txtype match everything, an empty cursor starts
+// from the latest entry and limit 0 means DefaultTxHistoryLimit. txtype is a
+// transaction type name as in the TransactionFee configuration.
+func GetTxHistoryPage(addr string, assetid string, direction string, txtype string, cursor string, limit int) (*TxHistoryPage, error) {
+	programHash, err := ToScriptHash(addr)
+	if err != nil {
+		return nil, err
+	}
+	var assetId *Uint256
+	if assetid != "" {
+		bys, err := HexStringToBytesReverse(assetid)
+		if err != nil {
+			return nil, err
+		}
+		id, err := Uint256ParseFromBytes(bys)
+		if err != nil {
+			return nil, err
+		}
+		assetId = &id
+	}
+	var dir TxDirection
+	switch direction {
+	case "":
+	case TxIncoming.String():
+		dir = TxIncoming
+	case TxOutgoing.String():
+		dir = TxOutgoing
+	default:
+		return nil, errors.New("direction should be in or out")
+	}
+	var txType *TransactionType
+	if txtype != "" {
+		t, ok := TxTypeByName(txtype)
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("unknown transaction type %s", txtype))
+		}
+		txType = &t
+	}
+	var from []byte
+	if cursor != "" {
+		if from, err = HexStringToBytes(cursor); err != nil {
+			return nil, err
+		}
+	}
+	if limit == 0 {
+		limit = DefaultTxHistoryLimit
+	}
+	if limit < 0 || limit > MaxTxHistoryLimit {
+		return nil, errors.New(fmt.Sprintf("limit should be between 1 and %d", MaxTxHistoryLimit))
+	}
+
+	history, next, err := ledger.DefaultLedger.Store.GetTxHistory(programHash, assetId, dir, txType, from, limit)
+	if err != nil {
+		return nil, err
+	}
+	page := &TxHistoryPage{History: make([]TxHistoryInfo, 0, len(history))}
+	for _, v := range history {
+		page.History = append(page.History, TxHistoryInfo{
+			Height:    v.Height,
+			Txid:      BytesToHexString(v.Txid.ToArrayReverse()),
+			TxType:    TxTypeName(v.TxType),
+			Direction: v.Direction.String(),
+			AssetId:   BytesToHexString(v.AssetID.ToArrayReverse()),
+			Value:     v.Value.String(),
+		})
+	}
+	if next != nil {
+		page.Cursor = BytesToHexString(next)
+	}
+
+	return page, nil
+}
+
+// TxProofInfo is the proof anchoring a transaction in a block, with the
+// header decoded for display. Offline clients check it with
+// ledger.VerifyTxProof.
+type TxProofInfo struct {
+	ledger.TxProof
+	Header *BlockHead
+}
+
+// GetTxProof builds the Merkle inclusion proof of the transaction txid.
+func GetTxProof(txid string) (*TxProofInfo, error) {
+	bys, err := HexStringToBytesReverse(txid)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := Uint256ParseFromBytes(bys)
+	if err != nil {
+		return nil, err
+	}
+	height, err := ledger.DefaultLedger.Store.GetTransactionHeight(hash)
+	if err != nil {
+		return nil, err
+	}
+	blockHash, err := ledger.DefaultLedger.Store.GetBlockHash(height)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ledger.DefaultLedger.Store.GetBlock(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	index := -1
+	hashes := make([]Uint256, len(block.Transactions))
+	for i, t := range block.Transactions {
+		hashes[i] = t.Hash()
+		if hashes[i] == hash {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, errors.New("transaction not found in block")
+	}
+	proof, err := crypto.NewMerkleProof(hashes, index)
+	if err != nil {
+		return nil, err
+	}
+
+	w := bytes.NewBuffer(nil)
+	block.Blockdata.Serialize(w)
+	info := &TxProofInfo{
+		TxProof: ledger.TxProof{
+			Txid:      txid,
+			BlockHash: BytesToHexString(blockHash.ToArrayReverse()),
+			RawHeader: BytesToHexString(w.Bytes()),
+			TxIndex:   proof.Index,
+			Branch:    make([]string, len(proof.Branch)),
+		},
+		Header: &BlockHead{
+			Version:          block.Blockdata.Version,
+			PrevBlockHash:    BytesToHexString(block.Blockdata.PrevBlockHash.ToArrayReverse()),
+			TransactionsRoot: BytesToHexString(block.Blockdata.TransactionsRoot.ToArrayReverse()),
+			Timestamp:        block.Blockdata.Timestamp,
+			Height:           block.Blockdata.Height,
+			ConsensusData:    block.Blockdata.ConsensusData,
+			NextBookKeeper:   BytesToHexString(block.Blockdata.NextBookKeeper.ToArrayReverse()),
+			StateRoot:        BytesToHexString(block.Blockdata.StateRoot.ToArrayReverse()),
+			Program: ProgramInfo{
+				Code:      BytesToHexString(block.Blockdata.Program.Code),
+				Parameter: BytesToHexString(block.Blockdata.Program.Parameter),
+			},
+			Hash: BytesToHexString(blockHash.ToArrayReverse()),
+		},
+	}
+	for i, h := range proof.Branch {
+		info.Branch[i] = BytesToHexString(h.ToArrayReverse())
+	}
+
+	return info, nil
+}
+
+// StateProofInfo proves the value of a state key, empty when the key is
+// absent, against the state root after block Height.
+type StateProofInfo struct {
+	Key       string
+	Value     string
+	Height    uint32
+	StateRoot string
+	Siblings  []string
+	LeafKey   string
+	LeafValue string
+}
+
+func GetStateProof(key []byte) (*StateProofInfo, error) {
+	value, height, root, proof, err := ledger.DefaultLedger.Store.GetStateProof(key)
+	if err != nil {
+		return nil, err
+	}
+	info := &StateProofInfo{
+		Key:       BytesToHexString(key),
+		Value:     BytesToHexString(value),
+		Height:    height,
+		StateRoot: BytesToHexString(root.ToArrayReverse()),
+		Siblings:  make([]string, len(proof.Siblings)),
+		LeafKey:   BytesToHexString(proof.LeafKey.ToArrayReverse()),
+		LeafValue: BytesToHexString(proof.LeafValue.ToArrayReverse()),
+	}
+	for i, h := range proof.Siblings {
+		info.Siblings[i] = BytesToHexString(h.ToArrayReverse())
+	}
+
+	return info, nil
+}
+
+// VerifyStateProof checks offline that info is consistent and returns the
+// state root it proves against. The caller compares it with the StateRoot of
+// the block header at info.Height+1.
+func VerifyStateProof(info *StateProofInfo) (Uint256, error) {
+	parse := func(s string) (Uint256, error) {
+		bys, err := HexStringToBytesReverse(s)
+		if err != nil {
+			return Uint256{}, err
+		}
+		return Uint256ParseFromBytes(bys)
+	}
+	key, err := HexStringToBytes(info.Key)
+	if err != nil {
+		return Uint256{}, err
+	}
+	var value []byte
+	if info.Value != "" {
+		if value, err = HexStringToBytes(info.Value); err != nil {
+			return Uint256{}, err
+		}
+	}
+	root, err := parse(info.StateRoot)
+	if err != nil {
+		return Uint256{}, err
+	}
+	proof := &crypto.StateProof{Siblings: make([]Uint256, len(info.Siblings))}
+	if proof.LeafKey, err = parse(info.LeafKey); err != nil {
+		return Uint256{}, err
+	}
+	if proof.LeafValue, err = parse(info.LeafValue); err != nil {
+		return Uint256{}, err
+	}
+	for i, h := range info.Siblings {
+		if proof.Siblings[i], err = parse(h); err != nil {
+			return Uint256{}, err
+		}
+	}
+	if !proof.Verify(key, value, root) {
+		return Uint256{}, errors.New("state proof does not match the state root")
+	}
+
+	return root, nil
+}
+
+// blockPersistTimeout bounds the wait for an imported block to be persisted.
+const blockPersistTimeout = 30 * time.Second
+
+// ImportBlocks verifies the blocks of a block stream and saves them one after
+// the other, each is persisted before the next is verified against the
+// ledger. Blocks the ledger already has are skipped. It returns the number of
+// blocks saved.
+func ImportBlocks(r io.Reader) (uint32, error) {
+	stream, err := ledger.NewBlockStreamReader(r)
+	if err != nil {
+		return 0, err
+	}
+
+	var saved uint32
+	for {
+		block, err := stream.Next()
+		if err == io.EOF {
+			return saved, nil
+		}
+		if err != nil {
+			return saved, err
+		}
+		height := block.Blockdata.Height
+		if height <= ledger.DefaultLedger.Store.GetHeight() {
+			continue
+		}
+
+		if err := validation.VerifyBlock(block, ledger.DefaultLedger, true); err != nil {
+			return saved, errors.New(fmt.Sprintf("block %d: %v", height, err))
+		}
+		if err := ledger.DefaultLedger.Store.SaveBlock(block, ledger.DefaultLedger); err != nil {
+			return saved, errors.New(fmt.Sprintf("block %d: %v", height, err))
+		}
+		deadline := time.Now().Add(blockPersistTimeout)
+		for ledger.DefaultLedger.Store.GetHeight() < height {
+			if time.Now().After(deadline) {
+				return saved, errors.New(fmt.Sprintf("block %d was not persisted", height))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		saved++
+	}
+}
+
 func RegistRpcNode(n Noder) {
 	if node == nil {
 		node = n
@@ -160,6 +480,30 @@ func HandleFunc(pattern string, handler func([]interface{}) map[string]interface
 	mainMux.m[pattern] = handler
 }
 
+// HandleMaintenanceFunc registers a method that changes the local ledger or
+// the files of the node. It is only served when MaintenanceRPC is enabled
+// and the caller is on the same host.
+func HandleMaintenanceFunc(pattern string, handler func([]interface{}) map[string]interface{}) {
+	mainMux.Lock()
+	defer mainMux.Unlock()
+	mainMux.m[pattern] = handler
+	mainMux.maintenance[pattern] = true
+}
+
+// maintenanceAllowed reports whether the maintenance methods are served to
+// the caller of r.
+func maintenanceAllowed(r *http.Request) bool {
+	if !config.Parameters.MaintenanceRPC {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 //a function to be called if the request is not a HTTP JSON RPC call
 func SetDefaultFunc(def func(http.ResponseWriter, *http.Request)) {
 	mainMux.defaultFunction = def
@@ -210,7 +554,13 @@ func Handle(w http.ResponseWriter, r *http.Request) {
 	//get the corresponding function
 	function, ok := mainMux.m[request["method"].(string)]
 	if ok {
-		response := function(request["params"].([]interface{}))
+		var response map[string]interface{}
+		if mainMux.maintenance[request["method"].(string)] && !maintenanceAllowed(r) {
+			log.Warn("HTTP JSON RPC Handle - maintenance method refused to ", r.RemoteAddr)
+			response = IPTRpcForbidden
+		} else {
+			response = function(request["params"].([]interface{}))
+		}
 		data, err := json.Marshal(map[string]interface{}{
 			"jsonpc": "2.0",
 			"result": response["result"],
@@ -288,3 +638,38 @@ func VerifyAndSendTx(txn *tx.Transaction) ErrCode {
 	}
 	return ErrNoError
 }
+
+// FeeEstimate tells clients the fee to declare for a transaction. PoolFeeRate
+// is the fee rate of the last pool transaction fitting into the next block,
+// zero while the pool does not fill a block.
+type FeeEstimate struct {
+	MinimumFee  string
+	FeePerByte  string
+	PoolFeeRate string
+	Fee         string
+}
+
+// EstimateFee estimates the fee for a transaction of type txType, spending
+// UTXO inputs and size bytes long once signed.
+func EstimateFee(txType tx.TransactionType, size int) *FeeEstimate {
+	minimum := tx.MinimumFee(txType, size)
+	fee := minimum
+	var poolRate Fixed64
+	if count := config.Parameters.MaxTxInBlock; count > 0 {
+		txns := node.GetTxnsByFeeRate(count)
+		if len(txns) == count {
+			poolRate = txns[count-1].FeeRate()
+			// outbid the last transaction of the next block
+			if f := (poolRate + 1) * Fixed64(size); f > fee {
+				fee = f
+			}
+		}
+	}
+
+	return &FeeEstimate{
+		MinimumFee:  minimum.String(),
+		FeePerByte:  tx.FeePerByte().String(),
+		PoolFeeRate: poolRate.String(),
+		Fee:         fee.String(),
+	}
+}
@@ -1,9 +1,12 @@
 package rpc
 
 import (
+	"bufio"
 	"bytes"
+	crand "crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -15,9 +18,12 @@ import (
 	"IPT/common/config"
 	. "IPT/common/errors"
 	"IPT/common/log"
+	"IPT/consensus"
+	"IPT/core/asset"
+	"IPT/core/contract"
 	"IPT/core/ledger"
-	"IPT/core/signature"
 	tx "IPT/core/transaction"
+	"IPT/crypto"
 	"IPT/sdk"
 
 	"github.com/mitchellh/go-homedir"
@@ -56,6 +62,7 @@ func TransArryByteToHexString(ptx *tx.Transaction) *Transactions {
 		trans.BalanceInputs[n].AssetID = BytesToHexString(v.AssetID.ToArrayReverse())
 		trans.BalanceInputs[n].Value = v.Value.String()
 		trans.BalanceInputs[n].ProgramHash = BytesToHexString(v.ProgramHash.ToArrayReverse())
+		trans.BalanceInputs[n].Nonce = v.Nonce
 		n++
 	}
 
@@ -155,7 +162,9 @@ func getBlock(params []interface{}) map[string]interface{} {
 	}
 
 	block, err := ledger.DefaultLedger.Store.GetBlock(hash)
-	if err != nil {
+	if err == ledger.ErrPruned {
+		return IPTRpcPrunedData
+	} else if err != nil {
 		return IPTRpcUnknownBlock
 	}
 
@@ -167,6 +176,7 @@ func getBlock(params []interface{}) map[string]interface{} {
 		Height:           block.Blockdata.Height,
 		ConsensusData:    block.Blockdata.ConsensusData,
 		NextBookKeeper:   BytesToHexString(block.Blockdata.NextBookKeeper.ToArrayReverse()),
+		StateRoot:        BytesToHexString(block.Blockdata.StateRoot.ToArrayReverse()),
 		Program: ProgramInfo{
 			Code:      BytesToHexString(block.Blockdata.Program.Code),
 			Parameter: BytesToHexString(block.Blockdata.Program.Parameter),
@@ -210,6 +220,293 @@ func getBlockHash(params []interface{}) map[string]interface{} {
 	}
 }
 
+// The rollback cannot be undone, so it is a maintenance method served only
+// to local callers. A JSON example for rollbackto method as following:
+//   {"jsonrpc": "2.0", "method": "rollbackto", "params": [1], "id": 0}
+func rollbackTo(params []interface{}) map[string]interface{} {
+	if len(params) < 1 {
+		return IPTRpcNil
+	}
+	switch params[0].(type) {
+	case float64:
+		height := uint32(params[0].(float64))
+		if err := ledger.DefaultLedger.Blockchain.RollbackTo(height); err != nil {
+			return IPTRpc("error: " + err.Error())
+		}
+		return IPTRpc(ledger.DefaultLedger.Blockchain.BlockHeight)
+	default:
+		return IPTRpcInvalidParameter
+	}
+}
+
+// The snapshot file is written on the node side, so it is a maintenance
+// method served only to local callers and never overwrites a file. A JSON
+// example for exportsnapshot method as following:
+//   {"jsonrpc": "2.0", "method": "exportsnapshot", "params": [1000, "snapshot.dat"], "id": 0}
+func exportSnapshot(params []interface{}) map[string]interface{} {
+	if len(params) < 2 {
+		return IPTRpcNil
+	}
+	var height uint32
+	var path string
+	switch params[0].(type) {
+	case float64:
+		height = uint32(params[0].(float64))
+	default:
+		return IPTRpcInvalidParameter
+	}
+	switch params[1].(type) {
+	case string:
+		path = params[1].(string)
+	default:
+		return IPTRpcInvalidParameter
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0664)
+	if err != nil {
+		return IPTRpcIOError
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if err := ledger.DefaultLedger.Store.ExportSnapshot(height, w); err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	if err := w.Flush(); err != nil {
+		return IPTRpcIOError
+	}
+
+	return IPTRpc(path)
+}
+
+// The block stream file is written on the node side, so it is a maintenance
+// method served only to local callers and never overwrites a file. A JSON
+// example for exportblocks method as following:
+//   {"jsonrpc": "2.0", "method": "exportblocks", "params": [1, 1000, "blocks.dat"], "id": 0}
+func exportBlocks(params []interface{}) map[string]interface{} {
+	if len(params) < 3 {
+		return IPTRpcNil
+	}
+	var from, to uint32
+	var path string
+	switch params[0].(type) {
+	case float64:
+		from = uint32(params[0].(float64))
+	default:
+		return IPTRpcInvalidParameter
+	}
+	switch params[1].(type) {
+	case float64:
+		to = uint32(params[1].(float64))
+	default:
+		return IPTRpcInvalidParameter
+	}
+	switch params[2].(type) {
+	case string:
+		path = params[2].(string)
+	default:
+		return IPTRpcInvalidParameter
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0664)
+	if err != nil {
+		return IPTRpcIOError
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if err := ledger.ExportBlocks(ledger.DefaultLedger.Store, from, to, w); err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	if err := w.Flush(); err != nil {
+		return IPTRpcIOError
+	}
+
+	return IPTRpc(path)
+}
+
+// The block stream file is read on the node side, so it is a maintenance
+// method served only to local callers. A JSON example for importblocks
+// method as following:
+//   {"jsonrpc": "2.0", "method": "importblocks", "params": ["blocks.dat"], "id": 0}
+func importBlocks(params []interface{}) map[string]interface{} {
+	if len(params) < 1 {
+		return IPTRpcNil
+	}
+	var path string
+	switch params[0].(type) {
+	case string:
+		path = params[0].(string)
+	default:
+		return IPTRpcInvalidParameter
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return IPTRpcIOError
+	}
+	defer f.Close()
+
+	saved, err := ImportBlocks(bufio.NewReader(f))
+	if err != nil {
+		return IPTRpc(fmt.Sprintf("error: %d blocks imported, %v", saved, err))
+	}
+
+	return IPTRpc(ledger.DefaultLedger.Store.GetHeight())
+}
+
+// The result lists every mismatch between the derived indexes and the
+// replayed blocks. With repair it rewrites the ledger, so it is a maintenance
+// method served only to local callers. A JSON example for verifyledger
+// method as following:
+//   {"jsonrpc": "2.0", "method": "verifyledger", "params": [false], "id": 0}
+func verifyLedger(params []interface{}) map[string]interface{} {
+	repair := false
+	if len(params) > 0 {
+		switch params[0].(type) {
+		case bool:
+			repair = params[0].(bool)
+		default:
+			return IPTRpcInvalidParameter
+		}
+	}
+
+	mismatches, err := ledger.DefaultLedger.Store.VerifyLedger(repair)
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	if mismatches == nil {
+		mismatches = []string{}
+	}
+
+	return IPTRpc(mismatches)
+}
+
+// The size is the length of the signed transaction in bytes. A JSON example
+// for estimatefee method as following:
+//   {"jsonrpc": "2.0", "method": "estimatefee", "params": ["Transfer", 250], "id": 0}
+func estimateFee(params []interface{}) map[string]interface{} {
+	if len(params) < 2 {
+		return IPTRpcNil
+	}
+	var txType tx.TransactionType
+	switch params[0].(type) {
+	case string:
+		t, ok := tx.TxTypeByName(params[0].(string))
+		if !ok {
+			return IPTRpcInvalidParameter
+		}
+		txType = t
+	default:
+		return IPTRpcInvalidParameter
+	}
+	var size int
+	switch params[1].(type) {
+	case float64:
+		size = int(params[1].(float64))
+	default:
+		return IPTRpcInvalidParameter
+	}
+	if size <= 0 {
+		return IPTRpcInvalidParameter
+	}
+
+	return IPTRpc(EstimateFee(txType, size))
+}
+
+// Every parameter after the address is optional, the cursor comes from the
+// previous page. A JSON example for getaddresshistory method as following:
+//   {"jsonrpc": "2.0", "method": "getaddresshistory", "params": ["address", "cursor", 50, "assetid", "in", "Transfer"], "id": 0}
+func getAddressHistory(params []interface{}) map[string]interface{} {
+	if len(params) < 1 {
+		return IPTRpcNil
+	}
+	var addr, cursor, assetid, direction, txtype string
+	var limit int
+	var ok bool
+	if addr, ok = params[0].(string); !ok {
+		return IPTRpcInvalidParameter
+	}
+	if len(params) > 1 {
+		if cursor, ok = params[1].(string); !ok {
+			return IPTRpcInvalidParameter
+		}
+	}
+	if len(params) > 2 {
+		l, ok := params[2].(float64)
+		if !ok {
+			return IPTRpcInvalidParameter
+		}
+		limit = int(l)
+	}
+	if len(params) > 3 {
+		if assetid, ok = params[3].(string); !ok {
+			return IPTRpcInvalidParameter
+		}
+	}
+	if len(params) > 4 {
+		if direction, ok = params[4].(string); !ok {
+			return IPTRpcInvalidParameter
+		}
+	}
+	if len(params) > 5 {
+		if txtype, ok = params[5].(string); !ok {
+			return IPTRpcInvalidParameter
+		}
+	}
+
+	page, err := GetTxHistoryPage(addr, assetid, direction, txtype, cursor, limit)
+	if err != nil {
+		return IPTRpcInvalidParameter
+	}
+
+	return IPTRpc(page)
+}
+
+// A JSON example for gettxproof method as following:
+//   {"jsonrpc": "2.0", "method": "gettxproof", "params": ["transaction hash in hex"], "id": 0}
+func getTxProof(params []interface{}) map[string]interface{} {
+	if len(params) < 1 {
+		return IPTRpcNil
+	}
+	switch params[0].(type) {
+	case string:
+		proof, err := GetTxProof(params[0].(string))
+		if err == ledger.ErrPruned {
+			return IPTRpcPrunedData
+		} else if err != nil {
+			return IPTRpcUnknownTransaction
+		}
+		return IPTRpc(proof)
+	default:
+		return IPTRpcInvalidParameter
+	}
+}
+
+// The key is a ledger state key in hex, such as "c2" followed by a program
+// hash. The state root of Height is committed in the header of block
+// Height+1. A JSON example for getstateproof method as following:
+//   {"jsonrpc": "2.0", "method": "getstateproof", "params": ["state key in hex"], "id": 0}
+func getStateProof(params []interface{}) map[string]interface{} {
+	if len(params) < 1 {
+		return IPTRpcNil
+	}
+	switch params[0].(type) {
+	case string:
+		key, err := HexStringToBytes(params[0].(string))
+		if err != nil {
+			return IPTRpcInvalidParameter
+		}
+		proof, err := GetStateProof(key)
+		if err != nil {
+			return IPTRpcInvalidParameter
+		}
+		return IPTRpc(proof)
+	default:
+		return IPTRpcInvalidParameter
+	}
+}
+
 func getConnectionCount(params []interface{}) map[string]interface{} {
 	return IPTRpc(node.GetConnectionCnt())
 }
@@ -245,7 +542,9 @@ func getRawTransaction(params []interface{}) map[string]interface{} {
 			return IPTRpcInvalidTransaction
 		}
 		tx, err := ledger.DefaultLedger.Store.GetTransaction(hash)
-		if err != nil {
+		if err == ledger.ErrPruned {
+			return IPTRpcPrunedData
+		} else if err != nil {
 			return IPTRpcUnknownTransaction
 		}
 		tran := TransArryByteToHexString(tx)
@@ -276,6 +575,7 @@ func sendRawTransaction(params []interface{}) map[string]interface{} {
 		if txn.TxType != tx.InvokeCode && txn.TxType != tx.DeployCode &&
 			txn.TxType != tx.TransferAsset && txn.TxType != tx.LockAsset &&
 			txn.TxType != tx.RegisterAsset && txn.TxType != tx.IssueAsset &&
+			txn.TxType != tx.BurnAsset && txn.TxType != tx.FreezeAsset &&
 			txn.TxType != tx.BookKeeper {
 			return IPTRpc("invalid transaction type")
 		}
@@ -355,6 +655,29 @@ func stopConsensus(params []interface{}) map[string]interface{} {
 	return IPTRpcSuccess
 }
 
+func getConsensusPolicy(params []interface{}) map[string]interface{} {
+	level, list := consensus.DefaultPolicy.Get()
+	addresses := []string{}
+	for _, programHash := range list {
+		address, err := programHash.ToAddress()
+		if err != nil {
+			return IPTRpcInternalError
+		}
+		addresses = append(addresses, address)
+	}
+	return IPTRpc(map[string]interface{}{
+		"PolicyLevel": level.String(),
+		"List":        addresses,
+	})
+}
+
+func reloadConsensusPolicy(params []interface{}) map[string]interface{} {
+	if err := consensus.DefaultPolicy.Refresh(); err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	return getConsensusPolicy(params)
+}
+
 func sendSampleTransaction(params []interface{}) map[string]interface{} {
 	if len(params) < 1 {
 		return IPTRpcNil
@@ -490,7 +813,9 @@ func catDataRecord(params []interface{}) map[string]interface{} {
 			return IPTRpcInvalidTransaction
 		}
 		tx, err := ledger.DefaultLedger.Store.GetTransaction(hash)
-		if err != nil {
+		if err == ledger.ErrPruned {
+			return IPTRpcPrunedData
+		} else if err != nil {
 			return IPTRpcUnknownTransaction
 		}
 		tran := TransArryByteToHexString(tx)
@@ -519,7 +844,9 @@ func getDataFile(params []interface{}) map[string]interface{} {
 			return IPTRpcInvalidTransaction
 		}
 		tx, err := ledger.DefaultLedger.Store.GetTransaction(hash)
-		if err != nil {
+		if err == ledger.ErrPruned {
+			return IPTRpcPrunedData
+		} else if err != nil {
 			return IPTRpcUnknownTransaction
 		}
 
@@ -735,11 +1062,22 @@ func makeRegTxn(params []interface{}) map[string]interface{} {
 	default:
 		return IPTRpcInvalidParameter
 	}
+	recordType := asset.UTXO
+	if len(params) > 2 {
+		switch params[2].(type) {
+		case bool:
+			if params[2].(bool) {
+				recordType = asset.Balance
+			}
+		default:
+			return IPTRpcInvalidParameter
+		}
+	}
 	if Wallet == nil {
 		return IPTRpc("open wallet first")
 	}
 
-	regTxn, err := sdk.MakeRegTransaction(Wallet, assetName, assetValue)
+	regTxn, err := sdk.MakeRegTransaction(Wallet, assetName, assetValue, recordType)
 	if err != nil {
 		return IPTRpcInternalError
 	}
@@ -796,6 +1134,23 @@ func makeIssueTxn(params []interface{}) map[string]interface{} {
 	return IPTRpc(true)
 }
 
+// makeTransferTxn spends UTXOs of the wallet, or the balance of its default
+// account with the next free nonce for balance-model assets.
+func makeTransferTxn(assetID Uint256, batchOut ...sdk.BatchOut) (*tx.Transaction, error) {
+	if !ledger.DefaultLedger.Store.IsBalanceAsset(assetID) {
+		return sdk.MakeTransferTransaction(Wallet, assetID, batchOut...)
+	}
+	mainAccount, err := Wallet.GetDefaultAccount()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := node.GetBalanceNonce(mainAccount.ProgramHash)
+	if err != nil {
+		return nil, err
+	}
+	return sdk.MakeBalanceTransferTransaction(Wallet, assetID, nonce, batchOut...)
+}
+
 func sendToAddress(params []interface{}) map[string]interface{} {
 	if len(params) < 3 {
 		return IPTRpcNil
@@ -850,7 +1205,7 @@ func sendToAddress(params []interface{}) map[string]interface{} {
 	if err := assetID.Deserialize(bytes.NewReader(tmp)); err != nil {
 		return IPTRpc("error: invalid asset hash")
 	}
-	txn, err := sdk.MakeTransferTransaction(Wallet, assetID, batchOut)
+	txn, err := makeTransferTxn(assetID, batchOut)
 	if err != nil {
 		return IPTRpc("error: " + err.Error())
 	}
@@ -905,11 +1260,11 @@ func lockAsset(params []interface{}) map[string]interface{} {
 	if len(params) < 3 {
 		return IPTRpcNil
 	}
-	var asset, value string
+	var assetid, value string
 	var height float64
 	switch params[0].(type) {
 	case string:
-		asset = params[0].(string)
+		assetid = params[0].(string)
 	default:
 		return IPTRpcInvalidParameter
 	}
@@ -925,6 +1280,34 @@ func lockAsset(params []interface{}) map[string]interface{} {
 	default:
 		return IPTRpcInvalidParameter
 	}
+	// optional vesting schedule: start height, cliff height, release and steps
+	vesting := len(params) > 3
+	var start, cliff, steps float64
+	release := asset.ReleaseCliff
+	if vesting {
+		if len(params) < 6 {
+			return IPTRpcNil
+		}
+		var ok bool
+		if start, ok = params[3].(float64); !ok {
+			return IPTRpcInvalidParameter
+		}
+		if cliff, ok = params[4].(float64); !ok {
+			return IPTRpcInvalidParameter
+		}
+		name, ok := params[5].(string)
+		if !ok {
+			return IPTRpcInvalidParameter
+		}
+		if release, ok = asset.ParseReleaseType(name); !ok {
+			return IPTRpcInvalidParameter
+		}
+		if len(params) > 6 {
+			if steps, ok = params[6].(float64); !ok {
+				return IPTRpcInvalidParameter
+			}
+		}
+	}
 	if Wallet == nil {
 		return IPTRpc("error: invalid wallet instance")
 	}
@@ -934,6 +1317,53 @@ func lockAsset(params []interface{}) map[string]interface{} {
 		return IPTRpc("error: does't support multi-addresses wallet locking asset")
 	}
 
+	tmp, err := HexStringToBytesReverse(assetid)
+	if err != nil {
+		return IPTRpc("error: invalid asset ID")
+	}
+	var assetID Uint256
+	if err := assetID.Deserialize(bytes.NewReader(tmp)); err != nil {
+		return IPTRpc("error: invalid asset hash")
+	}
+
+	var txn *tx.Transaction
+	if vesting {
+		txn, err = sdk.MakeVestingLockTransaction(Wallet, assetID, value, uint32(start), uint32(cliff), uint32(height), release, uint32(steps))
+	} else {
+		txn, err = sdk.MakeLockAssetTransaction(Wallet, assetID, value, uint32(height))
+	}
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+
+	txnHash := txn.Hash()
+	if errCode := VerifyAndSendTx(txn); errCode != ErrNoError {
+		return IPTRpc(errCode.Error())
+	}
+	return IPTRpc(BytesToHexString(txnHash.ToArrayReverse()))
+}
+
+func burnAsset(params []interface{}) map[string]interface{} {
+	if len(params) < 2 {
+		return IPTRpcNil
+	}
+	var asset, value string
+	switch params[0].(type) {
+	case string:
+		asset = params[0].(string)
+	default:
+		return IPTRpcInvalidParameter
+	}
+	switch params[1].(type) {
+	case string:
+		value = params[1].(string)
+	default:
+		return IPTRpcInvalidParameter
+	}
+	if Wallet == nil {
+		return IPTRpc("error: invalid wallet instance")
+	}
+
 	tmp, err := HexStringToBytesReverse(asset)
 	if err != nil {
 		return IPTRpc("error: invalid asset ID")
@@ -943,7 +1373,7 @@ func lockAsset(params []interface{}) map[string]interface{} {
 		return IPTRpc("error: invalid asset hash")
 	}
 
-	txn, err := sdk.MakeLockAssetTransaction(Wallet, assetID, value, uint32(height))
+	txn, err := sdk.MakeBurnTransaction(Wallet, assetID, value)
 	if err != nil {
 		return IPTRpc("error: " + err.Error())
 	}
@@ -955,54 +1385,420 @@ func lockAsset(params []interface{}) map[string]interface{} {
 	return IPTRpc(BytesToHexString(txnHash.ToArrayReverse()))
 }
 
-func signMultisigTransaction(params []interface{}) map[string]interface{} {
-	if len(params) < 1 {
+func freezeAsset(params []interface{}) map[string]interface{} {
+	if len(params) < 3 {
 		return IPTRpcNil
 	}
-	var signedrawtxn string
+	var asset, address string
+	var freeze bool
 	switch params[0].(type) {
 	case string:
-		signedrawtxn = params[0].(string)
+		asset = params[0].(string)
+	default:
+		return IPTRpcInvalidParameter
+	}
+	switch params[1].(type) {
+	case string:
+		address = params[1].(string)
+	default:
+		return IPTRpcInvalidParameter
+	}
+	switch params[2].(type) {
+	case bool:
+		freeze = params[2].(bool)
 	default:
 		return IPTRpcInvalidParameter
 	}
+	if Wallet == nil {
+		return IPTRpc("error: invalid wallet instance")
+	}
+
+	tmp, err := HexStringToBytesReverse(asset)
+	if err != nil {
+		return IPTRpc("error: invalid asset ID")
+	}
+	var assetID Uint256
+	if err := assetID.Deserialize(bytes.NewReader(tmp)); err != nil {
+		return IPTRpc("error: invalid asset hash")
+	}
+
+	txn, err := sdk.MakeFreezeTransaction(Wallet, assetID, address, freeze)
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+
+	txnHash := txn.Hash()
+	if errCode := VerifyAndSendTx(txn); errCode != ErrNoError {
+		return IPTRpc(errCode.Error())
+	}
+	return IPTRpc(BytesToHexString(txnHash.ToArrayReverse()))
+}
+
+// initiateswap locks value of the asset in a hash time-locked contract to
+// the recipient public key until the timeout height. Without a hash lock a
+// random secret is generated and returned.
+func initiateSwap(params []interface{}) map[string]interface{} {
+	if len(params) < 4 {
+		return IPTRpcNil
+	}
+	assetid, ok1 := params[0].(string)
+	recipient, ok2 := params[1].(string)
+	value, ok3 := params[2].(string)
+	timeout, ok4 := params[3].(float64)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return IPTRpcInvalidParameter
+	}
+	hashType := contract.HTLCSha256
+	if len(params) > 4 {
+		switch params[4] {
+		case "sha256":
+		case "sm3":
+			hashType = contract.HTLCSm3
+		default:
+			return IPTRpcInvalidParameter
+		}
+	}
+	info := &SwapInfo{Timeout: uint32(timeout)}
+	var hashLock []byte
+	if len(params) > 5 {
+		lock, ok := params[5].(string)
+		if !ok {
+			return IPTRpcInvalidParameter
+		}
+		var err error
+		if hashLock, err = HexStringToBytes(lock); err != nil {
+			return IPTRpcInvalidParameter
+		}
+	} else {
+		secret := make([]byte, contract.HTLCPreimageLen)
+		if _, err := crand.Read(secret); err != nil {
+			return IPTRpcInternalError
+		}
+		hashLock, _ = hashType.Hash(secret)
+		info.Secret = BytesToHexString(secret)
+	}
+	if Wallet == nil {
+		return IPTRpc("error: invalid wallet instance")
+	}
+
+	tmp, err := HexStringToBytesReverse(assetid)
+	if err != nil {
+		return IPTRpc("error: invalid asset ID")
+	}
+	var assetID Uint256
+	if err := assetID.Deserialize(bytes.NewReader(tmp)); err != nil {
+		return IPTRpc("error: invalid asset hash")
+	}
+	keyBytes, err := HexStringToBytes(recipient)
+	if err != nil {
+		return IPTRpcInvalidParameter
+	}
+	recipientKey, err := crypto.DecodePoint(keyBytes)
+	if err != nil {
+		return IPTRpc("error: invalid recipient public key")
+	}
+
+	htlc, err := sdk.MakeHTLCContract(Wallet, hashType, hashLock, uint32(timeout), recipientKey)
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	address, err := htlc.ProgramHash.ToAddress()
+	if err != nil {
+		return IPTRpcInternalError
+	}
+	txn, err := makeTransferTxn(assetID, sdk.BatchOut{Address: address, Value: value})
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	txnHash := txn.Hash()
+	if errCode := VerifyAndSendTx(txn); errCode != ErrNoError {
+		return IPTRpc(errCode.Error())
+	}
+
+	info.Contract = BytesToHexString(htlc.Code)
+	info.Address = address
+	info.HashLock = BytesToHexString(hashLock)
+	info.Txid = BytesToHexString(txnHash.ToArrayReverse())
+	info.Index = 0
+	info.Value = txn.Outputs[0].Value.String()
+	return IPTRpc(info)
+}
+
+// htlcFunding parses the contract, txid and index parameters of a swap
+// redeem or refund and looks up the funding output.
+func htlcFunding(params []interface{}) ([]byte, *tx.UTXOTxInput, *tx.TxOutput, error) {
+	code, ok1 := params[0].(string)
+	txid, ok2 := params[1].(string)
+	index, ok3 := params[2].(float64)
+	if !ok1 || !ok2 || !ok3 {
+		return nil, nil, nil, errors.New("invalid parameter")
+	}
+	htlcCode, err := HexStringToBytes(code)
+	if err != nil {
+		return nil, nil, nil, errors.New("invalid contract")
+	}
+	tmp, err := HexStringToBytesReverse(txid)
+	if err != nil {
+		return nil, nil, nil, errors.New("invalid txid")
+	}
+	var hash Uint256
+	if err := hash.Deserialize(bytes.NewReader(tmp)); err != nil {
+		return nil, nil, nil, errors.New("invalid txid")
+	}
+	funding, err := ledger.DefaultLedger.Store.GetTransaction(hash)
+	if err != nil {
+		return nil, nil, nil, errors.New("unknown funding transaction")
+	}
+	if int(index) >= len(funding.Outputs) {
+		return nil, nil, nil, errors.New("invalid output index")
+	}
+	output := funding.Outputs[int(index)]
+	programHash, err := ToCodeHash(htlcCode)
+	if err != nil || output.ProgramHash != programHash {
+		return nil, nil, nil, errors.New("output is not locked in this contract")
+	}
+	input := &tx.UTXOTxInput{ReferTxID: hash, ReferTxOutputIndex: uint16(index)}
+	return htlcCode, input, output, nil
+}
+
+// redeemswap claims the output of a swap contract with the preimage.
+func redeemSwap(params []interface{}) map[string]interface{} {
+	if len(params) < 4 {
+		return IPTRpcNil
+	}
+	preimage, ok := params[3].(string)
+	if !ok {
+		return IPTRpcInvalidParameter
+	}
+	secret, err := HexStringToBytes(preimage)
+	if err != nil {
+		return IPTRpcInvalidParameter
+	}
+	if Wallet == nil {
+		return IPTRpc("error: invalid wallet instance")
+	}
+	code, input, output, err := htlcFunding(params)
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	txn, err := sdk.MakeHTLCRedeemTransaction(Wallet, code, input, output, secret)
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	txnHash := txn.Hash()
+	if errCode := VerifyAndSendTx(txn); errCode != ErrNoError {
+		return IPTRpc(errCode.Error())
+	}
+	return IPTRpc(BytesToHexString(txnHash.ToArrayReverse()))
+}
+
+// refundswap returns the output of a swap contract to its sender after the
+// timeout.
+func refundSwap(params []interface{}) map[string]interface{} {
+	if len(params) < 3 {
+		return IPTRpcNil
+	}
+	if Wallet == nil {
+		return IPTRpc("error: invalid wallet instance")
+	}
+	code, input, output, err := htlcFunding(params)
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	txn, err := sdk.MakeHTLCRefundTransaction(Wallet, code, input, output)
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	txnHash := txn.Hash()
+	if errCode := VerifyAndSendTx(txn); errCode != ErrNoError {
+		return IPTRpc(errCode.Error())
+	}
+	return IPTRpc(BytesToHexString(txnHash.ToArrayReverse()))
+}
+
+// parseAssetID reads a reversed hex asset ID parameter.
+func parseAssetID(param interface{}) (Uint256, error) {
+	var assetID Uint256
+	str, ok := param.(string)
+	if !ok {
+		return assetID, errors.New("invalid asset ID")
+	}
+	tmp, err := HexStringToBytesReverse(str)
+	if err != nil {
+		return assetID, errors.New("invalid asset ID")
+	}
+	if err := assetID.Deserialize(bytes.NewReader(tmp)); err != nil {
+		return assetID, errors.New("invalid asset hash")
+	}
+	return assetID, nil
+}
 
-	rawtxn, _ := HexStringToBytes(signedrawtxn)
+// parseRawTransaction reads a hex encoded transaction parameter.
+func parseRawTransaction(param interface{}) (*tx.Transaction, error) {
+	str, ok := param.(string)
+	if !ok {
+		return nil, errors.New("invalid transaction format")
+	}
+	raw, err := HexStringToBytes(str)
+	if err != nil {
+		return nil, errors.New("invalid transaction format")
+	}
 	var txn tx.Transaction
-	txn.Deserialize(bytes.NewReader(rawtxn))
-	if len(txn.Programs) <= 0 {
-		return IPTRpc("missing the first signature")
-	}
-
-	found := false
-	programHashes := txn.ParseTransactionCode()
-	for _, hash := range programHashes {
-		acct := Wallet.GetAccountByProgramHash(hash)
-		if acct != nil {
-			found = true
-			sig, _ := signature.SignBySigner(&txn, acct)
-			txn.AppendNewSignature(sig)
+	if err := txn.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, errors.New("invalid transaction")
+	}
+	return &txn, nil
+}
+
+// maketradeoffer returns an unsigned offer of the wallet exchanging an amount
+// of one asset for an amount of another.
+func makeTradeOffer(params []interface{}) map[string]interface{} {
+	if len(params) < 4 {
+		return IPTRpcNil
+	}
+	giveValue, ok1 := params[1].(string)
+	wantValue, ok2 := params[3].(string)
+	if !ok1 || !ok2 {
+		return IPTRpcInvalidParameter
+	}
+	give, err := parseAssetID(params[0])
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	want, err := parseAssetID(params[2])
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	if Wallet == nil {
+		return IPTRpc("error: invalid wallet instance")
+	}
+	offer, err := sdk.MakeTradeOffer(Wallet, give, giveValue, want, wantValue)
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	return IPTRpc(BytesToHexString(offer.ToArray()))
+}
+
+// accepttradeoffer completes an offer with the assets of the wallet and
+// returns the trade signed by the wallet for the maker to sign.
+func acceptTradeOffer(params []interface{}) map[string]interface{} {
+	if len(params) < 1 {
+		return IPTRpcNil
+	}
+	offer, err := parseRawTransaction(params[0])
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	if Wallet == nil {
+		return IPTRpc("error: invalid wallet instance")
+	}
+	txn, err := sdk.AcceptTradeOffer(Wallet, offer)
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	return IPTRpc(BytesToHexString(txn.ToArray()))
+}
+
+// signtrade checks an accepted trade against the original offer, adds the
+// signature of the maker and sends it.
+func signTrade(params []interface{}) map[string]interface{} {
+	if len(params) < 2 {
+		return IPTRpcNil
+	}
+	offer, err := parseRawTransaction(params[0])
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	txn, err := parseRawTransaction(params[1])
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	if Wallet == nil {
+		return IPTRpc("error: invalid wallet instance")
+	}
+	txn, err = sdk.SignTradeTransaction(Wallet, offer, txn)
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	txnHash := txn.Hash()
+	if errCode := VerifyAndSendTx(txn); errCode != ErrNoError {
+		return IPTRpc(errCode.Error())
+	}
+	return IPTRpc(BytesToHexString(txnHash.ToArrayReverse()))
+}
+
+// readPartialTransaction reads a partial transaction, a raw transaction is
+// taken as one with the signatures its programs carry.
+func readPartialTransaction(param interface{}) (*tx.PartialTransaction, error) {
+	str, ok := param.(string)
+	if !ok {
+		return nil, errors.New("invalid transaction format")
+	}
+	raw, err := HexStringToBytes(str)
+	if err != nil {
+		return nil, errors.New("invalid transaction format")
+	}
+	if tx.IsPartialTransaction(raw) {
+		ptx := new(tx.PartialTransaction)
+		if err := ptx.Deserialize(bytes.NewReader(raw)); err != nil {
+			return nil, err
 		}
+		return ptx, nil
 	}
-	if !found {
-		return IPTRpc("error: no available account detected")
+	var txn tx.Transaction
+	if err := txn.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, errors.New("invalid transaction")
 	}
+	return tx.NewPartialTransaction(&txn)
+}
 
-	_, needsig, err := txn.ParseTransactionSig()
+// finishPartialTransaction sends ptx once it is completely signed and returns
+// the serialized partial transaction otherwise.
+func finishPartialTransaction(ptx *tx.PartialTransaction) map[string]interface{} {
+	if !ptx.IsCompleted() {
+		return IPTRpc(BytesToHexString(ptx.ToArray()))
+	}
+	txn, err := ptx.Finalize()
 	if err != nil {
 		return IPTRpc("error: " + err.Error())
 	}
-	if needsig == 0 {
-		txnHash := txn.Hash()
-		if errCode := VerifyAndSendTx(&txn); errCode != ErrNoError {
-			return IPTRpc(errCode.Error())
+	txnHash := txn.Hash()
+	if errCode := VerifyAndSendTx(txn); errCode != ErrNoError {
+		return IPTRpc(errCode.Error())
+	}
+	return IPTRpc(BytesToHexString(txnHash.ToArrayReverse()))
+}
+
+// signmultisigtransaction merges the partial transactions given, which have
+// to be of the same transaction, and signs the result with the wallet.
+func signMultisigTransaction(params []interface{}) map[string]interface{} {
+	if len(params) < 1 {
+		return IPTRpcNil
+	}
+	if Wallet == nil {
+		return IPTRpc("error: invalid wallet instance")
+	}
+	var ptx *tx.PartialTransaction
+	for _, param := range params {
+		next, err := readPartialTransaction(param)
+		if err != nil {
+			return IPTRpc("error: " + err.Error())
+		}
+		if ptx == nil {
+			ptx = next
+		} else if err := ptx.Merge(next); err != nil {
+			return IPTRpc("error: " + err.Error())
 		}
-		return IPTRpc(BytesToHexString(txnHash.ToArrayReverse()))
-	} else {
-		var buffer bytes.Buffer
-		txn.Serialize(&buffer)
-		return IPTRpc(BytesToHexString(buffer.Bytes()))
 	}
+
+	signed, err := Wallet.SignPartialTransaction(ptx)
+	if err != nil {
+		return IPTRpc("error: " + err.Error())
+	}
+	if signed == 0 && len(params) == 1 {
+		return IPTRpc("error: no available account detected")
+	}
+	return finishPartialTransaction(ptx)
 }
 
 func createMultisigTransaction(params []interface{}) map[string]interface{} {
@@ -1055,21 +1851,11 @@ func createMultisigTransaction(params []interface{}) map[string]interface{} {
 		return IPTRpc("error: " + err.Error())
 	}
 
-	_, needsig, err := txn.ParseTransactionSig()
+	ptx, err := tx.NewPartialTransaction(txn)
 	if err != nil {
 		return IPTRpc("error: " + err.Error())
 	}
-	if needsig == 0 {
-		txnHash := txn.Hash()
-		if errCode := VerifyAndSendTx(txn); errCode != ErrNoError {
-			return IPTRpc(errCode.Error())
-		}
-		return IPTRpc(BytesToHexString(txnHash.ToArrayReverse()))
-	} else {
-		var buffer bytes.Buffer
-		txn.Serialize(&buffer)
-		return IPTRpc(BytesToHexString(buffer.Bytes()))
-	}
+	return finishPartialTransaction(ptx)
 }
 
 func getBalance(params []interface{}) map[string]interface{} {
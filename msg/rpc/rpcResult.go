@@ -8,6 +8,8 @@ var (
 
 	IPTRpcUnknownBlock       = responsePacking("unknown block")
 	IPTRpcUnknownTransaction = responsePacking("unknown transaction")
+	IPTRpcPrunedData         = responsePacking("data pruned on this node")
+	IPTRpcForbidden          = responsePacking("maintenance method not allowed")
 
 	IPTRpcNil           = responsePacking(nil)
 	IPTRpcUnsupported   = responsePacking("Unsupported")
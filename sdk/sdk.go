@@ -9,11 +9,13 @@ import (
 
 	"IPT/account"
 	. "IPT/common"
-	"IPT/common/config"
 	. "IPT/core/asset"
 	"IPT/core/contract"
+	"IPT/core/contract/program"
 	"IPT/core/signature"
 	"IPT/core/transaction"
+	"IPT/core/transaction/payload"
+	"IPT/crypto"
 )
 
 type BatchOut struct {
@@ -55,13 +57,13 @@ func sortCoinsByValue(coins map[*transaction.UTXOTxInput]*account.Coin, addrtype
 	return coinList
 }
 
-func MakeRegTransaction(wallet account.Client, name string, value string) (*transaction.Transaction, error) {
+func MakeRegTransaction(wallet account.Client, name string, value string, recordType AssetRecordType) (*transaction.Transaction, error) {
 	admin, err := wallet.GetDefaultAccount()
 	if err != nil {
 		return nil, err
 	}
 	issuer := admin
-	asset := &Asset{name, name, byte(MaxPrecision), AssetType(Token), UTXO}
+	asset := &Asset{name, name, byte(MaxPrecision), AssetType(Token), recordType}
 	transactionContract, err := contract.CreateSignatureContract(admin.PubKey())
 	if err != nil {
 		fmt.Println("CreateSignatureContract failed")
@@ -136,22 +138,229 @@ func MakeLockAssetTransaction(wallet account.Client, assetID Uint256, value stri
 	return txn, nil
 }
 
-func getTransferTxnPerOutputFee(outputNum int) (Fixed64, error) {
-	var txnFee Fixed64
-	var err error
-	if v, ok := config.Parameters.TransactionFee["Transfer"]; ok {
-		if v == -1 {
-			// TODO: calculate transaction fee by using transaction size
-		} else {
-			// get transaction fee from configuration file, precision .4
-			txnFee, err = StringToFixed64(strconv.FormatFloat(v, 'f', 4, 64))
-			if err != nil {
-				return Fixed64(0), errors.New("invalid transaction fee")
+// MakeBurnTransaction destroys value of the asset held by the default
+// account. The declared fee is paid in the burned asset on top of value.
+func MakeBurnTransaction(wallet account.Client, assetID Uint256, value string) (*transaction.Transaction, error) {
+	mainAccount, err := wallet.GetDefaultAccount()
+	if err != nil {
+		return nil, err
+	}
+	amount, err := StringToFixed64(value)
+	if err != nil {
+		return nil, err
+	}
+	if amount <= 0 {
+		return nil, errors.New("invalid burn amount")
+	}
+
+	// the fee grows with the inputs, select coins until they cover it
+	var fee Fixed64
+	coins := wallet.GetCoins()
+	sorted := sortCoinsByValue(coins, account.SingleSign)
+	for {
+		var total Fixed64
+		input := []*transaction.UTXOTxInput{}
+		for _, coinItem := range sorted {
+			if total >= amount+fee {
+				break
+			}
+			if coinItem.coin.Output.AssetID == assetID {
+				input = append(input, coinItem.input)
+				total += coinItem.coin.Output.Value
+			}
+		}
+		if total < amount+fee {
+			return nil, errors.New("token is not enough")
+		}
+
+		output := []*transaction.TxOutput{}
+		if total > amount+fee {
+			changes := &transaction.TxOutput{
+				AssetID:     assetID,
+				Value:       total - amount - fee,
+				ProgramHash: mainAccount.ProgramHash,
 			}
+			output = append(output, changes)
+		}
+		txn, err := transaction.NewBurnAssetTransaction(input, output, assetID, amount)
+		if err != nil {
+			return nil, err
+		}
+		txAttr := transaction.NewTxAttribute(transaction.Nonce, []byte(strconv.FormatInt(rand.Int63(), 10)))
+		txn.Attributes = append(txn.Attributes, &txAttr)
+		txn.SetFee(fee)
+
+		// leave room for a change output added by a higher fee
+		size := len(txn.ToArray()) + signedSize(transaction.PublickKeyScriptLen, 1)
+		if len(output) == 0 {
+			size += 8 + 32 + 20
+		}
+		if minimum := transaction.MinimumFee(transaction.BurnAsset, size); fee < minimum {
+			fee = minimum
+			continue
 		}
+
+		ctx := contract.NewContractContext(txn)
+		wallet.Sign(ctx)
+		txn.SetPrograms(ctx.GetPrograms())
+
+		return txn, nil
+	}
+}
+
+// MakeFreezeTransaction freezes or unfreezes the asset held by address, the
+// default account has to be the controller of the asset.
+func MakeFreezeTransaction(wallet account.Client, assetID Uint256, address string, freeze bool) (*transaction.Transaction, error) {
+	programHash, err := ToScriptHash(address)
+	if err != nil {
+		return nil, err
+	}
+	txn, _ := transaction.NewFreezeAssetTransaction(assetID, programHash, freeze)
+	txAttr := transaction.NewTxAttribute(transaction.Nonce, []byte(strconv.FormatInt(rand.Int63(), 10)))
+	txn.Attributes = append(txn.Attributes, &txAttr)
+
+	ctx := contract.NewContractContext(txn)
+	if err := wallet.Sign(ctx); err != nil {
+		return nil, err
+	}
+	txn.SetPrograms(ctx.GetPrograms())
+
+	return txn, nil
+}
+
+// MakeVestingLockTransaction locks value of the default account and releases
+// it from start to unlock, nothing vests before cliff.
+func MakeVestingLockTransaction(wallet account.Client, assetID Uint256, value string, start, cliff, unlock uint32, release ReleaseType, steps uint32) (*transaction.Transaction, error) {
+	mainAccount, err := wallet.GetDefaultAccount()
+	if err != nil {
+		return nil, err
+	}
+	fixedValue, err := StringToFixed64(value)
+	if err != nil {
+		return nil, err
+	}
+	txn, _ := transaction.NewLockAssetTransaction(mainAccount.ProgramHash, assetID, fixedValue, unlock)
+	txn.PayloadVersion = payload.LockAssetVestingPayloadVersion
+	lp := txn.Payload.(*payload.LockAsset)
+	lp.StartHeight = start
+	lp.CliffHeight = cliff
+	lp.Release = release
+	lp.Steps = steps
+	txAttr := transaction.NewTxAttribute(transaction.Nonce, []byte(strconv.FormatInt(rand.Int63(), 10)))
+	txn.Attributes = append(txn.Attributes, &txAttr)
+
+	ctx := contract.NewContractContext(txn)
+	if err := wallet.Sign(ctx); err != nil {
+		return nil, err
+	}
+	txn.SetPrograms(ctx.GetPrograms())
+
+	return txn, nil
+}
+
+// MakeHTLCContract creates a hash time-locked contract from the default
+// account to recipient. Assets sent to its program hash can be redeemed by
+// recipient with the preimage of hashLock until timeout and refunded after.
+func MakeHTLCContract(wallet account.Client, hashType contract.HTLCHashType, hashLock []byte, timeout uint32, recipient *crypto.PubKey) (*contract.Contract, error) {
+	mainAccount, err := wallet.GetDefaultAccount()
+	if err != nil {
+		return nil, err
 	}
+	return contract.CreateHTLCContract(hashType, hashLock, timeout, recipient, mainAccount.PubKey())
+}
 
-	return txnFee / Fixed64(outputNum), nil
+// MakeHTLCRedeemTransaction spends output, locked in the HTLC with code at
+// input, to the recipient of the contract by revealing preimage.
+func MakeHTLCRedeemTransaction(wallet account.Client, code []byte, input *transaction.UTXOTxInput, output *transaction.TxOutput, preimage []byte) (*transaction.Transaction, error) {
+	htlc, err := contract.ParseHTLC(code)
+	if err != nil {
+		return nil, err
+	}
+	if !htlc.Unlocks(preimage) {
+		return nil, errors.New("preimage does not match the hash lock")
+	}
+	return makeHTLCSpend(wallet, code, htlc.Recipient, input, output, preimage, htlc.Timeout)
+}
+
+// MakeHTLCRefundTransaction returns output, locked in the HTLC with code at
+// input, to the sender of the contract once the timeout passed.
+func MakeHTLCRefundTransaction(wallet account.Client, code []byte, input *transaction.UTXOTxInput, output *transaction.TxOutput) (*transaction.Transaction, error) {
+	htlc, err := contract.ParseHTLC(code)
+	if err != nil {
+		return nil, err
+	}
+	return makeHTLCSpend(wallet, code, htlc.Sender, input, output, nil, 0)
+}
+
+// makeHTLCSpend pays output to the account of pubKey, which signs the
+// witness. A redeem with preimage expires at validUntil.
+func makeHTLCSpend(wallet account.Client, code []byte, pubKey []byte, input *transaction.UTXOTxInput, output *transaction.TxOutput, preimage []byte, validUntil uint32) (*transaction.Transaction, error) {
+	key, err := crypto.DecodePoint(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := wallet.GetAccount(key)
+	if err != nil || signer == nil {
+		return nil, errors.New("the wallet does not hold the key of this contract")
+	}
+
+	outputs := []*transaction.TxOutput{{
+		AssetID:     output.AssetID,
+		Value:       output.Value,
+		ProgramHash: signer.ProgramHash,
+	}}
+	txn, err := transaction.NewTransferAssetTransaction([]*transaction.UTXOTxInput{input}, outputs)
+	if err != nil {
+		return nil, err
+	}
+	txAttr := transaction.NewTxAttribute(transaction.Nonce, []byte(strconv.FormatInt(rand.Int63(), 10)))
+	txn.Attributes = append(txn.Attributes, &txAttr)
+	if preimage != nil {
+		txn.SetValidUntil(validUntil)
+	}
+	// the signature is followed by the branch flag, a redeem pushes and
+	// drops the preimage before it
+	programSize := signedSize(len(code), 1) + 1
+	if preimage != nil {
+		programSize += 1 + len(preimage) + 1
+	}
+	if err := declareFee(txn, 1, programSize); err != nil {
+		return nil, err
+	}
+
+	sig, err := signature.SignBySigner(txn, signer)
+	if err != nil {
+		return nil, err
+	}
+	parameter := contract.CreateHTLCRefundParameter(sig)
+	if preimage != nil {
+		parameter = contract.CreateHTLCRedeemParameter(sig, preimage)
+	}
+	txn.SetPrograms([]*program.Program{{Code: code, Parameter: parameter}})
+
+	return txn, nil
+}
+
+// signedSize estimates the bytes the programs add to a transaction once
+// signed, with varint lengths counted at their largest.
+func signedSize(codeLen int, signatures int) int {
+	return 3 + codeLen + 3 + signatures*transaction.SignatureScriptLen
+}
+
+// declareFee declares the minimum fee of the unsigned txn and takes it evenly
+// from the first outputNum outputs.
+func declareFee(txn *transaction.Transaction, outputNum int, programSize int) error {
+	txn.SetFee(0)
+	fee := transaction.MinimumFee(txn.TxType, len(txn.ToArray())+programSize)
+	perOutputFee := (fee + Fixed64(outputNum) - 1) / Fixed64(outputNum)
+	for _, o := range txn.Outputs[:outputNum] {
+		if o.Value <= perOutputFee {
+			return errors.New("token is not enough for transaction fee")
+		}
+		o.Value -= perOutputFee
+	}
+	txn.SetFee(perOutputFee * Fixed64(outputNum))
+	return nil
 }
 
 func MakeTransferTransaction(wallet account.Client, assetID Uint256, batchOut ...BatchOut) (*transaction.Transaction, error) {
@@ -166,10 +375,6 @@ func MakeTransferTransaction(wallet account.Client, assetID Uint256, batchOut ..
 	if err != nil {
 		return nil, err
 	}
-	perOutputFee, err := getTransferTxnPerOutputFee(len(batchOut))
-	if err != nil {
-		return nil, err
-	}
 
 	var expected Fixed64
 	input := []*transaction.UTXOTxInput{}
@@ -183,9 +388,6 @@ func MakeTransferTransaction(wallet account.Client, assetID Uint256, batchOut ..
 		if err != nil {
 			return nil, err
 		}
-		if outputValue <= perOutputFee {
-			return nil, errors.New("token is not enough for transaction fee")
-		}
 		expected += outputValue
 		address, err := ToScriptHash(o.Address)
 		if err != nil {
@@ -193,7 +395,7 @@ func MakeTransferTransaction(wallet account.Client, assetID Uint256, batchOut ..
 		}
 		tmp := &transaction.TxOutput{
 			AssetID:     assetID,
-			Value:       outputValue - perOutputFee,
+			Value:       outputValue,
 			ProgramHash: address,
 		}
 		output = append(output, tmp)
@@ -245,6 +447,9 @@ func MakeTransferTransaction(wallet account.Client, assetID Uint256, batchOut ..
 		txn.Attributes = append(txn.Attributes, &txA)
 		//fmt.Println("---- add Note ----")
 	}
+	if err := declareFee(txn, outputNum, signedSize(transaction.PublickKeyScriptLen, 1)); err != nil {
+		return nil, err
+	}
 
 	// sign transaction contract
 	ctx := contract.NewContractContext(txn)
@@ -254,22 +459,83 @@ func MakeTransferTransaction(wallet account.Client, assetID Uint256, batchOut ..
 	return txn, nil
 }
 
-func MakeMultisigTransferTransaction(wallet account.Client, assetID Uint256, from string, batchOut ...BatchOut) (*transaction.Transaction, error) {
-	//TODO: check if being transferred asset is System Token(IPT)
+// MakeBalanceTransferTransaction transfers a balance-model asset from the
+// default account, nonce is the next unused nonce of that account.
+func MakeBalanceTransferTransaction(wallet account.Client, assetID Uint256, nonce uint64, batchOut ...BatchOut) (*transaction.Transaction, error) {
 	outputNum := len(batchOut)
 	if outputNum == 0 {
 		return nil, errors.New("nil outputs")
 	}
 
-	spendAddress, err := ToScriptHash(from)
+	mainAccount, err := wallet.GetDefaultAccount()
 	if err != nil {
-		return nil, errors.New("invalid sender address")
+		return nil, err
 	}
 
-	perOutputFee, err := getTransferTxnPerOutputFee(len(batchOut))
+	var expected Fixed64
+	output := []*transaction.TxOutput{}
+	noteArr := []string{}
+	for _, o := range batchOut {
+		outputValue, err := StringToFixed64(o.Value)
+		if err != nil {
+			return nil, err
+		}
+		expected += outputValue
+		address, err := ToScriptHash(o.Address)
+		if err != nil {
+			return nil, errors.New("invalid address")
+		}
+		tmp := &transaction.TxOutput{
+			AssetID:     assetID,
+			Value:       outputValue,
+			ProgramHash: address,
+		}
+		output = append(output, tmp)
+		if len(o.Note) > 0 {
+			noteArr = append(noteArr, o.Note)
+		}
+	}
+
+	txn, err := transaction.NewTransferAssetTransaction([]*transaction.UTXOTxInput{}, output)
 	if err != nil {
 		return nil, err
 	}
+	txn.PayloadVersion = payload.TransferAssetBalancePayloadVersion
+	txn.BalanceInputs = []*transaction.BalanceTxInput{{
+		AssetID:     assetID,
+		Value:       expected,
+		ProgramHash: mainAccount.ProgramHash,
+		Nonce:       nonce,
+	}}
+	txAttr := transaction.NewTxAttribute(transaction.Nonce, []byte(strconv.FormatInt(rand.Int63(), 10)))
+	txn.Attributes = make([]*transaction.TxAttribute, 0)
+	txn.Attributes = append(txn.Attributes, &txAttr)
+	for _, note := range noteArr {
+		txA := transaction.NewTxAttribute(transaction.Description, []byte(note))
+		txn.Attributes = append(txn.Attributes, &txA)
+	}
+	if err := declareFee(txn, outputNum, signedSize(transaction.PublickKeyScriptLen, 1)); err != nil {
+		return nil, err
+	}
+
+	ctx := contract.NewContractContext(txn)
+	wallet.Sign(ctx)
+	txn.SetPrograms(ctx.GetPrograms())
+
+	return txn, nil
+}
+
+func MakeMultisigTransferTransaction(wallet account.Client, assetID Uint256, from string, batchOut ...BatchOut) (*transaction.Transaction, error) {
+	//TODO: check if being transferred asset is System Token(IPT)
+	outputNum := len(batchOut)
+	if outputNum == 0 {
+		return nil, errors.New("nil outputs")
+	}
+
+	spendAddress, err := ToScriptHash(from)
+	if err != nil {
+		return nil, errors.New("invalid sender address")
+	}
 
 	var expected Fixed64
 	input := []*transaction.UTXOTxInput{}
@@ -280,9 +546,6 @@ func MakeMultisigTransferTransaction(wallet account.Client, assetID Uint256, fro
 		if err != nil {
 			return nil, err
 		}
-		if outputValue <= perOutputFee {
-			return nil, errors.New("token is not enough for transaction fee")
-		}
 		expected += outputValue
 		address, err := ToScriptHash(o.Address)
 		if err != nil {
@@ -290,7 +553,7 @@ func MakeMultisigTransferTransaction(wallet account.Client, assetID Uint256, fro
 		}
 		tmp := &transaction.TxOutput{
 			AssetID:     assetID,
-			Value:       outputValue - perOutputFee,
+			Value:       outputValue,
 			ProgramHash: address,
 		}
 		output = append(output, tmp)
@@ -332,6 +595,14 @@ func MakeMultisigTransferTransaction(wallet account.Client, assetID Uint256, fro
 	txAttr := transaction.NewTxAttribute(transaction.Nonce, []byte(strconv.FormatInt(rand.Int63(), 10)))
 	txn.Attributes = make([]*transaction.TxAttribute, 0)
 	txn.Attributes = append(txn.Attributes, &txAttr)
+	for _, c := range wallet.GetContracts() {
+		if c.ProgramHash == spendAddress {
+			if err := declareFee(txn, outputNum, signedSize(len(c.Code), len(c.Parameters))); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
 
 	ctx := contract.NewContractContext(txn)
 	err = wallet.Sign(ctx)
@@ -0,0 +1,310 @@
+package sdk
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strconv"
+
+	"IPT/account"
+	. "IPT/common"
+	"IPT/core/contract"
+	"IPT/core/signature"
+	"IPT/core/transaction"
+)
+
+// TradeOffer is an exchange proposed by Maker: GiveValue of asset Give for
+// WantValue of asset Want. The counterparty pays the transaction fee.
+type TradeOffer struct {
+	Maker     Uint160
+	Give      Uint256
+	GiveValue Fixed64
+	Want      Uint256
+	WantValue Fixed64
+}
+
+// MakeTradeOffer creates the unsigned offer of the default account. It spends
+// the given asset and pays the wanted one back to the maker, the counterparty
+// completes it with AcceptTradeOffer.
+func MakeTradeOffer(wallet account.Client, give Uint256, giveValue string, want Uint256, wantValue string) (*transaction.Transaction, error) {
+	maker, err := wallet.GetDefaultAccount()
+	if err != nil {
+		return nil, err
+	}
+	giveAmount, err := StringToFixed64(giveValue)
+	if err != nil {
+		return nil, err
+	}
+	wantAmount, err := StringToFixed64(wantValue)
+	if err != nil {
+		return nil, err
+	}
+	if giveAmount <= 0 || wantAmount <= 0 {
+		return nil, errors.New("invalid trade amount")
+	}
+	if give == want {
+		return nil, errors.New("an offer has to exchange two different assets")
+	}
+
+	input, change, err := selectCoins(wallet, maker.ProgramHash, give, giveAmount)
+	if err != nil {
+		return nil, err
+	}
+	output := []*transaction.TxOutput{{
+		AssetID:     want,
+		Value:       wantAmount,
+		ProgramHash: maker.ProgramHash,
+	}}
+	if change > 0 {
+		output = append(output, &transaction.TxOutput{
+			AssetID:     give,
+			Value:       change,
+			ProgramHash: maker.ProgramHash,
+		})
+	}
+	txn, err := transaction.NewTransferAssetTransaction(input, output)
+	if err != nil {
+		return nil, err
+	}
+	txAttr := transaction.NewTxAttribute(transaction.Nonce, []byte(strconv.FormatInt(rand.Int63(), 10)))
+	txn.Attributes = append(txn.Attributes, &txAttr)
+
+	return txn, nil
+}
+
+// ParseTradeOffer reads the terms of an offer made by MakeTradeOffer.
+func ParseTradeOffer(offer *transaction.Transaction) (*TradeOffer, error) {
+	if offer.TxType != transaction.TransferAsset || len(offer.UTXOInputs) == 0 || len(offer.BalanceInputs) > 0 {
+		return nil, errors.New("not a trade offer")
+	}
+	reference, err := offer.GetReference()
+	if err != nil {
+		return nil, err
+	}
+	maker := reference[offer.UTXOInputs[0]].ProgramHash
+	for _, o := range reference {
+		if o.ProgramHash != maker {
+			return nil, errors.New("a trade offer spends the coins of its maker only")
+		}
+	}
+	for _, o := range offer.Outputs {
+		if o.ProgramHash != maker {
+			return nil, errors.New("a trade offer pays to its maker only")
+		}
+	}
+
+	terms := &TradeOffer{Maker: maker}
+	for assetID, value := range netValues(offer, reference, maker) {
+		switch {
+		case value < 0 && terms.GiveValue == 0:
+			terms.Give, terms.GiveValue = assetID, -value
+		case value > 0 && terms.WantValue == 0:
+			terms.Want, terms.WantValue = assetID, value
+		case value != 0:
+			return nil, errors.New("a trade offer exchanges exactly two assets")
+		}
+	}
+	if terms.GiveValue == 0 || terms.WantValue == 0 {
+		return nil, errors.New("a trade offer exchanges exactly two assets")
+	}
+	return terms, nil
+}
+
+// CheckTrade checks that the maker of terms gives and receives exactly what
+// the offer specified in txn, so the counterparty receives the rest.
+func CheckTrade(txn *transaction.Transaction, terms *TradeOffer) error {
+	if txn.TxType != transaction.TransferAsset || len(txn.BalanceInputs) > 0 {
+		return errors.New("not a trade transaction")
+	}
+	reference, err := txn.GetReference()
+	if err != nil {
+		return err
+	}
+	for assetID, value := range netValues(txn, reference, terms.Maker) {
+		switch {
+		case assetID == terms.Give && value == -terms.GiveValue:
+		case assetID == terms.Want && value == terms.WantValue:
+		case value != 0:
+			return errors.New("the trade does not match the offer")
+		}
+	}
+	return nil
+}
+
+// AcceptTradeOffer adds the inputs and outputs of the default account to
+// offer, pays the fee in the wanted asset and signs for the default account.
+// The maker completes the returned transaction with SignTradeTransaction.
+func AcceptTradeOffer(wallet account.Client, offer *transaction.Transaction) (*transaction.Transaction, error) {
+	terms, err := ParseTradeOffer(offer)
+	if err != nil {
+		return nil, err
+	}
+	taker, err := wallet.GetDefaultAccount()
+	if err != nil {
+		return nil, err
+	}
+	if taker.ProgramHash == terms.Maker {
+		return nil, errors.New("cannot accept an own offer")
+	}
+
+	// the fee grows with the inputs, select coins until they cover it
+	var fee Fixed64
+	for {
+		txn := new(transaction.Transaction)
+		if err := txn.Deserialize(bytes.NewReader(offer.ToArray())); err != nil {
+			return nil, err
+		}
+		input, change, err := selectCoins(wallet, taker.ProgramHash, terms.Want, terms.WantValue+fee)
+		if err != nil {
+			return nil, err
+		}
+		txn.UTXOInputs = append(txn.UTXOInputs, input...)
+		txn.Outputs = append(txn.Outputs, &transaction.TxOutput{
+			AssetID:     terms.Give,
+			Value:       terms.GiveValue,
+			ProgramHash: taker.ProgramHash,
+		})
+		if change > 0 {
+			txn.Outputs = append(txn.Outputs, &transaction.TxOutput{
+				AssetID:     terms.Want,
+				Value:       change,
+				ProgramHash: taker.ProgramHash,
+			})
+		}
+		txn.SetFee(fee)
+
+		// both parties sign, leave room for a change output added by a
+		// higher fee
+		size := len(txn.ToArray()) + 2*signedSize(transaction.PublickKeyScriptLen, 1)
+		if change == 0 {
+			size += 8 + 32 + 20
+		}
+		if minimum := transaction.MinimumFee(transaction.TransferAsset, size); fee < minimum {
+			fee = minimum
+			continue
+		}
+		if err := CheckTrade(txn, terms); err != nil {
+			return nil, err
+		}
+
+		ctx := contract.NewContractContext(txn)
+		if err := signOwn(wallet, ctx); err != nil {
+			return nil, err
+		}
+		txn.SetPrograms(ctx.GetUncompletedPrograms())
+
+		return txn, nil
+	}
+}
+
+// SignTradeTransaction checks that txn still matches offer and adds the
+// signature of the maker. The result is complete once the counterparty signed.
+func SignTradeTransaction(wallet account.Client, offer *transaction.Transaction, txn *transaction.Transaction) (*transaction.Transaction, error) {
+	terms, err := ParseTradeOffer(offer)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckTrade(txn, terms); err != nil {
+		return nil, err
+	}
+	ctx, err := restoreContext(txn)
+	if err != nil {
+		return nil, err
+	}
+	if err := signOwn(wallet, ctx); err != nil {
+		return nil, err
+	}
+	if !ctx.IsCompleted() {
+		return nil, errors.New("the counterparty has not signed the trade")
+	}
+	txn.SetPrograms(ctx.GetPrograms())
+
+	return txn, nil
+}
+
+// selectCoins picks single-sign coins of programHash worth at least value and
+// returns them with the change.
+func selectCoins(wallet account.Client, programHash Uint160, assetID Uint256, value Fixed64) ([]*transaction.UTXOTxInput, Fixed64, error) {
+	var total Fixed64
+	input := []*transaction.UTXOTxInput{}
+	for _, coinItem := range sortCoinsByValue(wallet.GetCoins(), account.SingleSign) {
+		if total >= value {
+			break
+		}
+		o := coinItem.coin.Output
+		if o.AssetID == assetID && o.ProgramHash == programHash {
+			input = append(input, coinItem.input)
+			total += o.Value
+		}
+	}
+	if total < value {
+		return nil, 0, errors.New("token is not enough")
+	}
+	return input, total - value, nil
+}
+
+// netValues returns what programHash receives minus what it spends in txn,
+// per asset.
+func netValues(txn *transaction.Transaction, reference map[*transaction.UTXOTxInput]*transaction.TxOutput, programHash Uint160) map[Uint256]Fixed64 {
+	values := make(map[Uint256]Fixed64)
+	for _, o := range txn.Outputs {
+		if o.ProgramHash == programHash {
+			values[o.AssetID] += o.Value
+		}
+	}
+	for _, o := range reference {
+		if o.ProgramHash == programHash {
+			values[o.AssetID] -= o.Value
+		}
+	}
+	return values
+}
+
+// signOwn signs the programs of ctx the wallet holds a single-sign account
+// for and leaves the others to their owners.
+func signOwn(wallet account.Client, ctx *contract.ContractContext) error {
+	signed := false
+	for _, hash := range ctx.ProgramHashes {
+		acct := wallet.GetAccountByProgramHash(hash)
+		if acct == nil {
+			continue
+		}
+		c, err := contract.CreateSignatureContract(acct.PubKey())
+		if err != nil {
+			return err
+		}
+		sig, err := signature.SignBySigner(ctx.Data, acct)
+		if err != nil {
+			return err
+		}
+		if err := ctx.AddContract(c, acct.PubKey(), sig); err != nil {
+			return err
+		}
+		signed = true
+	}
+	if !signed {
+		return errors.New("no available account detected")
+	}
+	return nil
+}
+
+// restoreContext rebuilds the signing context of txn from the single
+// signatures already in its programs.
+func restoreContext(txn *transaction.Transaction) (*contract.ContractContext, error) {
+	ctx := contract.NewContractContext(txn)
+	if len(txn.Programs) != len(ctx.ProgramHashes) {
+		return nil, errors.New("invalid transaction programs")
+	}
+	for i, p := range txn.Programs {
+		if len(p.Code) == 0 {
+			continue
+		}
+		hash, err := ToCodeHash(p.Code)
+		if err != nil || hash != ctx.ProgramHashes[i] || len(p.Parameter) != transaction.SignatureScriptLen {
+			return nil, errors.New("invalid transaction programs")
+		}
+		ctx.Codes[i] = p.Code
+		ctx.Parameters[i] = [][]byte{p.Parameter[1:]}
+	}
+	return ctx, nil
+}
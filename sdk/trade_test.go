@@ -0,0 +1,95 @@
+package sdk
+
+import (
+	"errors"
+	"testing"
+
+	. "IPT/common"
+	"IPT/core/transaction"
+)
+
+// coinStore holds the transactions trades spend from.
+type coinStore map[Uint256]*transaction.Transaction
+
+func (s coinStore) GetTransaction(hash Uint256) (*transaction.Transaction, error) {
+	if txn, ok := s[hash]; ok {
+		return txn, nil
+	}
+	return nil, errors.New("transaction not found")
+}
+
+func (s coinStore) GetQuantityIssued(assetId Uint256) (Fixed64, error) {
+	return 0, nil
+}
+
+// coin stores a transaction paying value of assetID to programHash and
+// returns the input spending it.
+func (s coinStore) coin(assetID Uint256, value Fixed64, programHash Uint160) *transaction.UTXOTxInput {
+	txn, _ := transaction.NewTransferAssetTransaction(nil, []*transaction.TxOutput{
+		{AssetID: assetID, Value: value, ProgramHash: programHash},
+	})
+	txn.Attributes = append(txn.Attributes, &transaction.TxAttribute{
+		Usage: transaction.Nonce, Data: []byte{byte(len(s))},
+	})
+	s[txn.Hash()] = txn
+	return &transaction.UTXOTxInput{ReferTxID: txn.Hash(), ReferTxOutputIndex: 0}
+}
+
+func TestCheckTrade(t *testing.T) {
+	defer func(store transaction.ILedgerStore) { transaction.TxStore = store }(transaction.TxStore)
+	store := coinStore{}
+	transaction.TxStore = store
+
+	give, want := Uint256{1}, Uint256{2}
+	maker, taker := Uint160{1}, Uint160{2}
+	offer, _ := transaction.NewTransferAssetTransaction(
+		[]*transaction.UTXOTxInput{store.coin(give, 120, maker)},
+		[]*transaction.TxOutput{
+			{AssetID: want, Value: 50, ProgramHash: maker},
+			{AssetID: give, Value: 20, ProgramHash: maker},
+		},
+	)
+	terms, err := ParseTradeOffer(offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if terms.Maker != maker || terms.Give != give || terms.GiveValue != 100 || terms.Want != want || terms.WantValue != 50 {
+		t.Fatalf("offer parsed as %+v", *terms)
+	}
+
+	// accept returns the counter-transaction of offer with the outputs of the
+	// taker and the output paying the maker the wanted asset set to paid
+	accept := func(paid Fixed64) *transaction.Transaction {
+		txn, _ := transaction.NewTransferAssetTransaction(
+			append(append([]*transaction.UTXOTxInput{}, offer.UTXOInputs...), store.coin(want, 60, taker)),
+			[]*transaction.TxOutput{
+				{AssetID: want, Value: paid, ProgramHash: maker},
+				{AssetID: give, Value: 20, ProgramHash: maker},
+				{AssetID: give, Value: 100, ProgramHash: taker},
+				{AssetID: want, Value: 60 - paid, ProgramHash: taker},
+			},
+		)
+		return txn
+	}
+	if err := CheckTrade(accept(50), terms); err != nil {
+		t.Fatalf("counter-transaction matching the offer rejected: %v", err)
+	}
+	if err := CheckTrade(accept(40), terms); err == nil {
+		t.Fatal("counter-transaction paying the maker less than wanted accepted")
+	}
+	spendsMore := accept(50)
+	spendsMore.UTXOInputs = append(spendsMore.UTXOInputs, store.coin(want, 10, maker))
+	if err := CheckTrade(spendsMore, terms); err == nil {
+		t.Fatal("counter-transaction spending more coins of the maker accepted")
+	}
+	takesGiven := accept(50)
+	takesGiven.Outputs[1].ProgramHash = taker
+	if err := CheckTrade(takesGiven, terms); err == nil {
+		t.Fatal("counter-transaction taking the change of the maker accepted")
+	}
+
+	// an offer paying to anyone but its maker is not an offer
+	if _, err := ParseTradeOffer(accept(50)); err == nil {
+		t.Fatal("counter-transaction parsed as an offer")
+	}
+}